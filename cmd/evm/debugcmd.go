@@ -0,0 +1,154 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/debugger"
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/tests"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	BreakpointFlag = cli.StringSliceFlag{
+		Name:  "break",
+		Usage: "breakpoint to stop at, e.g. \"pc 0x12\", \"op SSTORE\" or \"sstore 0x1\" (repeatable)",
+	}
+	StateTestFlag = cli.StringFlag{
+		Name:  "statetest",
+		Usage: "state test file to debug instead of the code given by the run flags",
+	}
+	StateTestNameFlag = cli.StringFlag{
+		Name:  "statetest.name",
+		Usage: "name of the state test to debug, if the file contains several",
+	}
+	StateTestForkFlag = cli.StringFlag{
+		Name:  "statetest.fork",
+		Usage: "fork of the state test to debug (default = first one)",
+	}
+	StateTestIndexFlag = cli.IntFlag{
+		Name:  "statetest.index",
+		Usage: "index of the post state of the state test to debug",
+	}
+)
+
+var debugCommand = cli.Command{
+	Action:    debugCmd,
+	Name:      "debug",
+	Usage:     "interactively step through evm execution",
+	ArgsUsage: "<code>",
+	Flags: []cli.Flag{
+		BreakpointFlag,
+		StateTestFlag,
+		StateTestNameFlag,
+		StateTestForkFlag,
+		StateTestIndexFlag,
+	},
+	Description: `
+The debug command executes EVM code like the run command, or a state test given
+through --statetest, stopping before the first instruction and at breakpoints to
+inspect the stack, memory and storage. Type help at the prompt for the commands.`,
+}
+
+func debugCmd(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	read := func(p string) (string, error) {
+		line, err := prompt.Stdin.PromptInput(p)
+		if err == nil && strings.TrimSpace(line) != "" {
+			prompt.Stdin.AppendHistory(line)
+		}
+		return line, err
+	}
+	dbg := debugger.New(read, os.Stdout)
+	for _, bp := range ctx.StringSlice(BreakpointFlag.Name) {
+		if err := dbg.AddBreakpoint(strings.Fields(bp)...); err != nil {
+			return fmt.Errorf("invalid breakpoint %q: %v", bp, err)
+		}
+	}
+	if path := ctx.String(StateTestFlag.Name); path != "" {
+		return debugStateTest(ctx, path, dbg)
+	}
+	env, err := newRunEnv(ctx, dbg, true)
+	if err != nil {
+		return err
+	}
+	// The outcome of the execution is reported by the debugger
+	env.execFunc(ctx.GlobalBool(CreateFlag.Name))()
+	return nil
+}
+
+// debugStateTest executes the selected subtest of the state test file with the
+// debugger and reports whether it passed.
+func debugStateTest(ctx *cli.Context, path string, dbg *debugger.Debugger) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var stateTests map[string]tests.StateTest
+	if err = json.Unmarshal(src, &stateTests); err != nil {
+		return err
+	}
+	name := ctx.String(StateTestNameFlag.Name)
+	if name == "" {
+		if len(stateTests) != 1 {
+			return fmt.Errorf("file contains %d tests, select one with --%s", len(stateTests), StateTestNameFlag.Name)
+		}
+		for key := range stateTests {
+			name = key
+		}
+	}
+	test, ok := stateTests[name]
+	if !ok {
+		return fmt.Errorf("test %q not found", name)
+	}
+	// Pick the requested subtest, ordering them as the forks are unordered
+	subtests := test.Subtests()
+	sort.Slice(subtests, func(i, j int) bool {
+		if subtests[i].Fork != subtests[j].Fork {
+			return subtests[i].Fork < subtests[j].Fork
+		}
+		return subtests[i].Index < subtests[j].Index
+	})
+	var (
+		fork  = ctx.String(StateTestForkFlag.Name)
+		index = ctx.Int(StateTestIndexFlag.Name)
+	)
+	for _, st := range subtests {
+		if (fork == "" || st.Fork == fork) && st.Index == index {
+			fmt.Printf("Debugging %s, fork %s, index %d\n", name, st.Fork, st.Index)
+			if _, _, err := test.Run(st, vm.Config{Debug: true, Tracer: dbg}, false); err != nil {
+				fmt.Printf("Test failed: %v\n", err)
+			} else {
+				fmt.Println("Test passed")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("subtest with fork %q and index %d not found", fork, index)
+}
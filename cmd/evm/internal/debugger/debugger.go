@@ -0,0 +1,395 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package debugger implements an interactive stepper for EVM executions.
+package debugger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// stepMode determines where the execution stops next, besides breakpoints.
+type stepMode int
+
+const (
+	modeStep     stepMode = iota // Stop at the next instruction, entering calls
+	modeNext                     // Stop at the next instruction of the same or an outer call
+	modeOut                      // Stop at the next instruction of an outer call
+	modeContinue                 // Stop at breakpoints only
+)
+
+// Debugger is a vm.Tracer which stops the execution before the first instruction
+// and at breakpoints, prompting the user for commands to inspect the stack, the
+// memory and the storage or to continue the execution.
+type Debugger struct {
+	prompt func(string) (string, error) // Reads a command from the user
+	out    io.Writer                    // Output of the debugger
+
+	breakpoints []*breakpoint
+	mode        stepMode
+	depth       int    // Call depth the current step mode refers to
+	last        string // Last command, repeated on empty input
+	quit        bool   // Whether the user aborted the execution
+
+	// Storage slots accessed by the execution, shown besides the prestate ones
+	storage map[common.Address]map[common.Hash]struct{}
+}
+
+// New creates a debugger reading the user commands with the given prompt
+// function and writing its output to out.
+func New(prompt func(string) (string, error), out io.Writer) *Debugger {
+	return &Debugger{
+		prompt:  prompt,
+		out:     out,
+		storage: make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// AddBreakpoint parses a breakpoint in the syntax of the break command, e.g.
+// "pc 0x12", "op SSTORE" or "sstore 0x01", and adds it to the debugger.
+func (d *Debugger) AddBreakpoint(args ...string) error {
+	bp, err := parseBreakpoint(args)
+	if err != nil {
+		return err
+	}
+	d.breakpoints = append(d.breakpoints, bp)
+	return nil
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (d *Debugger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	kind := "call"
+	if create {
+		kind = "create"
+	}
+	fmt.Fprintf(d.out, "Starting %s from %x to %x, gas %d, value %v, input 0x%x\n", kind, from, to, gas, value, input)
+}
+
+// CaptureState implements the vm.Tracer interface, stopping the execution if a
+// breakpoint is hit, the instruction fails or the user requested it.
+func (d *Debugger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if d.quit {
+		return
+	}
+	addr := scope.Contract.Address()
+	if (op == vm.SLOAD || op == vm.SSTORE) && len(scope.Stack.Data()) > 0 {
+		d.touch(addr, common.Hash(scope.Stack.Back(0).Bytes32()))
+	}
+	var stop bool
+	switch d.mode {
+	case modeStep:
+		stop = true
+	case modeNext:
+		stop = depth <= d.depth
+	case modeOut:
+		stop = depth < d.depth
+	}
+	for i, bp := range d.breakpoints {
+		if bp.matches(addr, pc, op, scope.Stack) {
+			fmt.Fprintf(d.out, "Breakpoint %d hit: %v\n", i, bp)
+			stop = true
+		}
+	}
+	// Always stop at instructions failing before execution, e.g. on stack
+	// underflows or out of gas errors
+	if err != nil {
+		fmt.Fprintf(d.out, "Instruction failed: %v\n", err)
+		stop = true
+	}
+	if !stop {
+		return
+	}
+	d.show(env, pc, op, gas, cost, scope, depth)
+	d.repl(env, pc, op, gas, cost, scope, depth)
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (d *Debugger) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if !d.quit {
+		fmt.Fprintf(d.out, "Fault at depth %d, pc %d (%v): %v\n", depth, pc, op, err)
+	}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (d *Debugger) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) {
+	if d.quit {
+		fmt.Fprintln(d.out, "Execution aborted")
+		return
+	}
+	fmt.Fprintf(d.out, "Execution finished, gas used %d, output 0x%x\n", gasUsed, output)
+	if err != nil {
+		fmt.Fprintf(d.out, "Error: %v\n", err)
+	}
+}
+
+// touch marks a storage slot as accessed.
+func (d *Debugger) touch(addr common.Address, key common.Hash) {
+	if _, ok := d.storage[addr]; !ok {
+		d.storage[addr] = make(map[common.Hash]struct{})
+	}
+	d.storage[addr][key] = struct{}{}
+}
+
+// repl prompts the user for commands until one resumes the execution.
+func (d *Debugger) repl(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int) {
+	for {
+		line, err := d.prompt("evm> ")
+		if err != nil {
+			// Input closed, abort the execution
+			d.abort(env)
+			return
+		}
+		if line = strings.TrimSpace(line); line == "" {
+			line = d.last
+		}
+		d.last = line
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch cmd, args := fields[0], fields[1:]; cmd {
+		case "s", "step":
+			d.mode = modeStep
+			return
+		case "n", "next":
+			d.mode, d.depth = modeNext, depth
+			return
+		case "o", "out":
+			d.mode, d.depth = modeOut, depth
+			return
+		case "c", "continue":
+			d.mode = modeContinue
+			return
+		case "q", "quit":
+			d.abort(env)
+			return
+		case "i", "info":
+			d.show(env, pc, op, gas, cost, scope, depth)
+		case "st", "stack":
+			d.showStack(scope.Stack)
+		case "m", "memory":
+			d.showMemory(scope.Memory)
+		case "sto", "storage":
+			d.showStorage(env, scope.Contract.Address())
+		case "b", "break":
+			if err := d.AddBreakpoint(args...); err != nil {
+				fmt.Fprintf(d.out, "Invalid breakpoint: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(d.out, "Breakpoint %d: %v\n", len(d.breakpoints)-1, d.breakpoints[len(d.breakpoints)-1])
+		case "bl", "breakpoints":
+			for i, bp := range d.breakpoints {
+				fmt.Fprintf(d.out, "%d: %v\n", i, bp)
+			}
+		case "d", "delete":
+			index, err := strconv.Atoi(strings.Join(args, ""))
+			if err != nil || index < 0 || index >= len(d.breakpoints) {
+				fmt.Fprintf(d.out, "Invalid breakpoint number %q\n", strings.Join(args, " "))
+				continue
+			}
+			d.breakpoints = append(d.breakpoints[:index], d.breakpoints[index+1:]...)
+		case "h", "help":
+			fmt.Fprint(d.out, help)
+		default:
+			fmt.Fprintf(d.out, "Unknown command %q, type help for the list of commands\n", cmd)
+		}
+	}
+}
+
+// abort stops prompting the user and cancels the execution.
+func (d *Debugger) abort(env *vm.EVM) {
+	d.quit = true
+	env.Cancel()
+}
+
+const help = `Commands:
+  s, step          execute the next instruction, entering calls
+  n, next          execute the next instruction, stepping over calls
+  o, out           run until the current call returns
+  c, continue      run until the next breakpoint
+  i, info          show the current instruction, stack, memory and storage
+  st, stack        show the stack
+  m, memory        show the memory
+  sto, storage     show the storage of the executing contract
+  b, break <bp>    add a breakpoint, one of:
+                     pc <pc> [address]  instruction at the given pc
+                     op <opcode>        any instruction with the given opcode
+                     sstore [slot]      storage writes, optionally to a slot
+  bl, breakpoints  list the breakpoints
+  d, delete <n>    delete a breakpoint
+  q, quit          abort the execution
+An empty line repeats the last command.
+`
+
+// show prints the current instruction along with the stack, memory and storage.
+func (d *Debugger) show(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int) {
+	fmt.Fprintf(d.out, "[depth %d] %x pc %d: %v, gas %d, cost %d\n", depth, scope.Contract.Address(), pc, op, gas, cost)
+	d.showStack(scope.Stack)
+	d.showMemory(scope.Memory)
+	d.showStorage(env, scope.Contract.Address())
+}
+
+// showStack prints the stack, top item first.
+func (d *Debugger) showStack(stack *vm.Stack) {
+	data := stack.Data()
+	fmt.Fprintf(d.out, "Stack (%d items):\n", len(data))
+	for i := len(data) - 1; i >= 0; i-- {
+		fmt.Fprintf(d.out, "  %2d: %#x\n", len(data)-1-i, data[i].ToBig())
+	}
+}
+
+// showMemory prints the memory in rows of 32 bytes.
+func (d *Debugger) showMemory(mem *vm.Memory) {
+	data := mem.Data()
+	fmt.Fprintf(d.out, "Memory (%d bytes):\n", len(data))
+	for offset := 0; offset < len(data); offset += 32 {
+		end := offset + 32
+		if end > len(data) {
+			end = len(data)
+		}
+		fmt.Fprintf(d.out, "  %#06x: %x\n", offset, data[offset:end])
+	}
+}
+
+// showStorage prints the storage slots of the prestate and the ones accessed by
+// the execution of the given contract.
+func (d *Debugger) showStorage(env *vm.EVM, addr common.Address) {
+	keys := make(map[common.Hash]struct{})
+	for key := range d.storage[addr] {
+		keys[key] = struct{}{}
+	}
+	env.StateDB.ForEachStorage(addr, func(key, value common.Hash) bool {
+		keys[key] = struct{}{}
+		return true
+	})
+	sorted := make([]common.Hash, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	fmt.Fprintf(d.out, "Storage (%d slots):\n", len(sorted))
+	for _, key := range sorted {
+		fmt.Fprintf(d.out, "  %x: %x\n", key, env.StateDB.GetState(addr, key))
+	}
+}
+
+// breakpoint is a condition on the next instruction to stop the execution at.
+type breakpoint struct {
+	pc     *uint64         // Program counter of the instruction, nil if any
+	addr   *common.Address // Contract executing the instruction, nil if any
+	op     *vm.OpCode      // Opcode of the instruction, nil if any
+	sstore bool            // Whether to stop at storage writes
+	slot   *common.Hash    // Storage slot written, nil if any
+}
+
+// parseBreakpoint parses the arguments of a break command.
+func parseBreakpoint(args []string) (*breakpoint, error) {
+	if len(args) == 0 {
+		return nil, errors.New("missing breakpoint type")
+	}
+	bp := new(breakpoint)
+	switch kind, args := args[0], args[1:]; kind {
+	case "pc":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, errors.New("usage: pc <pc> [address]")
+		}
+		pc, err := strconv.ParseUint(args[0], 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pc %q", args[0])
+		}
+		bp.pc = &pc
+		if len(args) == 2 {
+			if !common.IsHexAddress(args[1]) {
+				return nil, fmt.Errorf("invalid address %q", args[1])
+			}
+			addr := common.HexToAddress(args[1])
+			bp.addr = &addr
+		}
+	case "op":
+		if len(args) != 1 {
+			return nil, errors.New("usage: op <opcode>")
+		}
+		name := strings.ToUpper(args[0])
+		op := vm.StringToOp(name)
+		if op.String() != name {
+			return nil, fmt.Errorf("unknown opcode %q", args[0])
+		}
+		bp.op = &op
+	case "sstore":
+		if len(args) > 1 {
+			return nil, errors.New("usage: sstore [slot]")
+		}
+		bp.sstore = true
+		if len(args) == 1 {
+			slot, ok := new(big.Int).SetString(args[0], 0)
+			if !ok || slot.Sign() < 0 || slot.BitLen() > 256 {
+				return nil, fmt.Errorf("invalid slot %q", args[0])
+			}
+			hash := common.BigToHash(slot)
+			bp.slot = &hash
+		}
+	default:
+		return nil, fmt.Errorf("unknown breakpoint type %q", kind)
+	}
+	return bp, nil
+}
+
+// matches returns whether the breakpoint is hit by the given instruction.
+func (bp *breakpoint) matches(addr common.Address, pc uint64, op vm.OpCode, stack *vm.Stack) bool {
+	switch {
+	case bp.pc != nil:
+		return *bp.pc == pc && (bp.addr == nil || *bp.addr == addr)
+	case bp.op != nil:
+		return *bp.op == op
+	case bp.sstore:
+		if op != vm.SSTORE {
+			return false
+		}
+		return bp.slot == nil || (len(stack.Data()) > 0 && common.Hash(stack.Back(0).Bytes32()) == *bp.slot)
+	}
+	return false
+}
+
+// String implements fmt.Stringer, returning the breakpoint in the syntax of the
+// break command.
+func (bp *breakpoint) String() string {
+	switch {
+	case bp.pc != nil && bp.addr != nil:
+		return fmt.Sprintf("pc %d %x", *bp.pc, *bp.addr)
+	case bp.pc != nil:
+		return fmt.Sprintf("pc %d", *bp.pc)
+	case bp.op != nil:
+		return fmt.Sprintf("op %v", *bp.op)
+	case bp.slot != nil:
+		return fmt.Sprintf("sstore %#x", bp.slot.Big())
+	default:
+		return "sstore"
+	}
+}
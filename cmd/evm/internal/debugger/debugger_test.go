@@ -0,0 +1,211 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package debugger
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+)
+
+var (
+	calleeAddr = common.HexToAddress("0xbb")
+
+	// callee stores 1 at slot 0
+	calleeCode = common.FromHex("6001600055" + "00")
+
+	// caller calls the callee with no input and value, then stops:
+	//   0: PUSH1 0 ... 10: PUSH1 0xbb, 12: GAS, 13: CALL, 14: POP, 15: STOP
+	callerCode = common.FromHex("6000600060006000600060bb5af15000")
+)
+
+var stopRegexp = regexp.MustCompile(`\[depth (\d+)\] [0-9a-f]+ pc (\d+)`)
+
+// debug runs the caller code with the debugger fed the given commands, returning
+// the instructions it stopped at as "depth:pc" along with its output.
+func debug(t *testing.T, breakpoints []string, commands ...string) ([]string, string) {
+	t.Helper()
+
+	var (
+		out   = new(bytes.Buffer)
+		stops []string
+	)
+	prompt := func(string) (string, error) {
+		// Record the last instruction shown before prompting
+		if m := stopRegexp.FindAllStringSubmatch(out.String(), -1); len(m) > 0 {
+			last := m[len(m)-1]
+			stops = append(stops, last[1]+":"+last[2])
+		}
+		if len(commands) == 0 {
+			return "", io.EOF
+		}
+		cmd := commands[0]
+		commands = commands[1:]
+		return cmd, nil
+	}
+	dbg := New(prompt, out)
+	for _, bp := range breakpoints {
+		if err := dbg.AddBreakpoint(strings.Fields(bp)...); err != nil {
+			t.Fatalf("failed to add breakpoint %q: %v", bp, err)
+		}
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(calleeAddr, calleeCode)
+
+	runtime.Execute(callerCode, nil, &runtime.Config{
+		State:     statedb,
+		EVMConfig: vm.Config{Debug: true, Tracer: dbg},
+	})
+	return stops, out.String()
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		stops    []string
+	}{
+		{
+			name:     "step into call",
+			commands: []string{"b pc 13", "c", "s", "s", "c"},
+			stops:    []string{"1:0", "1:0", "1:13", "2:0", "2:2"},
+		},
+		{
+			name:     "step over call",
+			commands: []string{"b pc 13", "c", "n", "", "c"},
+			stops:    []string{"1:0", "1:0", "1:13", "1:14", "1:15"},
+		},
+		{
+			name:     "step out of call",
+			commands: []string{"b pc 0 0x00000000000000000000000000000000000000bb", "c", "s", "o", "c"},
+			stops:    []string{"1:0", "1:0", "2:0", "2:2", "1:14"},
+		},
+		{
+			name:     "next at depth",
+			commands: []string{"b op SSTORE", "c", "n", "n", "c"},
+			stops:    []string{"1:0", "1:0", "2:4", "2:5", "1:14"},
+		},
+	}
+	for _, tt := range tests {
+		stops, out := debug(t, nil, tt.commands...)
+		if !reflect.DeepEqual(stops, tt.stops) {
+			t.Errorf("%s: stops mismatch: have %v, want %v\n%s", tt.name, stops, tt.stops, out)
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	tests := []struct {
+		breakpoints []string
+		stops       []string
+	}{
+		{[]string{"pc 14"}, []string{"1:0", "1:14"}},
+		{[]string{"pc 0x2"}, []string{"1:0", "1:2", "2:2"}},
+		{[]string{"pc 2 0x00000000000000000000000000000000000000bb"}, []string{"1:0", "2:2"}},
+		{[]string{"op GAS"}, []string{"1:0", "1:12"}},
+		{[]string{"sstore"}, []string{"1:0", "2:4"}},
+		{[]string{"sstore 0"}, []string{"1:0", "2:4"}},
+		{[]string{"sstore 1"}, []string{"1:0"}},
+		{[]string{"op GAS", "pc 14"}, []string{"1:0", "1:12", "1:14"}},
+	}
+	for i, tt := range tests {
+		stops, out := debug(t, tt.breakpoints, "c", "c", "c", "c")
+		if !reflect.DeepEqual(stops, tt.stops) {
+			t.Errorf("test %d: stops mismatch: have %v, want %v\n%s", i, stops, tt.stops, out)
+		}
+	}
+}
+
+func TestDeleteBreakpoint(t *testing.T) {
+	stops, out := debug(t, []string{"op GAS", "pc 14"}, "d 0", "bl", "c")
+	if want := []string{"1:0", "1:0", "1:0", "1:14"}; !reflect.DeepEqual(stops, want) {
+		t.Errorf("stops mismatch: have %v, want %v\n%s", stops, want, out)
+	}
+	if !strings.Contains(out, "0: pc 14\n") {
+		t.Errorf("breakpoint list missing from output:\n%s", out)
+	}
+}
+
+func TestStorageDisplay(t *testing.T) {
+	_, out := debug(t, []string{"sstore"}, "c", "s", "sto", "c")
+
+	want := "Storage (1 slots):\n" +
+		"  0000000000000000000000000000000000000000000000000000000000000000: 0000000000000000000000000000000000000000000000000000000000000001\n"
+	if !strings.Contains(out, want) {
+		t.Errorf("written slot missing from output:\n%s", out)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	for _, commands := range [][]string{{"s", "q"}, {"s"}} {
+		stops, out := debug(t, []string{"op GAS"}, commands...)
+		if want := []string{"1:0", "1:2"}; !reflect.DeepEqual(stops, want) {
+			t.Errorf("%v: stops mismatch: have %v, want %v\n%s", commands, stops, want, out)
+		}
+		if !strings.HasSuffix(out, "Execution aborted\n") {
+			t.Errorf("%v: execution not aborted:\n%s", commands, out)
+		}
+	}
+}
+
+func TestParseBreakpoint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "pc 18", want: "pc 18"},
+		{input: "pc 0x12 0x00000000000000000000000000000000000000bb", want: "pc 18 00000000000000000000000000000000000000bb"},
+		{input: "op sstore", want: "op SSTORE"},
+		{input: "sstore", want: "sstore"},
+		{input: "sstore 0x10", want: "sstore 0x10"},
+		{input: "", err: true},
+		{input: "pc", err: true},
+		{input: "pc foo", err: true},
+		{input: "pc 1 0x12", err: true},
+		{input: "op FOO", err: true},
+		{input: "op", err: true},
+		{input: "sstore -1", err: true},
+		{input: "sstore 1 2", err: true},
+		{input: "call", err: true},
+	}
+	for _, tt := range tests {
+		bp, err := parseBreakpoint(strings.Fields(tt.input))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", tt.input, bp)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if bp.String() != tt.want {
+			t.Errorf("%q: have %q, want %q", tt.input, bp.String(), tt.want)
+		}
+	}
+}
@@ -183,6 +183,7 @@ func init() {
 	}
 	app.Commands = []cli.Command{
 		compileCommand,
+		debugCommand,
 		disasmCommand,
 		runCommand,
 		stateTestCommand,
@@ -103,35 +103,26 @@ func timedExec(bench bool, execFunc func() ([]byte, uint64, error)) (output []by
 	return output, gasLeft, stats, err
 }
 
-func runCmd(ctx *cli.Context) error {
-	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
-	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
-	log.Root().SetHandler(glogger)
-	logconfig := &vm.LogConfig{
-		DisableMemory:     ctx.GlobalBool(DisableMemoryFlag.Name),
-		DisableStack:      ctx.GlobalBool(DisableStackFlag.Name),
-		DisableStorage:    ctx.GlobalBool(DisableStorageFlag.Name),
-		DisableReturnData: ctx.GlobalBool(DisableReturnDataFlag.Name),
-		Debug:             ctx.GlobalBool(DebugFlag.Name),
-	}
+// runEnv is the execution environment assembled from the command line flags,
+// shared by the run and debug commands.
+type runEnv struct {
+	config   runtime.Config
+	statedb  *state.StateDB
+	receiver common.Address
+	code     []byte
+	input    []byte
+}
 
+// newRunEnv assembles the execution environment from the command line flags,
+// tracing the execution with the given tracer if debug is set.
+func newRunEnv(ctx *cli.Context, tracer vm.Tracer, debug bool) (*runEnv, error) {
 	var (
-		tracer        vm.Tracer
-		debugLogger   *vm.StructLogger
 		statedb       *state.StateDB
 		chainConfig   *params.ChainConfig
 		sender        = common.BytesToAddress([]byte("sender"))
 		receiver      = common.BytesToAddress([]byte("receiver"))
 		genesisConfig *core.Genesis
 	)
-	if ctx.GlobalBool(MachineFlag.Name) {
-		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
-	} else if ctx.GlobalBool(DebugFlag.Name) {
-		debugLogger = vm.NewStructLogger(logconfig)
-		tracer = debugLogger
-	} else {
-		debugLogger = vm.NewStructLogger(logconfig)
-	}
 	if ctx.GlobalString(GenesisFlag.Name) != "" {
 		gen := readGenesis(ctx.GlobalString(GenesisFlag.Name))
 		genesisConfig = gen
@@ -188,11 +179,11 @@ func runCmd(ctx *cli.Context) error {
 		// EASM-file to compile
 		src, err := ioutil.ReadFile(fn)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		bin, err := compiler.Compile(fn, src, false)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		code = common.Hex2Bytes(bin)
 	}
@@ -212,23 +203,9 @@ func runCmd(ctx *cli.Context) error {
 		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
 		EVMConfig: vm.Config{
 			Tracer: tracer,
-			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Debug:  debug,
 		},
 	}
-
-	if cpuProfilePath := ctx.GlobalString(CPUProfileFlag.Name); cpuProfilePath != "" {
-		f, err := os.Create(cpuProfilePath)
-		if err != nil {
-			fmt.Println("could not create CPU profile: ", err)
-			os.Exit(1)
-		}
-		if err := pprof.StartCPUProfile(f); err != nil {
-			fmt.Println("could not start CPU profile: ", err)
-			os.Exit(1)
-		}
-		defer pprof.StopCPUProfile()
-	}
-
 	if chainConfig != nil {
 		runtimeConfig.ChainConfig = chainConfig
 	} else {
@@ -247,21 +224,76 @@ func runCmd(ctx *cli.Context) error {
 	}
 	input := common.FromHex(string(bytes.TrimSpace(hexInput)))
 
-	var execFunc func() ([]byte, uint64, error)
-	if ctx.GlobalBool(CreateFlag.Name) {
-		input = append(code, input...)
-		execFunc = func() ([]byte, uint64, error) {
-			output, _, gasLeft, err := runtime.Create(input, &runtimeConfig)
+	return &runEnv{
+		config:   runtimeConfig,
+		statedb:  statedb,
+		receiver: receiver,
+		code:     code,
+		input:    input,
+	}, nil
+}
+
+// execFunc returns a function executing the code, either deploying it along with
+// the input or calling it at the receiver address.
+func (env *runEnv) execFunc(create bool) func() ([]byte, uint64, error) {
+	if create {
+		input := append(env.code, env.input...)
+		return func() ([]byte, uint64, error) {
+			output, _, gasLeft, err := runtime.Create(input, &env.config)
 			return output, gasLeft, err
 		}
+	}
+	if len(env.code) > 0 {
+		env.statedb.SetCode(env.receiver, env.code)
+	}
+	return func() ([]byte, uint64, error) {
+		return runtime.Call(env.receiver, env.input, &env.config)
+	}
+}
+
+func runCmd(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+	logconfig := &vm.LogConfig{
+		DisableMemory:     ctx.GlobalBool(DisableMemoryFlag.Name),
+		DisableStack:      ctx.GlobalBool(DisableStackFlag.Name),
+		DisableStorage:    ctx.GlobalBool(DisableStorageFlag.Name),
+		DisableReturnData: ctx.GlobalBool(DisableReturnDataFlag.Name),
+		Debug:             ctx.GlobalBool(DebugFlag.Name),
+	}
+
+	var (
+		tracer      vm.Tracer
+		debugLogger *vm.StructLogger
+	)
+	if ctx.GlobalBool(MachineFlag.Name) {
+		tracer = vm.NewJSONLogger(logconfig, os.Stdout)
+	} else if ctx.GlobalBool(DebugFlag.Name) {
+		debugLogger = vm.NewStructLogger(logconfig)
+		tracer = debugLogger
 	} else {
-		if len(code) > 0 {
-			statedb.SetCode(receiver, code)
+		debugLogger = vm.NewStructLogger(logconfig)
+	}
+	env, err := newRunEnv(ctx, tracer, ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name))
+	if err != nil {
+		return err
+	}
+	statedb, initialGas := env.statedb, env.config.GasLimit
+
+	if cpuProfilePath := ctx.GlobalString(CPUProfileFlag.Name); cpuProfilePath != "" {
+		f, err := os.Create(cpuProfilePath)
+		if err != nil {
+			fmt.Println("could not create CPU profile: ", err)
+			os.Exit(1)
 		}
-		execFunc = func() ([]byte, uint64, error) {
-			return runtime.Call(receiver, input, &runtimeConfig)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fmt.Println("could not start CPU profile: ", err)
+			os.Exit(1)
 		}
+		defer pprof.StopCPUProfile()
 	}
+	execFunc := env.execFunc(ctx.GlobalBool(CreateFlag.Name))
 
 	bench := ctx.GlobalBool(BenchFlag.Name)
 	output, leftOverGas, stats, err := timedExec(bench, execFunc)
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"runtime"
 	"strconv"
@@ -33,7 +34,9 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -176,6 +179,40 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 This command dumps out the state for a given block (or latest, if none provided).
 `,
 	}
+	profileChainCommand = cli.Command{
+		Action:    utils.MigrateFlags(profileChain),
+		Name:      "profile-chain",
+		Usage:     "Re-execute a range of blocks and profile the EVM",
+		ArgsUsage: "<blockNumFirst> <blockNumLast>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			profileJSONFlag,
+			profilePprofFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The profile-chain command re-executes the transactions of the given block range
+and aggregates the gas usage, execution time and execution count per opcode, per
+precompile and per contract code hash. The state of the block preceding the range
+needs to be available.
+
+The profile is written as JSON to the standard output, or into the file given by
+--profile.json. Optionally, a pprof compatible profile is written into the file
+given by --profile.pprof.`,
+	}
+)
+
+var (
+	profileJSONFlag = cli.StringFlag{
+		Name:  "profile.json",
+		Usage: "File to write the JSON profile into (default = stdout)",
+	}
+	profilePprofFlag = cli.StringFlag{
+		Name:  "profile.pprof",
+		Usage: "File to write the pprof profile into",
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -465,6 +502,81 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// profileChain re-executes a range of blocks with the profiling tracer.
+func profileChain(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	first, ferr := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	last, lerr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if ferr != nil || lerr != nil {
+		utils.Fatalf("Profile error in parsing parameters: block number not an integer\n")
+	}
+	if first == 0 || first > last {
+		utils.Fatalf("Profile error: invalid block range #%d-#%d\n", first, last)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	if head := chain.CurrentBlock(); last > head.NumberU64() {
+		utils.Fatalf("Profile error: block number %d larger than head block %d\n", last, head.NumberU64())
+	}
+	parent := chain.GetBlockByNumber(first - 1)
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		utils.Fatalf("Profile error: state of block #%d unavailable: %v\n", parent.NumberU64(), err)
+	}
+	var (
+		profiler = tracers.NewProfiler()
+		config   = vm.Config{Debug: true, Tracer: profiler}
+		start    = time.Now()
+		logged   = time.Now()
+	)
+	for number := first; number <= last; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			utils.Fatalf("Profile error: block #%d not found\n", number)
+		}
+		if _, _, _, err := chain.Processor().Process(block, statedb, config); err != nil {
+			utils.Fatalf("Profile error: processing block #%d failed: %v\n", number, err)
+		}
+		if root := statedb.IntermediateRoot(chain.Config().IsEIP158(block.Number())); root != block.Root() {
+			utils.Fatalf("Profile error: state root mismatch in block #%d: have %x, want %x\n", number, root, block.Root())
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Profiling chain segment", "start", first, "end", last, "current", number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Profiled chain segment", "start", first, "end", last, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Write out the JSON and the optional pprof profiles
+	out, err := json.MarshalIndent(&tracers.BlockProfileResult{From: first, To: last, ProfileResult: profiler.Result()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path := ctx.String(profileJSONFlag.Name); path != "" {
+		if err := ioutil.WriteFile(path, out, 0644); err != nil {
+			utils.Fatalf("Failed to write JSON profile: %v\n", err)
+		}
+	} else {
+		fmt.Println(string(out))
+	}
+	if path := ctx.String(profilePprofFlag.Name); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			utils.Fatalf("Failed to create pprof profile: %v\n", err)
+		}
+		defer f.Close()
+
+		if err := profiler.WritePprof(f); err != nil {
+			utils.Fatalf("Failed to write pprof profile: %v\n", err)
+		}
+	}
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
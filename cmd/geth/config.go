@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"os"
 	"reflect"
+	"time"
 	"unicode"
 
 	"gopkg.in/urfave/cli.v1"
@@ -161,6 +162,23 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 			utils.Fatalf("%v", err)
 		}
 	}
+	// Configure the catalyst beacon simulator for developer mode.
+	if ctx.GlobalBool(utils.DeveloperCatalystFlag.Name) {
+		if !ctx.GlobalBool(utils.DeveloperFlag.Name) {
+			utils.Fatalf("Catalyst beacon simulator requires developer mode (--dev).")
+		}
+		if eth == nil {
+			utils.Fatalf("Catalyst does not work in light client mode.")
+		}
+		config := catalyst.SimulatorConfig{
+			Period:      time.Duration(ctx.GlobalInt(utils.DeveloperPeriodFlag.Name)) * time.Second,
+			OnlyPending: ctx.GlobalBool(utils.DeveloperCatalystPendingFlag.Name),
+			FinalityLag: ctx.GlobalUint64(utils.DeveloperCatalystFinalityLagFlag.Name),
+		}
+		if err := catalyst.RegisterSimulator(stack, eth, config); err != nil {
+			utils.Fatalf("%v", err)
+		}
+	}
 
 	// Configure GraphQL if requested
 	if ctx.GlobalIsSet(utils.GraphQLEnabledFlag.Name) {
@@ -27,6 +27,8 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -98,6 +100,25 @@ func randSlice(min, max uint32) []byte {
 	return out
 }
 
+// Tests that catalyst blocks get a difficulty of one and no block rewards.
+func TestCatalyst(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.CatalystBlock = big.NewInt(0)
+
+	parent := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1)}
+	if diff := CalcDifficulty(&config, 10, parent); diff.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("difficulty mismatch: have %v, want %v", diff, 1)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	header := &types.Header{Number: big.NewInt(2), Coinbase: common.Address{1}}
+	accumulateRewards(&config, statedb, header, []*types.Header{{Number: big.NewInt(1), Coinbase: common.Address{2}}})
+	for _, addr := range []common.Address{header.Coinbase, {2}} {
+		if balance := statedb.GetBalance(addr); balance.Sign() != 0 {
+			t.Errorf("account %x rewarded: %v", addr, balance)
+		}
+	}
+}
+
 func TestDifficultyCalculators(t *testing.T) {
 	rand.Seed(2)
 	for i := 0; i < 5000; i++ {
@@ -0,0 +1,399 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package transition implements a consensus engine wrapper that hands block
+// production over from a legacy engine to an external consensus client.
+package transition
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Catalyst protocol constants.
+var (
+	catalystDifficulty        = common.Big0        // The difficulty of all blocks after the transition
+	genesisCatalystDifficulty = common.Big1        // The difficulty of all blocks of chains on catalyst since genesis
+	catalystNonce             = types.BlockNonce{} // The nonce of all blocks after the transition
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errInvalidDifficulty is returned if the difficulty of a block after the
+	// transition is not zero (one on chains on catalyst since genesis).
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidNonce is returned if the nonce of a block after the transition
+	// is not empty.
+	errInvalidNonce = errors.New("invalid nonce")
+
+	// errInvalidUncleHash is returned if a block after the transition contains
+	// a non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errTooManyUncles is returned if a block after the transition includes
+	// uncles.
+	errTooManyUncles = errors.New("uncles not allowed")
+
+	// errOlderBlockTime is returned if a block's timestamp is not newer than
+	// its parent's.
+	errOlderBlockTime = errors.New("timestamp older than parent")
+)
+
+// Engine is a consensus engine that follows the rules of a legacy engine (ethash
+// or clique) until the catalyst transition, and the rules of consensus driven by
+// an external client after it. The transition happens at the configured catalyst
+// block, or once the total difficulty of the chain reaches the configured
+// catalyst total difficulty, whichever comes first.
+//
+// After the transition, blocks carry no difficulty, nonce or uncles, and are
+// neither sealed nor rewarded: the external client chooses the canonical chain.
+type Engine struct {
+	legacy consensus.Engine // Consensus engine used before the transition
+}
+
+// New creates a transition engine wrapping the given legacy engine.
+func New(legacy consensus.Engine) *Engine {
+	return &Engine{legacy: legacy}
+}
+
+// InnerEngine returns the legacy engine used before the transition.
+func (e *Engine) InnerEngine() consensus.Engine {
+	return e.legacy
+}
+
+// IsPostTransition reports whether the child of the given parent header is past
+// the catalyst transition, either by block number or by total difficulty.
+func IsPostTransition(chain consensus.ChainHeaderReader, parent *types.Header) bool {
+	return isPostTransition(chain.Config(), parent, chain.GetTd(parent.Hash(), parent.Number.Uint64()))
+}
+
+// isPostTransition reports whether the child of the given parent is past the
+// catalyst transition, given the parent's total difficulty (nil if unknown).
+func isPostTransition(config *params.ChainConfig, parent *types.Header, parentTd *big.Int) bool {
+	if config.IsCatalyst(new(big.Int).Add(parent.Number, common.Big1)) {
+		return true
+	}
+	if config.CatalystTotalDifficulty == nil || parentTd == nil {
+		return false
+	}
+	return parentTd.Cmp(config.CatalystTotalDifficulty) >= 0
+}
+
+// postTransitionDifficulty returns the difficulty of the blocks after the
+// transition. Chains on catalyst since genesis keep the difficulty of one their
+// blocks were always created with.
+func postTransitionDifficulty(config *params.ChainConfig) *big.Int {
+	if config.CatalystBlock != nil && config.CatalystBlock.Sign() == 0 {
+		return genesisCatalystDifficulty
+	}
+	return catalystDifficulty
+}
+
+// Author implements consensus.Engine, returning the header's coinbase after the
+// transition and delegating to the legacy engine before it.
+func (e *Engine) Author(header *types.Header) (common.Address, error) {
+	if header.Difficulty != nil && header.Difficulty.Cmp(catalystDifficulty) == 0 {
+		return header.Coinbase, nil
+	}
+	return e.legacy.Author(header)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules of the
+// engine active at its height.
+func (e *Engine) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	// Short circuit if the header is known, or its parent not
+	number := header.Number.Uint64()
+	if chain.GetHeader(header.Hash(), number) != nil {
+		return nil
+	}
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	if !IsPostTransition(chain, parent) {
+		return e.legacy.VerifyHeader(chain, header, seal)
+	}
+	return e.verifyHeader(chain, header, parent)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
+// concurrently. The headers before the transition are handed to the legacy
+// engine, the ones after it are verified sequentially once those completed.
+// The method returns a quit channel to abort the operations and a results
+// channel to retrieve the async verifications.
+func (e *Engine) VerifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	split := e.splitHeaders(chain, headers)
+	if split == 0 {
+		return e.verifyHeaders(chain, headers, nil)
+	}
+	if split == len(headers) {
+		return e.legacy.VerifyHeaders(chain, headers, seals)
+	}
+	var (
+		abort   = make(chan struct{})
+		results = make(chan error, len(headers))
+	)
+	go func() {
+		legacyAbort, legacyResults := e.legacy.VerifyHeaders(chain, headers[:split], seals[:split])
+		defer close(legacyAbort)
+
+		for i := 0; i < split; i++ {
+			select {
+			case err := <-legacyResults:
+				results <- err
+			case <-abort:
+				return
+			}
+		}
+		postAbort, postResults := e.verifyHeaders(chain, headers[split:], headers[split-1])
+		defer close(postAbort)
+
+		for i := split; i < len(headers); i++ {
+			select {
+			case err := <-postResults:
+				results <- err
+			case <-abort:
+				return
+			}
+		}
+	}()
+	return abort, results
+}
+
+// splitHeaders returns the index of the first header in the batch which is past
+// the transition, or the length of the batch if there's none. The total
+// difficulty is tracked across the batch as it's not yet available in the chain.
+func (e *Engine) splitHeaders(chain consensus.ChainHeaderReader, headers []*types.Header) int {
+	if len(headers) == 0 {
+		return 0
+	}
+	var (
+		config = chain.Config()
+		parent = chain.GetHeader(headers[0].ParentHash, headers[0].Number.Uint64()-1)
+		td     *big.Int
+	)
+	if parent == nil {
+		// Unknown ancestor, let the legacy engine report it
+		return len(headers)
+	}
+	if ptd := chain.GetTd(parent.Hash(), parent.Number.Uint64()); ptd != nil {
+		td = new(big.Int).Set(ptd)
+	}
+	for i, header := range headers {
+		if isPostTransition(config, parent, td) {
+			return i
+		}
+		if td != nil {
+			td.Add(td, header.Difficulty)
+		}
+		parent = header
+	}
+	return len(headers)
+}
+
+// verifyHeaders verifies a batch of post-transition headers sequentially. The
+// parent of the first header is either given or looked up from the chain.
+func (e *Engine) verifyHeaders(chain consensus.ChainHeaderReader, headers []*types.Header, parent *types.Header) (chan<- struct{}, <-chan error) {
+	var (
+		abort   = make(chan struct{})
+		results = make(chan error, len(headers))
+	)
+	go func() {
+		for i, header := range headers {
+			var err error
+			switch {
+			case i > 0 && headers[i-1].Hash() == header.ParentHash:
+				parent = headers[i-1]
+			case i > 0:
+				parent = nil
+			case parent == nil:
+				parent = chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+			}
+			if parent == nil {
+				err = consensus.ErrUnknownAncestor
+			} else {
+				err = e.verifyHeader(chain, header, parent)
+			}
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules after
+// the transition. The timestamp is not checked against the local clock, as
+// block production is driven by the external consensus client.
+func (e *Engine) verifyHeader(chain consensus.ChainHeaderReader, header, parent *types.Header) error {
+	// Ensure that the header's extra-data section is of a reasonable size
+	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
+		return fmt.Errorf("extra-data too long: %d > %d", len(header.Extra), params.MaximumExtraDataSize)
+	}
+	if header.Time <= parent.Time {
+		return errOlderBlockTime
+	}
+	// Verify the fields the external consensus client made redundant
+	if want := postTransitionDifficulty(chain.Config()); header.Difficulty == nil || header.Difficulty.Cmp(want) != 0 {
+		return fmt.Errorf("%w: have %v, want %v", errInvalidDifficulty, header.Difficulty, want)
+	}
+	if header.Nonce != catalystNonce {
+		return errInvalidNonce
+	}
+	if header.UncleHash != types.EmptyUncleHash {
+		return errInvalidUncleHash
+	}
+	// Verify that the gas limit is <= 2^63-1
+	cap := uint64(0x7fffffffffffffff)
+	if header.GasLimit > cap {
+		return fmt.Errorf("invalid gasLimit: have %v, max %v", header.GasLimit, cap)
+	}
+	// Verify that the gasUsed is <= gasLimit
+	if header.GasUsed > header.GasLimit {
+		return fmt.Errorf("invalid gasUsed: have %d, gasLimit %d", header.GasUsed, header.GasLimit)
+	}
+	// Verify the block's gas usage and (if applicable) verify the base fee.
+	if !chain.Config().IsLondon(header.Number) {
+		// Verify BaseFee not present before EIP-1559 fork.
+		if header.BaseFee != nil {
+			return fmt.Errorf("invalid baseFee before fork: have %d, expected 'nil'", header.BaseFee)
+		}
+		if err := misc.VerifyGaslimit(parent.GasLimit, header.GasLimit); err != nil {
+			return err
+		}
+	} else if err := misc.VerifyEip1559Header(chain.Config(), parent, header); err != nil {
+		// Verify the header's EIP-1559 attributes.
+		return err
+	}
+	// Verify that the block number is parent's +1
+	if diff := new(big.Int).Sub(header.Number, parent.Number); diff.Cmp(common.Big1) != 0 {
+		return consensus.ErrInvalidNumber
+	}
+	// If all checks passed, validate any special fields for hard forks
+	return misc.VerifyForkHashes(chain.Config(), header, false)
+}
+
+// VerifyUncles verifies that the given block's uncles conform to the consensus
+// rules of the engine active at its height. Blocks after the transition can't
+// include any uncles.
+func (e *Engine) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if !e.isPostTransitionHeader(chain, block.Header()) {
+		return e.legacy.VerifyUncles(chain, block)
+	}
+	if len(block.Uncles()) > 0 {
+		return errTooManyUncles
+	}
+	return nil
+}
+
+// isPostTransitionHeader reports whether the given header is past the transition,
+// based on its parent in the chain.
+func (e *Engine) isPostTransitionHeader(chain consensus.ChainHeaderReader, header *types.Header) bool {
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return chain.Config().IsCatalyst(header.Number)
+	}
+	return IsPostTransition(chain, parent)
+}
+
+// Prepare implements consensus.Engine, initializing the difficulty field of a
+// header after the transition, or delegating to the legacy engine before it.
+func (e *Engine) Prepare(chain consensus.ChainHeaderReader, header *types.Header) error {
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		// Generated chains have no access to their ancestors, use the block number only
+		if !chain.Config().IsCatalyst(header.Number) {
+			return e.legacy.Prepare(chain, header)
+		}
+	} else if !IsPostTransition(chain, parent) {
+		return e.legacy.Prepare(chain, header)
+	}
+	header.Difficulty = new(big.Int).Set(postTransitionDifficulty(chain.Config()))
+	return nil
+}
+
+// Finalize implements consensus.Engine, setting the final state on the header.
+// No block or uncle rewards are issued after the transition.
+func (e *Engine) Finalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header) {
+	if !e.isPostTransitionHeader(chain, header) {
+		e.legacy.Finalize(chain, header, state, txs, uncles)
+		return
+	}
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+}
+
+// FinalizeAndAssemble implements consensus.Engine, setting the final state and
+// assembling the block. Uncles are dropped after the transition.
+func (e *Engine) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if !e.isPostTransitionHeader(chain, header) {
+		return e.legacy.FinalizeAndAssemble(chain, header, state, txs, uncles, receipts)
+	}
+	e.Finalize(chain, header, state, txs, nil)
+	return types.NewBlock(header, txs, nil, receipts, trie.NewStackTrie(nil)), nil
+}
+
+// Seal implements consensus.Engine. Blocks after the transition are sealed by
+// the external consensus client, so they are accepted as they are without
+// pushing anything back.
+func (e *Engine) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	if !e.isPostTransitionHeader(chain, block.Header()) {
+		return e.legacy.Seal(chain, block, results, stop)
+	}
+	return nil
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (e *Engine) SealHash(header *types.Header) common.Hash {
+	return e.legacy.SealHash(header)
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have, which is zero after the transition (one on
+// chains on catalyst since genesis).
+func (e *Engine) CalcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header) *big.Int {
+	if !IsPostTransition(chain, parent) {
+		return e.legacy.CalcDifficulty(chain, time, parent)
+	}
+	return new(big.Int).Set(postTransitionDifficulty(chain.Config()))
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC APIs of the
+// legacy engine.
+func (e *Engine) APIs(chain consensus.ChainHeaderReader) []rpc.API {
+	return e.legacy.APIs(chain)
+}
+
+// Close implements consensus.Engine, terminating the legacy engine.
+func (e *Engine) Close() error {
+	return e.legacy.Close()
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package transition
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestChain generates a chain of n blocks transitioning to catalyst at the
+// given block number, and a blockchain containing only the genesis block using
+// the given chain config.
+func newTestChain(t *testing.T, config *params.ChainConfig, transition int64, n int) (*core.BlockChain, []*types.Block) {
+	genconfig := *config
+	genconfig.CatalystBlock = big.NewInt(transition)
+	genconfig.CatalystTotalDifficulty = nil
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		engine  = New(ethash.NewFaker())
+		genspec = &core.Genesis{Config: config, BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = genspec.MustCommit(db)
+	)
+	blocks, _ := core.GenerateChain(&genconfig, genesis, engine, rawdb.NewMemoryDatabase(), n, func(i int, b *core.BlockGen) {
+		b.OffsetTime(5)
+	})
+	chain, err := core.NewBlockChain(db, nil, config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	return chain, blocks
+}
+
+func testChainConfig() *params.ChainConfig {
+	config := *params.AllEthashProtocolChanges
+	return &config
+}
+
+// Tests that the engine delegates to the legacy engine up to the configured
+// catalyst block, and produces and accepts zero difficulty blocks after it.
+func TestTransitionByNumber(t *testing.T) {
+	config := testChainConfig()
+	config.CatalystBlock = big.NewInt(5)
+
+	chain, blocks := newTestChain(t, config, 5, 10)
+	defer chain.Stop()
+
+	for _, block := range blocks {
+		if post := block.NumberU64() >= 5; post != (block.Difficulty().Sign() == 0) {
+			t.Errorf("block %d: difficulty mismatch: have %v, post-transition %v", block.NumberU64(), block.Difficulty(), post)
+		}
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 10 {
+		t.Fatalf("head mismatch: have %d, want %d", head, 10)
+	}
+}
+
+// Tests that chains on catalyst since genesis keep producing and accepting blocks
+// of difficulty one, matching the legacy ethash catalyst mode.
+func TestTransitionAtGenesis(t *testing.T) {
+	config := testChainConfig()
+	config.CatalystBlock = big.NewInt(0)
+
+	chain, blocks := newTestChain(t, config, 0, 5)
+	defer chain.Stop()
+
+	for _, block := range blocks {
+		if block.Difficulty().Cmp(common.Big1) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", block.NumberU64(), block.Difficulty(), common.Big1)
+		}
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	parent := chain.CurrentBlock().Header()
+	if diff := ethash.CalcDifficulty(config, parent.Time+1, parent); diff.Cmp(common.Big1) != 0 {
+		t.Errorf("ethash difficulty mismatch: have %v, want %v", diff, common.Big1)
+	}
+	header := postTransitionHeader(config, parent)
+	if err := New(ethash.NewFaker()).VerifyHeader(chain, header, true); !errors.Is(err, errInvalidDifficulty) {
+		t.Errorf("zero difficulty error mismatch: have %v, want %v", err, errInvalidDifficulty)
+	}
+}
+
+// Tests that the engine switches over once the configured total difficulty is
+// reached, both when verifying headers in a batch and one by one.
+func TestTransitionByTotalDifficulty(t *testing.T) {
+	config := testChainConfig()
+	chain, blocks := newTestChain(t, config, 5, 10)
+	defer chain.Stop()
+
+	// Import the legacy blocks to find out the transition difficulty, then
+	// roll back and import the whole chain in a single batch
+	if n, err := chain.InsertChain(blocks[:4]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	config.CatalystTotalDifficulty = chain.GetTd(blocks[3].Hash(), 4)
+	if err := chain.SetHead(0); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 10 {
+		t.Fatalf("head mismatch: have %d, want %d", head, 10)
+	}
+	// A zero difficulty block before reaching the total difficulty is invalid
+	engine := New(ethash.NewFaker())
+	header := postTransitionHeader(config, blocks[2].Header())
+	if err := engine.VerifyHeader(chain, header, true); err == nil {
+		t.Fatalf("pre-transition zero difficulty header accepted")
+	}
+	header = postTransitionHeader(config, blocks[3].Header())
+	if err := engine.VerifyHeader(chain, header, true); err != nil {
+		t.Fatalf("post-transition header rejected: %v", err)
+	}
+}
+
+// postTransitionHeader creates a valid post-transition child of the given header.
+func postTransitionHeader(config *params.ChainConfig, parent *types.Header) *types.Header {
+	return &types.Header{
+		ParentHash: parent.Hash(),
+		UncleHash:  types.EmptyUncleHash,
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		Difficulty: new(big.Int),
+		GasLimit:   parent.GasLimit,
+		Time:       parent.Time + 1,
+		BaseFee:    misc.CalcBaseFee(config, parent),
+	}
+}
+
+// Tests that post-transition headers are rejected if they carry difficulty, a
+// nonce or uncles.
+func TestVerifyPostTransitionHeader(t *testing.T) {
+	config := testChainConfig()
+	config.CatalystBlock = big.NewInt(1)
+
+	chain, blocks := newTestChain(t, config, 1, 1)
+	defer chain.Stop()
+
+	var (
+		engine = New(ethash.NewFaker())
+		parent = chain.Genesis().Header()
+	)
+	if err := engine.VerifyHeader(chain, blocks[0].Header(), true); err != nil {
+		t.Fatalf("generated header rejected: %v", err)
+	}
+	tests := []struct {
+		mutate func(*types.Header)
+		err    error
+	}{
+		{func(h *types.Header) {}, nil},
+		{func(h *types.Header) { h.Difficulty = big.NewInt(1) }, errInvalidDifficulty},
+		{func(h *types.Header) { h.Nonce = types.EncodeNonce(1) }, errInvalidNonce},
+		{func(h *types.Header) { h.UncleHash = common.Hash{1} }, errInvalidUncleHash},
+		{func(h *types.Header) { h.Time = parent.Time }, errOlderBlockTime},
+		{func(h *types.Header) { h.Number = big.NewInt(2) }, consensus.ErrInvalidNumber},
+	}
+	for i, tt := range tests {
+		header := postTransitionHeader(config, parent)
+		tt.mutate(header)
+		if err := engine.VerifyHeader(chain, header, true); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Uncles are not allowed after the transition either
+	uncle := blocks[0].Header()
+	block := types.NewBlockWithHeader(postTransitionHeader(config, parent)).WithBody(nil, []*types.Header{uncle})
+	if err := engine.VerifyUncles(chain, block); err != errTooManyUncles {
+		t.Errorf("uncle error mismatch: have %v, want %v", err, errTooManyUncles)
+	}
+}
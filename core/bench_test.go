@@ -58,6 +58,12 @@ func BenchmarkInsertChain_uncles_memdb(b *testing.B) {
 func BenchmarkInsertChain_uncles_diskdb(b *testing.B) {
 	benchInsertChain(b, true, genUncles)
 }
+func BenchmarkInsertChain_contractCalls_memdb(b *testing.B) {
+	benchInsertContractCalls(b, 4096)
+}
+func BenchmarkInsertChain_contractCalls_noAnalysisCache_memdb(b *testing.B) {
+	benchInsertContractCalls(b, 0)
+}
 func BenchmarkInsertChain_ring200_memdb(b *testing.B) {
 	benchInsertChain(b, false, genTxRing(200))
 }
@@ -184,6 +190,58 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 	}
 }
 
+var (
+	// benchRouterAddr is a contract with 24KB of code, jumping over most of it
+	// similarly to the dispatchers of large contracts.
+	benchRouterAddr = common.HexToAddress("0x1000")
+	benchRouterCode = func() []byte {
+		code := common.FromHex("615ffc56") // PUSH2 0x5ffc, JUMP
+		for len(code) < 0x5ffc {
+			code = append(code, byte(vm.PUSH1), 0x5b)
+		}
+		return append(code, byte(vm.JUMPDEST), byte(vm.STOP))
+	}()
+)
+
+// genContractCalls returns a block generator that calls the router contract in
+// n transactions per block.
+func genContractCalls(n int) func(int, *BlockGen) {
+	return func(i int, gen *BlockGen) {
+		for j := 0; j < n; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(benchRootAddr), benchRouterAddr, big.NewInt(0), 30000, gen.BaseFee(), nil), types.HomesteadSigner{}, benchRootKey)
+			gen.AddTx(tx)
+		}
+	}
+}
+
+// benchInsertContractCalls measures the import of blocks calling the same large
+// contract in many transactions, retaining the given number of JUMPDEST analyses.
+func benchInsertContractCalls(b *testing.B, cacheSize int) {
+	defer vm.ResetAnalysisCache(4096)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec := Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			benchRootAddr:   {Balance: benchRootFunds},
+			benchRouterAddr: {Balance: new(big.Int), Code: benchRouterCode},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+	chain, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, b.N, genContractCalls(100))
+
+	// Drop the analyses done while generating the chain
+	vm.ResetAnalysisCache(cacheSize)
+
+	chainman, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chainman.Stop()
+	b.ReportAllocs()
+	b.ResetTimer()
+	if i, err := chainman.InsertChain(chain); err != nil {
+		b.Fatalf("insert error (block %d): %v\n", i, err)
+	}
+}
+
 func BenchmarkChainRead_header_10k(b *testing.B) {
 	benchReadChain(b, false, 10000)
 }
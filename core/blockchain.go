@@ -23,6 +23,7 @@ import (
 	"io"
 	"math/big"
 	mrand "math/rand"
+	"runtime"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -51,6 +52,7 @@ var (
 	headBlockGauge     = metrics.NewRegisteredGauge("chain/head/block", nil)
 	headHeaderGauge    = metrics.NewRegisteredGauge("chain/head/header", nil)
 	headFastBlockGauge = metrics.NewRegisteredGauge("chain/head/receipt", nil)
+	headFinalizedGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
 
 	accountReadTimer   = metrics.NewRegisteredTimer("chain/account/reads", nil)
 	accountHashTimer   = metrics.NewRegisteredTimer("chain/account/hashes", nil)
@@ -129,6 +131,7 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	ParallelExecution   bool          // Whether to execute the transactions of imported blocks optimistically in parallel
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -185,8 +188,9 @@ type BlockChain struct {
 
 	chainmu sync.RWMutex // blockchain insertion lock
 
-	currentBlock     atomic.Value // Current head of the block chain
-	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentBlock          atomic.Value // Current head of the block chain
+	currentFastBlock      atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
+	currentFinalizedBlock atomic.Value // Latest block finalized by the external consensus engine (may be nil)
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
@@ -218,6 +222,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	if err := vm.CheckPrecompiles(chainConfig); err != nil {
+		return nil, err
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
@@ -249,6 +256,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	if cacheConfig.ParallelExecution {
+		bc.processor = NewParallelStateProcessor(chainConfig, bc, engine, runtime.NumCPU())
+	}
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -263,6 +273,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	var nilBlock *types.Block
 	bc.currentBlock.Store(nilBlock)
 	bc.currentFastBlock.Store(nilBlock)
+	bc.currentFinalizedBlock.Store(nilBlock)
 
 	// Initialize the chain with ancient data if it isn't empty.
 	var txIndexBlock uint64
@@ -456,6 +467,22 @@ func (bc *BlockChain) loadLastState() error {
 			headFastBlockGauge.Update(int64(block.NumberU64()))
 		}
 	}
+	// Restore the last known finalized block, unless it was rewound or is not on
+	// the canonical chain any more, in which case the stale marker is dropped
+	var nilBlock *types.Block
+	bc.currentFinalizedBlock.Store(nilBlock)
+
+	if head := rawdb.ReadFinalizedBlockHash(bc.db); head != (common.Hash{}) {
+		block := bc.GetBlockByHash(head)
+		if block != nil && block.NumberU64() <= currentBlock.NumberU64() && rawdb.ReadCanonicalHash(bc.db, block.NumberU64()) == head {
+			bc.currentFinalizedBlock.Store(block)
+			headFinalizedGauge.Update(int64(block.NumberU64()))
+		} else {
+			log.Warn("Dropping stale finalized block marker", "hash", head)
+			rawdb.DeleteFinalizedBlockHash(bc.db)
+			headFinalizedGauge.Update(0)
+		}
+	}
 	// Issue a status log for the user
 	currentFastBlock := bc.CurrentFastBlock()
 
@@ -466,6 +493,9 @@ func (bc *BlockChain) loadLastState() error {
 	log.Info("Loaded most recent local header", "number", currentHeader.Number, "hash", currentHeader.Hash(), "td", headerTd, "age", common.PrettyAge(time.Unix(int64(currentHeader.Time), 0)))
 	log.Info("Loaded most recent local full block", "number", currentBlock.Number(), "hash", currentBlock.Hash(), "td", blockTd, "age", common.PrettyAge(time.Unix(int64(currentBlock.Time()), 0)))
 	log.Info("Loaded most recent local fast block", "number", currentFastBlock.Number(), "hash", currentFastBlock.Hash(), "td", fastTd, "age", common.PrettyAge(time.Unix(int64(currentFastBlock.Time()), 0)))
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil {
+		log.Info("Loaded most recent finalized block", "number", finalized.Number(), "hash", finalized.Hash(), "age", common.PrettyAge(time.Unix(int64(finalized.Time()), 0)))
+	}
 	if pivot := rawdb.ReadLastPivotNumber(bc.db); pivot != nil {
 		log.Info("Loaded last fast-sync pivot marker", "number", *pivot)
 	}
@@ -660,6 +690,21 @@ func (bc *BlockChain) CurrentBlock() *types.Block {
 	return bc.currentBlock.Load().(*types.Block)
 }
 
+// CurrentFinalizedBlock retrieves the latest block marked as finalized by the
+// external consensus engine, or nil if none was marked yet.
+func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
+	return bc.currentFinalizedBlock.Load().(*types.Block)
+}
+
+// SetFinalized marks the given block as finalized and persists the marker. The
+// canonical chain will not be reorganised below a finalized block afterwards and
+// the freezer is allowed to move it into the ancient store.
+func (bc *BlockChain) SetFinalized(block *types.Block) {
+	rawdb.WriteFinalizedBlockHash(bc.db, block.Hash())
+	bc.currentFinalizedBlock.Store(block)
+	headFinalizedGauge.Update(int64(block.NumberU64()))
+}
+
 // Snapshots returns the blockchain snapshot tree.
 func (bc *BlockChain) Snapshots() *snapshot.Tree {
 	return bc.snaps
@@ -1535,7 +1580,12 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Please refer to http://www.cs.cornell.edu/~ie53/publications/btcProcFC.pdf
 	reorg := externTd.Cmp(localTd) > 0
 	currentBlock = bc.CurrentBlock()
-	if !reorg && externTd.Cmp(localTd) == 0 {
+	if block.Difficulty().Sign() == 0 {
+		// Blocks past the catalyst transition carry no difficulty, the canonical
+		// chain is chosen by the external consensus client via SetChainHead. Only
+		// blocks extending the current head are made canonical automatically.
+		reorg = block.ParentHash() == currentBlock.Hash()
+	} else if !reorg && externTd.Cmp(localTd) == 0 {
 		// Split same-difficulty blocks by number, then preferentially select
 		// the block generated by the local miner as the canonical block.
 		if block.NumberU64() < currentBlock.NumberU64() {
@@ -1654,6 +1704,98 @@ func (bc *BlockChain) InsertChainWithoutSealVerification(block *types.Block) (in
 	return n, err
 }
 
+// SetChainHead makes the given, already imported block the head of the canonical
+// chain, reorganising the chain if needed. In contrast to SetHead, the new head
+// may live on any known side chain and nothing is deleted from the database, so
+// the previous canonical chain can be restored later on.
+//
+// The new head must descend from the finalized block, if any, and its state must
+// be available locally.
+func (bc *BlockChain) SetChainHead(hash common.Hash) error {
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	block := bc.GetBlockByHash(hash)
+	if block == nil {
+		return ErrUnknownBlock
+	}
+	if !bc.HasState(block.Root()) {
+		return fmt.Errorf("missing state for block #%d [%x..]", block.NumberU64(), hash.Bytes()[:4])
+	}
+	current := bc.CurrentBlock()
+	if current.Hash() == hash {
+		return nil
+	}
+	if !bc.descendsFromFinalized(block.Header()) {
+		return ErrReorgBelowFinalized
+	}
+	// Run the reorg if necessary and set the given block as new head
+	if block.ParentHash() != current.Hash() {
+		if err := bc.reorg(current, block); err != nil {
+			return err
+		}
+	}
+	bc.writeHeadBlock(block)
+
+	// If the chain was rewound to an ancestor of the previous head, the header
+	// and fast block markers are still pointing to the old chain, reset them.
+	if header := bc.CurrentHeader(); header.Number.Uint64() > block.NumberU64() {
+		batch := bc.db.NewBatch()
+		rawdb.WriteHeadHeaderHash(batch, hash)
+		rawdb.WriteHeadFastBlockHash(batch, hash)
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to update chain markers", "err", err)
+		}
+		bc.hc.SetCurrentHeader(block.Header())
+		bc.currentFastBlock.Store(block)
+		headFastBlockGauge.Update(int64(block.NumberU64()))
+	}
+	logs := bc.collectLogs(block)
+	bc.chainFeed.Send(ChainEvent{Block: block, Hash: hash, Logs: logs})
+	if len(logs) > 0 {
+		bc.logsFeed.Send(logs)
+	}
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
+
+	log.Info("Chain head was updated", "number", block.Number(), "hash", hash, "root", block.Root())
+	return nil
+}
+
+// collectLogs retrieves the logs generated during the processing of the given
+// block, with all the derived fields filled in.
+func (bc *BlockChain) collectLogs(block *types.Block) []*types.Log {
+	receipts := rawdb.ReadReceipts(bc.db, block.Hash(), block.NumberU64(), bc.chainConfig)
+
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		logs = append(logs, receipt.Logs...)
+	}
+	return logs
+}
+
+// descendsFromFinalized reports whether the given header is the finalized block
+// or one of its descendants. If no block was finalized yet, any header passes.
+func (bc *BlockChain) descendsFromFinalized(header *types.Header) bool {
+	finalized := bc.CurrentFinalizedBlock()
+	if finalized == nil {
+		return true
+	}
+	number := header.Number.Uint64()
+	if number < finalized.NumberU64() {
+		return false
+	}
+	for number > finalized.NumberU64() {
+		if header = bc.GetHeader(header.ParentHash, number-1); header == nil {
+			return false
+		}
+		number--
+	}
+	return header.Hash() == finalized.Hash()
+}
+
 // insertChain is the internal implementation of InsertChain, which assumes that
 // 1) chains are contiguous, and 2) The chain mutex is held.
 //
@@ -2186,6 +2328,12 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("invalid new chain")
 		}
 	}
+	// Never drop finalized blocks from the canonical chain
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && len(oldChain) > 0 && commonBlock.NumberU64() < finalized.NumberU64() {
+		log.Warn("Rejected reorg below finalized block", "number", commonBlock.Number(), "hash", commonBlock.Hash(),
+			"finalized", finalized.Number(), "finalizedhash", finalized.Hash())
+		return ErrReorgBelowFinalized
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
@@ -2199,6 +2347,17 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		blockReorgAddMeter.Mark(int64(len(newChain)))
 		blockReorgDropMeter.Mark(int64(len(oldChain)))
 		blockReorgMeter.Mark(1)
+	} else if len(newChain) > 0 {
+		// The old head is an ancestor of the new one, but they are not consecutive.
+		// This can only happen when the head is set explicitly.
+		log.Info("Extending canonical chain", "number", newChain[0].Number(), "hash", newChain[0].Hash(), "add", len(newChain))
+		blockReorgAddMeter.Mark(int64(len(newChain)))
+	} else if len(oldChain) > 0 {
+		// The new head is an ancestor of the old one, rewind the canonical chain.
+		// This can only happen when the head is set explicitly.
+		log.Info("Rewinding canonical chain", "number", commonBlock.Number(), "hash", commonBlock.Hash(), "drop", len(oldChain))
+		blockReorgDropMeter.Mark(int64(len(oldChain)))
+		blockReorgMeter.Mark(1)
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
@@ -2222,6 +2381,9 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	}
 	// Delete any canonical number assignments above the new head
 	number := bc.CurrentBlock().NumberU64()
+	if len(newChain) == 0 {
+		number = commonBlock.NumberU64()
+	}
 	for i := number + 1; ; i++ {
 		hash := rawdb.ReadCanonicalHash(bc.db, i)
 		if hash == (common.Hash{}) {
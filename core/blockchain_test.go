@@ -3123,6 +3123,85 @@ func TestEIP2718Transition(t *testing.T) {
 	}
 }
 
+// TestEIP1153Transition tests that the transient storage written by a transaction
+// is visible for the rest of it, but cleared before the next transaction of the
+// same block is executed.
+func TestEIP1153Transition(t *testing.T) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+
+		engine = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000000000)
+		config  = *params.TestChainConfig
+		gspec   = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				address: {Balance: funds},
+				// The address 0xAAAA persists the transient slot 0 into slot callvalue,
+				// then sets it and persists it again into slot callvalue+0x10
+				aa: {
+					Code: []byte{
+						byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.CALLVALUE), byte(vm.SSTORE),
+						byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.TSTORE),
+						byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.CALLVALUE), byte(vm.PUSH1), 0x10, byte(vm.ADD), byte(vm.SSTORE),
+					},
+					Nonce:   0,
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+	)
+	config.EIP1153Block = big.NewInt(0)
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+
+		// Two transactions to 0xAAAA in the same block
+		signer := types.LatestSigner(gspec.Config)
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignNewTx(key, signer, &types.LegacyTx{
+				Nonce:    nonce,
+				To:       &aa,
+				Value:    new(big.Int).SetUint64(nonce + 1),
+				Gas:      100000,
+				GasPrice: b.header.BaseFee,
+			})
+			b.AddTx(tx)
+		}
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve head state: %v", err)
+	}
+	for slot, want := range map[byte]common.Hash{
+		0x01: {},                              // First transaction starts empty
+		0x11: common.BytesToHash([]byte{0x2a}), // First transaction sees its own write
+		0x02: {},                              // Second transaction starts empty again
+		0x12: common.BytesToHash([]byte{0x2a}), // Second transaction sees its own write
+	} {
+		if have := statedb.GetState(aa, common.BytesToHash([]byte{slot})); have != want {
+			t.Errorf("slot %#x mismatch: have %x, want %x", slot, have, want)
+		}
+	}
+}
+
 // TestEIP1559Transition tests the following:
 //
 // 1. A transaction whose gasFeeCap is greater than the baseFee is valid.
@@ -3275,3 +3354,221 @@ func TestEIP1559Transition(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// Tests that the head of the chain can be explicitly moved onto a side chain,
+// back onto an ancestor and that it cannot be moved below the finalized block.
+func TestSetChainHead(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		db      = rawdb.NewMemoryDatabase()
+		genesis = (&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+	)
+	canon, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 10, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+	})
+	fork, _ := GenerateChain(params.TestChainConfig, canon[2], engine, db, 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{2})
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	(&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(canon); err != nil {
+		t.Fatalf("block %d: failed to insert canonical chain: %v", n, err)
+	}
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert side chain: %v", n, err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != canon[9].Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), canon[9].NumberU64())
+	}
+	headCh := make(chan ChainHeadEvent, 10)
+	sub := chain.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	// checkHead verifies that the given block is the head and that the canonical
+	// mappings correspond to the given chain segment.
+	checkHead := func(head *types.Block, blocks []*types.Block) {
+		t.Helper()
+
+		if have := chain.CurrentBlock(); have.Hash() != head.Hash() {
+			t.Fatalf("head block mismatch: have #%d [%x], want #%d [%x]", have.NumberU64(), have.Hash(), head.NumberU64(), head.Hash())
+		}
+		if have := chain.CurrentHeader(); have.Hash() != head.Hash() {
+			t.Fatalf("head header mismatch: have #%d [%x], want #%d [%x]", have.Number, have.Hash(), head.NumberU64(), head.Hash())
+		}
+		for _, block := range blocks {
+			if have := chain.GetCanonicalHash(block.NumberU64()); have != block.Hash() {
+				t.Fatalf("canonical hash mismatch at #%d: have %x, want %x", block.NumberU64(), have, block.Hash())
+			}
+		}
+		if block := chain.GetBlockByNumber(head.NumberU64() + 1); block != nil {
+			t.Fatalf("canonical block above head: #%d", block.NumberU64())
+		}
+		select {
+		case ev := <-headCh:
+			if ev.Block.Hash() != head.Hash() {
+				t.Fatalf("head event mismatch: have %x, want %x", ev.Block.Hash(), head.Hash())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no head event received")
+		}
+	}
+	// Move the head onto the lighter side chain
+	if err := chain.SetChainHead(fork[2].Hash()); err != nil {
+		t.Fatalf("failed to set head to side chain: %v", err)
+	}
+	checkHead(fork[2], append(canon[:3:3], fork...))
+
+	// Move the head back onto an ancestor of the original chain
+	if err := chain.SetChainHead(canon[5].Hash()); err != nil {
+		t.Fatalf("failed to set head to canonical chain: %v", err)
+	}
+	checkHead(canon[5], canon[:6])
+
+	// Rewind the head to an ancestor of itself
+	if err := chain.SetChainHead(canon[3].Hash()); err != nil {
+		t.Fatalf("failed to rewind head: %v", err)
+	}
+	checkHead(canon[3], canon[:4])
+
+	// Unknown blocks must be rejected
+	if err := chain.SetChainHead(common.Hash{0x01}); err != ErrUnknownBlock {
+		t.Fatalf("unknown head error mismatch: have %v, want %v", err, ErrUnknownBlock)
+	}
+	// Blocks not descending from the finalized one must be rejected
+	chain.SetFinalized(canon[3])
+	if err := chain.SetChainHead(fork[2].Hash()); err != ErrReorgBelowFinalized {
+		t.Fatalf("finalized reorg error mismatch: have %v, want %v", err, ErrReorgBelowFinalized)
+	}
+	if err := chain.SetChainHead(canon[9].Hash()); err != nil {
+		t.Fatalf("failed to set head above finalized block: %v", err)
+	}
+	checkHead(canon[9], canon)
+}
+
+// Tests that the chain refuses to reorg below the finalized block and that the
+// finalized marker survives a restart.
+func TestReorgBelowFinalized(t *testing.T) {
+	chain, canonblocks, sideblocks, err := getLongAndShortChains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := chain.InsertChain(canonblocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	chain.SetFinalized(canonblocks[10])
+
+	if _, err := chain.InsertChain(sideblocks); !errors.Is(err, ErrReorgBelowFinalized) {
+		t.Fatalf("heavier side chain import error mismatch: have %v, want %v", err, ErrReorgBelowFinalized)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != canonblocks[len(canonblocks)-1].Hash() {
+		t.Fatalf("head block mismatch: have #%d [%x]", head.NumberU64(), head.Hash())
+	}
+	chain.Stop()
+
+	// Reopen the chain and ensure the finalized block was restored
+	chain, err = NewBlockChain(chain.db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if finalized := chain.CurrentFinalizedBlock(); finalized == nil || finalized.Hash() != canonblocks[10].Hash() {
+		t.Fatalf("finalized block mismatch: have %v, want %x", finalized, canonblocks[10].Hash())
+	}
+	// Rewind below the finalized block and ensure the marker is dropped
+	if err := chain.SetHead(canonblocks[5].NumberU64()); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if finalized := chain.CurrentFinalizedBlock(); finalized != nil {
+		t.Fatalf("finalized block retained after rewind: #%d [%x]", finalized.NumberU64(), finalized.Hash())
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(chain.db); hash != (common.Hash{}) {
+		t.Fatalf("finalized marker retained after rewind: %x", hash)
+	}
+}
+
+// Tests that a persisted finalized marker pointing to a block which is not on
+// the canonical chain is not restored.
+func TestNonCanonicalFinalized(t *testing.T) {
+	chain, canonblocks, sideblocks, err := getLongAndShortChains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := chain.InsertChain(canonblocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if n, err := chain.InsertChain(sideblocks[:5]); err != nil {
+		t.Fatalf("block %d: failed to insert side chain: %v", n, err)
+	}
+	rawdb.WriteFinalizedBlockHash(chain.db, sideblocks[4].Hash())
+	chain.Stop()
+
+	chain, err = NewBlockChain(chain.db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if finalized := chain.CurrentFinalizedBlock(); finalized != nil {
+		t.Fatalf("non-canonical finalized block restored: #%d [%x]", finalized.NumberU64(), finalized.Hash())
+	}
+	if hash := rawdb.ReadFinalizedBlockHash(chain.db); hash != (common.Hash{}) {
+		t.Fatalf("non-canonical finalized marker retained: %x", hash)
+	}
+}
+
+// Tests that finalized blocks are moved into the ancient store without waiting
+// for the immutability threshold.
+func TestFreezeFinalized(t *testing.T) {
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "", false)
+	if err != nil {
+		t.Fatalf("failed to create temp freezer db: %v", err)
+	}
+	defer db.Close()
+
+	var (
+		engine  = ethash.NewFaker()
+		genesis = (&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, rawdb.NewMemoryDatabase(), 32, nil)
+
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	type freezer interface {
+		Freeze(threshold uint64) error
+		Ancients() (uint64, error)
+	}
+	db.(freezer).Freeze(params.FullImmutabilityThreshold)
+	if frozen, _ := db.(freezer).Ancients(); frozen != 0 {
+		t.Fatalf("frozen items mismatch before finalization: have %d, want %d", frozen, 0)
+	}
+	chain.SetFinalized(blocks[9])
+
+	db.(freezer).Freeze(params.FullImmutabilityThreshold)
+	if frozen, _ := db.(freezer).Ancients(); frozen != 11 {
+		t.Fatalf("frozen items mismatch after finalization: have %d, want %d", frozen, 11)
+	}
+	if block := chain.GetBlockByNumber(10); block == nil || block.Hash() != blocks[9].Hash() {
+		t.Fatalf("finalized block not retrievable after freezing")
+	}
+}
@@ -31,6 +31,13 @@ var (
 
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
+
+	// ErrUnknownBlock is returned when a block to make canonical is not known locally.
+	ErrUnknownBlock = errors.New("unknown block")
+
+	// ErrReorgBelowFinalized is returned when a chain reorganisation would drop
+	// a block that was already marked as finalized.
+	ErrReorgBelowFinalized = errors.New("reorg below finalized block")
 )
 
 // List of evm-call-message pre-checking errors. All state transition messages will
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -228,7 +229,8 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	if height == nil {
 		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
 	}
-	compatErr := storedcfg.CheckCompatible(newcfg, *height)
+	transition := catalystTransition(db, *height, storedcfg.CatalystTotalDifficulty, newcfg.CatalystTotalDifficulty)
+	compatErr := storedcfg.CheckCompatible(newcfg, *height, transition)
 	if compatErr != nil && *height != 0 && compatErr.RewindTo != 0 {
 		return newcfg, stored, compatErr
 	}
@@ -236,6 +238,29 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	return newcfg, stored, nil
 }
 
+// catalystTransition returns the first canonical block up to the given height
+// whose parent reached the lower of the two catalyst total difficulties, or nil
+// if none of them did.
+func catalystTransition(db ethdb.Reader, height uint64, storedTd, newTd *big.Int) *big.Int {
+	target := storedTd
+	if target == nil || (newTd != nil && newTd.Cmp(target) < 0) {
+		target = newTd
+	}
+	if target == nil {
+		return nil
+	}
+	// Total difficulty is monotonic along the chain, search for the first parent reaching it
+	reached := func(number uint64) bool {
+		td := rawdb.ReadTd(db, rawdb.ReadCanonicalHash(db, number), number)
+		return td != nil && td.Cmp(target) >= 0
+	}
+	if height == 0 || !reached(height-1) {
+		return nil
+	}
+	parent := sort.Search(int(height), func(i int) bool { return reached(uint64(i)) })
+	return new(big.Int).SetUint64(uint64(parent) + 1)
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
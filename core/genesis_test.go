@@ -163,6 +163,47 @@ func TestSetupGenesis(t *testing.T) {
 	}
 }
 
+// Tests that the catalyst total difficulty can only be changed if the local chain
+// did not reach it yet, and that the chain is rewound to before the transition
+// otherwise.
+func TestSetupGenesisCatalystTotalDifficulty(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: &params.ChainConfig{HomesteadBlock: big.NewInt(0)}}
+		genesis = gspec.MustCommit(db)
+	)
+	bc, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer bc.Stop()
+
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 4, nil)
+	if _, err := bc.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	// Scheduling the transition beyond the head is compatible
+	future := &Genesis{Config: &params.ChainConfig{
+		HomesteadBlock:          big.NewInt(0),
+		CatalystTotalDifficulty: new(big.Int).Add(bc.GetTdByHash(blocks[3].Hash()), common.Big1),
+	}}
+	if _, _, err := SetupGenesisBlock(db, future); err != nil {
+		t.Fatalf("failed to schedule future transition: %v", err)
+	}
+	// Moving the transition before the head must rewind to before its first block
+	past := &Genesis{Config: &params.ChainConfig{
+		HomesteadBlock:          big.NewInt(0),
+		CatalystTotalDifficulty: bc.GetTdByHash(blocks[1].Hash()),
+	}}
+	_, _, err := SetupGenesisBlock(db, past)
+	want := &params.ConfigCompatError{
+		What:         "Catalyst total difficulty",
+		StoredConfig: future.Config.CatalystTotalDifficulty,
+		NewConfig:    past.Config.CatalystTotalDifficulty,
+		RewindTo:     2,
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Fatalf("compatibility error mismatch: have %v, want %v", err, want)
+	}
+}
+
 // TestGenesisHashes checks the congruity of default genesis data to corresponding hardcoded genesis hash values.
 func TestGenesisHashes(t *testing.T) {
 	cases := []struct {
@@ -0,0 +1,692 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// versionKind is the part of the state a versioned key refers to.
+type versionKind byte
+
+const (
+	versionAccount versionKind = iota // Existence, nonce and code of an account
+	versionBalance                    // Balance of an account
+	versionReset                      // Wiping of the storage of an account
+	versionSlot                       // Single storage slot of an account
+)
+
+// versionKey identifies a part of the state tracked by the versioned state.
+type versionKey struct {
+	kind versionKind
+	addr common.Address
+	slot common.Hash
+}
+
+// versionedAccount is the existence, nonce and code of an account after the
+// execution of a transaction.
+type versionedAccount struct {
+	exists   bool
+	nonce    uint64
+	code     []byte
+	codeHash common.Hash
+}
+
+// versionedValue is a part of the state written by a transaction, along with
+// the index of that transaction in the block.
+type versionedValue struct {
+	writer  int
+	account *versionedAccount
+	balance *big.Int
+	slot    common.Hash
+}
+
+// versionedState holds the state written by the transactions of a block which
+// have already been committed, indexed by the part of the state written. Reads
+// not covered by any of them fall through to the state at the beginning of the
+// block, reported as version -1.
+type versionedState struct {
+	values map[versionKey]*versionedValue
+	lock   sync.RWMutex
+}
+
+func newVersionedState() *versionedState {
+	return &versionedState{values: make(map[versionKey]*versionedValue)}
+}
+
+// get returns the latest value of the key, or nil if it hasn't been written.
+func (vs *versionedState) get(key versionKey) *versionedValue {
+	vs.lock.RLock()
+	defer vs.lock.RUnlock()
+
+	return vs.values[key]
+}
+
+// version returns the index of the last transaction which wrote the key, or -1
+// if it hasn't been written.
+func (vs *versionedState) version(key versionKey) int {
+	if val := vs.get(key); val != nil {
+		return val.writer
+	}
+	return -1
+}
+
+// set updates the values of the keys written by a committed transaction.
+func (vs *versionedState) set(values map[versionKey]*versionedValue) {
+	vs.lock.Lock()
+	defer vs.lock.Unlock()
+
+	for key, val := range values {
+		vs.values[key] = val
+	}
+}
+
+// speculativeAccount is the state of an account during a speculative execution.
+// Its prior state is loaded lazily, so that accounts only credited, like the
+// coinbase, don't make the transaction depend on their previous balance.
+type speculativeAccount struct {
+	loaded   bool // Whether the existence, nonce and code were loaded or overwritten
+	exists   bool
+	nonce    uint64
+	code     []byte
+	codeHash common.Hash
+
+	balance *big.Int // Balance of the account, nil if not read yet
+	delta   *big.Int // Balance change applied while the balance is unknown
+
+	storage   map[common.Hash]common.Hash // Slots written by the transaction
+	committed map[common.Hash]common.Hash // Slots as of the beginning of the transaction
+
+	// Modifications done by the transaction, replayed onto the block state
+	created      bool // Whether the account was (re)created by CreateAccount
+	suicided     bool // Whether the account self destructed
+	touched      bool // Whether the account was modified, implicitly creating it
+	zeroTouched  bool // Whether the account was credited with zero wei
+	nonceDirty   bool
+	codeDirty    bool
+	balanceDirty bool
+}
+
+// speculativeState is a vm.StateDB executing a transaction on top of the state
+// committed by the previous transactions of the block. Every read of a prior
+// state is recorded along with its version, allowing to check whether the
+// execution is still valid once all the previous transactions are committed.
+type speculativeState struct {
+	base      *state.StateDB  // State at the beginning of the block, owned by the executor
+	versioned *versionedState // State committed by the previous transactions
+	reads     map[versionKey]int
+
+	accounts  map[common.Address]*speculativeAccount
+	transient map[common.Address]map[common.Hash]common.Hash
+	access    map[common.Address]map[common.Hash]struct{}
+	refund    uint64
+	logs      []*types.Log
+	preimages map[common.Hash][]byte
+
+	journal       []func()
+	ripemdTouched bool // Whether ripemd was touched, surviving reverts just like in the state journal
+}
+
+func newSpeculativeState(base *state.StateDB, versioned *versionedState) *speculativeState {
+	return &speculativeState{
+		base:      base,
+		versioned: versioned,
+		reads:     make(map[versionKey]int),
+		accounts:  make(map[common.Address]*speculativeAccount),
+		transient: make(map[common.Address]map[common.Hash]common.Hash),
+		access:    make(map[common.Address]map[common.Hash]struct{}),
+		preimages: make(map[common.Hash][]byte),
+	}
+}
+
+// read returns the latest committed value of the key, recording the version of
+// the first read.
+func (s *speculativeState) read(key versionKey) *versionedValue {
+	val := s.versioned.get(key)
+	if _, ok := s.reads[key]; !ok {
+		if val != nil {
+			s.reads[key] = val.writer
+		} else {
+			s.reads[key] = -1
+		}
+	}
+	return val
+}
+
+// valid reports whether all the reads of the execution still return the same
+// values, i.e. whether no transaction committed since then overwrote them.
+func (s *speculativeState) valid() bool {
+	for key, version := range s.reads {
+		if s.versioned.version(key) != version {
+			return false
+		}
+	}
+	return true
+}
+
+// account returns the speculative account of the address, without loading it.
+func (s *speculativeState) account(addr common.Address) *speculativeAccount {
+	acc := s.accounts[addr]
+	if acc == nil {
+		acc = &speculativeAccount{
+			storage:   make(map[common.Hash]common.Hash),
+			committed: make(map[common.Hash]common.Hash),
+		}
+		s.accounts[addr] = acc
+	}
+	return acc
+}
+
+// loaded returns the speculative account with its existence, nonce and code
+// loaded.
+func (s *speculativeState) loaded(addr common.Address) *speculativeAccount {
+	acc := s.account(addr)
+	if acc.loaded {
+		return acc
+	}
+	if val := s.read(versionKey{kind: versionAccount, addr: addr}); val != nil {
+		acc.exists, acc.nonce, acc.code, acc.codeHash = val.account.exists, val.account.nonce, val.account.code, val.account.codeHash
+	} else {
+		acc.exists, acc.nonce, acc.code, acc.codeHash = s.base.Exist(addr), s.base.GetNonce(addr), s.base.GetCode(addr), s.base.GetCodeHash(addr)
+	}
+	if !acc.exists {
+		// Accounts created implicitly by a modification have no code
+		acc.nonce, acc.code, acc.codeHash = 0, nil, emptyCodeHash
+	}
+	acc.loaded = true
+	return acc
+}
+
+// alive returns whether the account exists, either beforehand or by being
+// modified during the transaction.
+func (s *speculativeState) alive(addr common.Address) bool {
+	acc := s.loaded(addr)
+	return acc.exists || acc.touched
+}
+
+// balance returns the current balance of the account, loading it if needed.
+func (s *speculativeState) balance(addr common.Address) *big.Int {
+	acc := s.account(addr)
+	if acc.balance != nil {
+		return acc.balance
+	}
+	var prior *big.Int
+	if val := s.read(versionKey{kind: versionBalance, addr: addr}); val != nil {
+		prior = val.balance
+	} else {
+		prior = s.base.GetBalance(addr)
+	}
+	// Loading the balance isn't a modification, so it's not journalled. The
+	// delta is kept around in case an earlier modification is reverted.
+	acc.balance = new(big.Int).Set(prior)
+	if acc.delta != nil {
+		acc.balance.Add(acc.balance, acc.delta)
+	}
+	return acc.balance
+}
+
+// committedState returns the value of the slot at the beginning of the
+// transaction.
+func (s *speculativeState) committedState(addr common.Address, key common.Hash) common.Hash {
+	acc := s.account(addr)
+	if acc.created {
+		return common.Hash{}
+	}
+	if value, ok := acc.committed[key]; ok {
+		return value
+	}
+	var (
+		value common.Hash
+		reset = s.read(versionKey{kind: versionReset, addr: addr})
+		slot  = s.read(versionKey{kind: versionSlot, addr: addr, slot: key})
+	)
+	switch {
+	case slot != nil && (reset == nil || slot.writer >= reset.writer):
+		value = slot.slot
+	case reset != nil:
+		// Storage wiped after the last write of the slot
+	default:
+		value = s.base.GetState(addr, key)
+	}
+	acc.committed[key] = value
+	return value
+}
+
+// modify journals the current state of the account before a modification and
+// marks it as touched.
+func (s *speculativeState) modify(addr common.Address) *speculativeAccount {
+	acc := s.account(addr)
+	prev := *acc
+	s.journal = append(s.journal, func() { *acc = prev })
+
+	acc.touched = true
+	return acc
+}
+
+// CreateAccount implements vm.StateDB, resetting the account while carrying over
+// its balance.
+func (s *speculativeState) CreateAccount(addr common.Address) {
+	acc := s.modify(addr)
+	acc.loaded, acc.exists, acc.nonce, acc.code, acc.codeHash = true, true, 0, nil, emptyCodeHash
+	acc.storage = make(map[common.Hash]common.Hash)
+	acc.created = true
+}
+
+// addBalance changes the balance of the account by the given amount, without
+// reading its prior balance if it's still unknown.
+func (s *speculativeState) addBalance(addr common.Address, amount *big.Int) {
+	acc := s.modify(addr)
+	if acc.balance != nil {
+		acc.balance = new(big.Int).Add(acc.balance, amount)
+	}
+	if acc.delta != nil {
+		acc.delta = new(big.Int).Add(acc.delta, amount)
+	} else {
+		acc.delta = new(big.Int).Set(amount)
+	}
+	acc.balanceDirty = true
+}
+
+// SubBalance implements vm.StateDB.
+func (s *speculativeState) SubBalance(addr common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		// Debiting nothing still creates the account, but doesn't touch it
+		s.modify(addr)
+		return
+	}
+	s.addBalance(addr, new(big.Int).Neg(amount))
+}
+
+// AddBalance implements vm.StateDB.
+func (s *speculativeState) AddBalance(addr common.Address, amount *big.Int) {
+	if amount.Sign() == 0 {
+		if addr == ripemd {
+			s.ripemdTouched = true
+		}
+		s.modify(addr).zeroTouched = true
+		return
+	}
+	s.addBalance(addr, amount)
+}
+
+// GetBalance implements vm.StateDB.
+func (s *speculativeState) GetBalance(addr common.Address) *big.Int {
+	if !s.alive(addr) {
+		return common.Big0
+	}
+	return s.balance(addr)
+}
+
+// GetNonce implements vm.StateDB.
+func (s *speculativeState) GetNonce(addr common.Address) uint64 {
+	return s.loaded(addr).nonce
+}
+
+// SetNonce implements vm.StateDB.
+func (s *speculativeState) SetNonce(addr common.Address, nonce uint64) {
+	s.loaded(addr)
+	acc := s.modify(addr)
+	acc.nonce, acc.nonceDirty = nonce, true
+}
+
+// GetCodeHash implements vm.StateDB.
+func (s *speculativeState) GetCodeHash(addr common.Address) common.Hash {
+	if !s.alive(addr) {
+		return common.Hash{}
+	}
+	return s.loaded(addr).codeHash
+}
+
+// GetCode implements vm.StateDB.
+func (s *speculativeState) GetCode(addr common.Address) []byte {
+	return s.loaded(addr).code
+}
+
+// SetCode implements vm.StateDB.
+func (s *speculativeState) SetCode(addr common.Address, code []byte) {
+	s.loaded(addr)
+	acc := s.modify(addr)
+	acc.code, acc.codeHash, acc.codeDirty = code, crypto.Keccak256Hash(code), true
+}
+
+// GetCodeSize implements vm.StateDB.
+func (s *speculativeState) GetCodeSize(addr common.Address) int {
+	return len(s.loaded(addr).code)
+}
+
+// AddRefund implements vm.StateDB.
+func (s *speculativeState) AddRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	s.refund += gas
+}
+
+// SubRefund implements vm.StateDB.
+func (s *speculativeState) SubRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	if gas > s.refund {
+		panic(fmt.Sprintf("Refund counter below zero (gas: %d > refund: %d)", gas, s.refund))
+	}
+	s.refund -= gas
+}
+
+// GetRefund implements vm.StateDB.
+func (s *speculativeState) GetRefund() uint64 {
+	return s.refund
+}
+
+// GetCommittedState implements vm.StateDB.
+func (s *speculativeState) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	if !s.alive(addr) {
+		return common.Hash{}
+	}
+	return s.committedState(addr, key)
+}
+
+// GetState implements vm.StateDB.
+func (s *speculativeState) GetState(addr common.Address, key common.Hash) common.Hash {
+	if !s.alive(addr) {
+		return common.Hash{}
+	}
+	if value, ok := s.account(addr).storage[key]; ok {
+		return value
+	}
+	return s.committedState(addr, key)
+}
+
+// SetState implements vm.StateDB.
+func (s *speculativeState) SetState(addr common.Address, key, value common.Hash) {
+	s.loaded(addr)
+	if s.alive(addr) && s.GetState(addr, key) == value {
+		// Writing the current value only creates the account if missing
+		s.modify(addr)
+		return
+	}
+	acc := s.modify(addr)
+	storage := acc.storage
+	prev, dirty := storage[key]
+	s.journal = append(s.journal, func() {
+		if dirty {
+			storage[key] = prev
+		} else {
+			delete(storage, key)
+		}
+	})
+	storage[key] = value
+}
+
+// GetTransientState implements vm.StateDB.
+func (s *speculativeState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	return s.transient[addr][key]
+}
+
+// SetTransientState implements vm.StateDB.
+func (s *speculativeState) SetTransientState(addr common.Address, key, value common.Hash) {
+	if s.transient[addr] == nil {
+		s.transient[addr] = make(map[common.Hash]common.Hash)
+	}
+	storage := s.transient[addr]
+	prev := storage[key]
+	s.journal = append(s.journal, func() { storage[key] = prev })
+	storage[key] = value
+}
+
+// Suicide implements vm.StateDB.
+func (s *speculativeState) Suicide(addr common.Address) bool {
+	if !s.alive(addr) {
+		return false
+	}
+	acc := s.account(addr)
+	prev := *acc
+	s.journal = append(s.journal, func() { *acc = prev })
+
+	acc.suicided, acc.balance, acc.delta, acc.balanceDirty = true, new(big.Int), nil, true
+	return true
+}
+
+// HasSuicided implements vm.StateDB.
+func (s *speculativeState) HasSuicided(addr common.Address) bool {
+	return s.account(addr).suicided
+}
+
+// Exist implements vm.StateDB.
+func (s *speculativeState) Exist(addr common.Address) bool {
+	return s.alive(addr)
+}
+
+// Empty implements vm.StateDB.
+func (s *speculativeState) Empty(addr common.Address) bool {
+	if !s.alive(addr) {
+		return true
+	}
+	acc := s.loaded(addr)
+	return acc.nonce == 0 && s.balance(addr).Sign() == 0 && acc.codeHash == emptyCodeHash
+}
+
+// PrepareAccessList implements vm.StateDB.
+func (s *speculativeState) PrepareAccessList(sender common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	s.AddAddressToAccessList(sender)
+	if dst != nil {
+		s.AddAddressToAccessList(*dst)
+	}
+	for _, addr := range precompiles {
+		s.AddAddressToAccessList(addr)
+	}
+	for _, el := range list {
+		s.AddAddressToAccessList(el.Address)
+		for _, key := range el.StorageKeys {
+			s.AddSlotToAccessList(el.Address, key)
+		}
+	}
+}
+
+// AddressInAccessList implements vm.StateDB.
+func (s *speculativeState) AddressInAccessList(addr common.Address) bool {
+	_, ok := s.access[addr]
+	return ok
+}
+
+// SlotInAccessList implements vm.StateDB.
+func (s *speculativeState) SlotInAccessList(addr common.Address, slot common.Hash) (bool, bool) {
+	slots, ok := s.access[addr]
+	if !ok {
+		return false, false
+	}
+	_, slotOk := slots[slot]
+	return true, slotOk
+}
+
+// AddAddressToAccessList implements vm.StateDB.
+func (s *speculativeState) AddAddressToAccessList(addr common.Address) {
+	if _, ok := s.access[addr]; ok {
+		return
+	}
+	s.access[addr] = make(map[common.Hash]struct{})
+	s.journal = append(s.journal, func() { delete(s.access, addr) })
+}
+
+// AddSlotToAccessList implements vm.StateDB.
+func (s *speculativeState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.AddAddressToAccessList(addr)
+	slots := s.access[addr]
+	if _, ok := slots[slot]; ok {
+		return
+	}
+	slots[slot] = struct{}{}
+	s.journal = append(s.journal, func() { delete(slots, slot) })
+}
+
+// Snapshot implements vm.StateDB.
+func (s *speculativeState) Snapshot() int {
+	return len(s.journal)
+}
+
+// RevertToSnapshot implements vm.StateDB.
+func (s *speculativeState) RevertToSnapshot(id int) {
+	for i := len(s.journal) - 1; i >= id; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:id]
+}
+
+// AddLog implements vm.StateDB.
+func (s *speculativeState) AddLog(log *types.Log) {
+	s.journal = append(s.journal, func() { s.logs = s.logs[:len(s.logs)-1] })
+	s.logs = append(s.logs, log)
+}
+
+// AddPreimage implements vm.StateDB.
+func (s *speculativeState) AddPreimage(hash common.Hash, preimage []byte) {
+	if _, ok := s.preimages[hash]; ok {
+		return
+	}
+	s.preimages[hash] = common.CopyBytes(preimage)
+	s.journal = append(s.journal, func() { delete(s.preimages, hash) })
+}
+
+// ForEachStorage implements vm.StateDB, iterating over the slots written by the
+// transaction only.
+func (s *speculativeState) ForEachStorage(addr common.Address, cb func(common.Hash, common.Hash) bool) error {
+	for key, value := range s.account(addr).storage {
+		if !cb(key, value) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// apply replays the modifications of the transaction onto the block state, in a
+// way leaving it exactly as a serial execution of the transaction would. The
+// state needs to be prepared for the transaction beforehand and finalised
+// afterwards, followed by a call to written.
+func (s *speculativeState) apply(statedb *state.StateDB, deleteEmptyObjects bool) {
+	for _, addr := range s.modified() {
+		acc := s.accounts[addr]
+		if !acc.touched && !acc.suicided {
+			continue
+		}
+		if acc.created || !statedb.Exist(addr) {
+			// Recreating the account or creating a missing one both reset it,
+			// carrying over the balance in the first case
+			statedb.CreateAccount(addr)
+		}
+		if acc.balanceDirty {
+			if acc.balance != nil {
+				statedb.SetBalance(addr, acc.balance)
+			} else {
+				statedb.SetBalance(addr, new(big.Int).Add(statedb.GetBalance(addr), acc.delta))
+			}
+		}
+		if acc.nonceDirty {
+			statedb.SetNonce(addr, acc.nonce)
+		}
+		if acc.codeDirty {
+			statedb.SetCode(addr, acc.code)
+		}
+		for key, value := range acc.storage {
+			statedb.SetState(addr, key, value)
+		}
+		if acc.zeroTouched {
+			statedb.AddBalance(addr, common.Big0)
+		}
+		if acc.suicided {
+			statedb.Suicide(addr)
+		}
+	}
+	// Ripemd stays touched even if reverted, deleting it if it exists and is empty
+	if s.ripemdTouched && deleteEmptyObjects && statedb.Exist(ripemd) {
+		statedb.AddBalance(ripemd, common.Big0)
+	}
+	for _, log := range s.logs {
+		statedb.AddLog(log)
+	}
+	for hash, preimage := range s.preimages {
+		statedb.AddPreimage(hash, preimage)
+	}
+}
+
+// written returns the parts of the state written by the transaction with their
+// values read from the finalised block state, to be committed to the versioned
+// state. The existence of the modified accounts before applying the transaction
+// is needed to detect deletions.
+func (s *speculativeState) written(statedb *state.StateDB, existed map[common.Address]bool, index int) map[versionKey]*versionedValue {
+	values := make(map[versionKey]*versionedValue)
+	for _, addr := range s.modified() {
+		acc := s.accounts[addr]
+		if !acc.touched && !acc.suicided && !(addr == ripemd && s.ripemdTouched) {
+			continue
+		}
+		exists := statedb.Exist(addr)
+		if acc.created || acc.suicided || acc.nonceDirty || acc.codeDirty || exists != existed[addr] {
+			values[versionKey{kind: versionAccount, addr: addr}] = &versionedValue{
+				writer: index,
+				account: &versionedAccount{
+					exists:   exists,
+					nonce:    statedb.GetNonce(addr),
+					code:     statedb.GetCode(addr),
+					codeHash: statedb.GetCodeHash(addr),
+				},
+			}
+		}
+		if acc.balanceDirty || exists != existed[addr] {
+			values[versionKey{kind: versionBalance, addr: addr}] = &versionedValue{
+				writer:  index,
+				balance: new(big.Int).Set(statedb.GetBalance(addr)),
+			}
+		}
+		if acc.created || (existed[addr] && !exists) {
+			values[versionKey{kind: versionReset, addr: addr}] = &versionedValue{writer: index}
+		}
+		for key := range acc.storage {
+			values[versionKey{kind: versionSlot, addr: addr, slot: key}] = &versionedValue{
+				writer: index,
+				slot:   statedb.GetState(addr, key),
+			}
+		}
+	}
+	return values
+}
+
+// modified returns the addresses of the accounts accessed by the transaction
+// in a deterministic order.
+func (s *speculativeState) modified() []common.Address {
+	addrs := make([]common.Address, 0, len(s.accounts))
+	for addr := range s.accounts {
+		addrs = append(addrs, addr)
+	}
+	if s.ripemdTouched && s.accounts[ripemd] == nil {
+		addrs = append(addrs, ripemd)
+		s.account(ripemd)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	return addrs
+}
+
+// ripemd is the precompile kept touched by the state journal across reverts.
+var ripemd = common.BytesToAddress([]byte{3})
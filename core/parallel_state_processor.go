@@ -0,0 +1,207 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	parallelTxsMeter     = metrics.NewRegisteredMeter("chain/parallel/txs", nil)
+	parallelReexecsMeter = metrics.NewRegisteredMeter("chain/parallel/reexecs", nil)
+)
+
+// ParallelStateProcessor is a Processor executing the transactions of a block
+// optimistically in parallel. Every transaction is executed speculatively on
+// top of the state committed by the previous ones, recording the versions of
+// everything it reads. The transactions are then committed in order, with the
+// ones which read a state since overwritten being executed again. The resulting
+// state and receipts are identical to the ones of the StateProcessor.
+//
+// ParallelStateProcessor implements Processor.
+type ParallelStateProcessor struct {
+	config  *params.ChainConfig // Chain configuration options
+	bc      *BlockChain         // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	serial  *StateProcessor     // Fallback processor for blocks not worth parallelising
+	workers int                 // Number of transactions executed concurrently
+}
+
+// NewParallelStateProcessor initialises a new ParallelStateProcessor executing
+// up to the given number of transactions concurrently.
+func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine, workers int) *ParallelStateProcessor {
+	return &ParallelStateProcessor{
+		config:  config,
+		bc:      bc,
+		engine:  engine,
+		serial:  NewStateProcessor(config, bc, engine),
+		workers: workers,
+	}
+}
+
+// speculation is the outcome of the speculative execution of a transaction.
+type speculation struct {
+	msg    types.Message
+	state  *speculativeState
+	result *ExecutionResult
+	err    error
+}
+
+// Process processes the state changes according to the Ethereum rules like the
+// StateProcessor, executing the transactions in parallel. Blocks traced by the
+// vm config are processed serially.
+func (p *ParallelStateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	txs := block.Transactions()
+	if p.workers < 2 || len(txs) < 2 || cfg.Debug {
+		return p.serial.Process(block, statedb, cfg)
+	}
+	var (
+		receipts    types.Receipts
+		usedGas     = new(uint64)
+		header      = block.Header()
+		blockHash   = block.Hash()
+		blockNumber = block.Number()
+		allLogs     []*types.Log
+		gp          = new(GasPool).AddGas(block.GasLimit())
+		signer      = types.MakeSigner(p.config, header.Number)
+		versioned   = newVersionedState()
+	)
+	// Mutate the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	// execute runs a transaction speculatively on the executor's own copy of the
+	// state at the beginning of the block
+	execute := func(index int, evm *vm.EVM, base *state.StateDB) (spec *speculation) {
+		spec = new(speculation)
+		spec.msg, spec.err = txs[index].AsMessage(signer, header.BaseFee)
+		if spec.err != nil {
+			return spec
+		}
+		spec.state = newSpeculativeState(base, versioned)
+		defer func() {
+			// Inconsistent reads may lead to any kind of failure, the transaction
+			// will be executed again anyway since its reads are stale
+			if r := recover(); r != nil {
+				if spec.state.valid() {
+					panic(r)
+				}
+				spec.result, spec.err = nil, fmt.Errorf("speculative execution failed: %v", r)
+			}
+		}()
+		evm.Reset(NewEVMTxContext(spec.msg), spec.state)
+		spec.result, spec.err = ApplyMessage(evm, spec.msg, new(GasPool).AddGas(block.GasLimit()))
+		return spec
+	}
+	newEVM := func() *vm.EVM {
+		return vm.NewEVM(NewEVMBlockContext(header, p.bc, nil), vm.TxContext{}, statedb, p.config, cfg)
+	}
+	// Start the speculative executors, running ahead of the commits below
+	var (
+		workers = p.workers
+		results = make([]chan *speculation, len(txs))
+		next    = int32(-1)
+		abort   = make(chan struct{})
+		pend    sync.WaitGroup
+	)
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	for i := range results {
+		results[i] = make(chan *speculation, 1)
+	}
+	pend.Add(workers)
+	for i := 0; i < workers; i++ {
+		base := statedb.Copy()
+		go func() {
+			defer pend.Done()
+
+			evm := newEVM()
+			for {
+				index := int(atomic.AddInt32(&next, 1))
+				if index >= len(txs) {
+					return
+				}
+				select {
+				case <-abort:
+					return
+				default:
+				}
+				results[index] <- execute(index, evm, base)
+			}
+		}()
+	}
+	defer func() {
+		close(abort)
+		pend.Wait()
+	}()
+	// Commit the transactions in order, executing again the stale ones
+	var (
+		evm     = newEVM()
+		base    = statedb.Copy()
+		reexecs int
+	)
+	for i, tx := range txs {
+		spec := <-results[i]
+		if spec.state != nil && !spec.state.valid() {
+			spec = execute(i, evm, base)
+			reexecs++
+		}
+		if spec.err == nil && gp.Gas() < spec.msg.Gas() {
+			spec.err = ErrGasLimitReached
+		}
+		if spec.err != nil {
+			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), spec.err)
+		}
+		gp.SubGas(spec.result.UsedGas)
+
+		// Replay the transaction onto the block state and publish its writes
+		statedb.Prepare(tx.Hash(), i)
+
+		modified := spec.state.modified()
+		existed := make(map[common.Address]bool, len(modified))
+		for _, addr := range modified {
+			existed[addr] = statedb.Exist(addr)
+		}
+		spec.state.apply(statedb, p.config.IsEIP158(blockNumber))
+		receipt := finaliseTransaction(spec.msg, p.config, statedb, blockNumber, blockHash, tx, usedGas, spec.result)
+		versioned.set(spec.state.written(statedb, existed, i))
+
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	parallelTxsMeter.Mark(int64(len(txs)))
+	parallelReexecsMeter.Mark(int64(reexecs))
+	log.Trace("Processed block in parallel", "number", blockNumber, "txs", len(txs), "reexecs", reexecs)
+
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	p.engine.Finalize(p.bc, header, statedb, txs, block.Uncles())
+
+	return receipts, allLogs, *usedGas, nil
+}
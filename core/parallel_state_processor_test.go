@@ -0,0 +1,288 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	parallelCoinbase = common.HexToAddress("0xc0ffee")
+	parallelEmpty    = common.HexToAddress("0xe0") // Empty account in the genesis state
+
+	// parallelCounter increments slot 0 and logs the new value
+	parallelCounter = common.HexToAddress("0xc0")
+	// parallelReader stores the balance of the coinbase in slot 0
+	parallelReader = common.HexToAddress("0xc1")
+	// parallelSuicider self destructs, sending its balance to the caller
+	parallelSuicider = common.HexToAddress("0xc2")
+	// parallelToucher calls the address in the first input word with no value and
+	// the gas in the second word, then stores the code hash (or size before
+	// Constantinople) of the address at the slot of the address
+	parallelToucher = common.HexToAddress("0xc3")
+	// parallelReverter writes slot 0 and reverts
+	parallelReverter = common.HexToAddress("0xc4")
+)
+
+// parallelTestChain generates a chain with random transactions exercising the
+// corner cases of the state, returning the genesis block along with the blocks.
+func parallelTestChain(t *testing.T, config *params.ChainConfig, seed int64, blocks, txs int) (*Genesis, []*types.Block, []*ecdsa.PrivateKey) {
+	var (
+		rnd    = rand.New(rand.NewSource(seed))
+		keys   = make([]*ecdsa.PrivateKey, 16)
+		alloc  = GenesisAlloc{parallelEmpty: {Balance: new(big.Int)}}
+		funds  = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+		hashOp = "3b" // EXTCODESIZE
+	)
+	for i := range keys {
+		keys[i], _ = crypto.ToECDSA(crypto.Keccak256([]byte{byte(i)}))
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = GenesisAccount{Balance: funds}
+	}
+	if config.IsConstantinople(common.Big0) {
+		hashOp = "3f" // EXTCODEHASH
+	}
+	alloc[parallelCounter] = GenesisAccount{Balance: new(big.Int), Code: common.FromHex("6000546001018060005560005260206000a000")}
+	alloc[parallelReader] = GenesisAccount{Balance: new(big.Int), Code: common.FromHex("413160005500")}
+	alloc[parallelSuicider] = GenesisAccount{Balance: big.NewInt(1000), Code: common.FromHex("33ff"), Storage: map[common.Hash]common.Hash{{}: {1}}}
+	alloc[parallelToucher] = GenesisAccount{Balance: new(big.Int), Code: common.FromHex("60006000600060006000600035602035f150600035" + hashOp + "6000355500")}
+	alloc[parallelReverter] = GenesisAccount{Balance: new(big.Int), Code: common.FromHex("600160005560006000fd")}
+
+	genesis := &Genesis{Config: config, Alloc: alloc, GasLimit: 30000000}
+	db := rawdb.NewMemoryDatabase()
+	gblock := genesis.MustCommit(db)
+
+	var created []common.Address
+	chain, _ := GenerateChain(config, gblock, ethash.NewFaker(), db, blocks, func(n int, b *BlockGen) {
+		b.SetCoinbase(parallelCoinbase)
+		signer := types.MakeSigner(config, b.Number())
+		gasPrice := big.NewInt(params.GWei)
+		if b.header.BaseFee != nil {
+			gasPrice.Add(gasPrice, b.header.BaseFee)
+		}
+		for i := 0; i < txs; i++ {
+			var (
+				key    = keys[rnd.Intn(len(keys))]
+				from   = crypto.PubkeyToAddress(key.PublicKey)
+				to     *common.Address
+				value  = new(big.Int)
+				gas    = uint64(100000)
+				input  []byte
+				target common.Address
+			)
+			switch op := rnd.Intn(10); op {
+			case 0: // Transfer to another sender or a fresh account
+				target = crypto.PubkeyToAddress(keys[rnd.Intn(len(keys))].PublicKey)
+				if rnd.Intn(2) == 0 {
+					target = common.BigToAddress(big.NewInt(0x10000 + rnd.Int63n(8)))
+				}
+				to, value = &target, big.NewInt(1+rnd.Int63n(1000))
+			case 1:
+				to = &parallelCounter
+			case 2:
+				to = &parallelReader
+			case 3: // Touch an empty, destructed, missing or precompile account
+				targets := []common.Address{parallelEmpty, parallelSuicider, parallelCounter, common.HexToAddress("0xdead"), common.BytesToAddress([]byte{3}), common.BytesToAddress([]byte{1})}
+				target = targets[rnd.Intn(len(targets))]
+				callGas := uint64(50000)
+				if rnd.Intn(2) == 0 {
+					callGas = 1 // Out of gas in the callee, reverting the touch
+				}
+				to, input = &parallelToucher, append(common.LeftPadBytes(target[:], 32), common.LeftPadBytes(new(big.Int).SetUint64(callGas).Bytes(), 32)...)
+			case 4:
+				to = &parallelSuicider
+			case 5: // Resurrect the self destructed account or fund the empty one
+				targets := []common.Address{parallelSuicider, parallelEmpty}
+				target = targets[rnd.Intn(len(targets))]
+				to, value = &target, big.NewInt(1+rnd.Int63n(1000))
+			case 6:
+				to = &parallelReverter
+			case 7: // Create a contract writing a slot, or fund a created one
+				if len(created) > 0 && rnd.Intn(2) == 0 {
+					target = created[rnd.Intn(len(created))]
+					to, value = &target, big.NewInt(1+rnd.Int63n(1000))
+				} else {
+					input = common.FromHex("600160015500")
+					created = append(created, crypto.CreateAddress(from, b.TxNonce(from)))
+				}
+			case 8:
+				to, value = &parallelCoinbase, big.NewInt(1+rnd.Int63n(1000))
+			case 9: // Out of gas in the counter
+				to, gas = &parallelCounter, 21000+100
+			}
+			var tx *types.Transaction
+			if to == nil {
+				tx = types.NewContractCreation(b.TxNonce(from), value, gas, gasPrice, input)
+			} else {
+				tx = types.NewTransaction(b.TxNonce(from), *to, value, gas, gasPrice, input)
+			}
+			tx, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			b.AddTx(tx)
+		}
+	})
+	return genesis, chain, keys
+}
+
+// processBoth processes the block on top of the given state with both the
+// serial and the parallel processor, checking that the results match.
+func processBoth(t *testing.T, chain *BlockChain, block *types.Block, root common.Hash) error {
+	t.Helper()
+
+	var (
+		serial   = NewStateProcessor(chain.chainConfig, chain, chain.engine)
+		parallel = NewParallelStateProcessor(chain.chainConfig, chain, chain.engine, 4)
+		eip158   = chain.chainConfig.IsEIP158(block.Number())
+	)
+	serialState, _ := state.New(root, chain.stateCache, nil)
+	serialReceipts, serialLogs, serialGas, serialErr := serial.Process(block, serialState, vm.Config{})
+
+	parallelState, _ := state.New(root, chain.stateCache, nil)
+	parallelReceipts, parallelLogs, parallelGas, parallelErr := parallel.Process(block, parallelState, vm.Config{})
+
+	if serialErr != nil || parallelErr != nil {
+		if serialErr == nil || parallelErr == nil || serialErr.Error() != parallelErr.Error() {
+			t.Fatalf("block %d: error mismatch: serial %v, parallel %v", block.NumberU64(), serialErr, parallelErr)
+		}
+		return serialErr
+	}
+	if serialGas != parallelGas {
+		t.Errorf("block %d: gas used mismatch: serial %d, parallel %d", block.NumberU64(), serialGas, parallelGas)
+	}
+	have, _ := json.Marshal(parallelReceipts)
+	want, _ := json.Marshal(serialReceipts)
+	if !bytes.Equal(have, want) {
+		t.Errorf("block %d: receipts mismatch:\nserial   %s\nparallel %s", block.NumberU64(), want, have)
+	}
+	have, _ = json.Marshal(parallelLogs)
+	want, _ = json.Marshal(serialLogs)
+	if !bytes.Equal(have, want) {
+		t.Errorf("block %d: logs mismatch:\nserial   %s\nparallel %s", block.NumberU64(), want, have)
+	}
+	if have, want := parallelState.IntermediateRoot(eip158), serialState.IntermediateRoot(eip158); have != want {
+		t.Errorf("block %d: state root mismatch: serial %x, parallel %x", block.NumberU64(), want, have)
+	}
+	return nil
+}
+
+func testParallelProcessor(t *testing.T, config *params.ChainConfig) {
+	for seed := int64(0); seed < 4; seed++ {
+		genesis, blocks, _ := parallelTestChain(t, config, seed, 8, 40)
+
+		// Process every block with both processors on top of the canonical state
+		db := rawdb.NewMemoryDatabase()
+		genesis.MustCommit(db)
+		chain, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		defer chain.Stop()
+
+		parent := chain.Genesis()
+		for _, block := range blocks {
+			if err := processBoth(t, chain, block, parent.Root()); err != nil {
+				t.Fatalf("seed %d: failed to process block %d: %v", seed, block.NumberU64(), err)
+			}
+			if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+				t.Fatalf("seed %d: failed to insert block %d: %v", seed, block.NumberU64(), err)
+			}
+			parent = block
+		}
+		// Import the chain with the parallel processor, validating the results
+		db = rawdb.NewMemoryDatabase()
+		genesis.MustCommit(db)
+		pchain, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+		defer pchain.Stop()
+
+		pchain.processor = NewParallelStateProcessor(config, pchain, pchain.engine, 4)
+		if n, err := pchain.InsertChain(blocks); err != nil {
+			t.Fatalf("seed %d: failed to insert block %d in parallel: %v", seed, n, err)
+		}
+	}
+}
+
+func TestParallelProcessorLondon(t *testing.T) {
+	testParallelProcessor(t, params.TestChainConfig)
+}
+
+func TestParallelProcessorSpuriousDragon(t *testing.T) {
+	testParallelProcessor(t, &params.ChainConfig{
+		ChainID:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		Ethash:         new(params.EthashConfig),
+	})
+}
+
+func TestParallelProcessorHomestead(t *testing.T) {
+	testParallelProcessor(t, &params.ChainConfig{
+		ChainID:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		Ethash:         new(params.EthashConfig),
+	})
+}
+
+// Tests that invalid blocks are rejected with the same error by both processors.
+func TestParallelProcessorInvalidBlock(t *testing.T) {
+	genesis, blocks, keys := parallelTestChain(t, params.TestChainConfig, 0, 1, 20)
+
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	var (
+		block  = blocks[0]
+		txs    = block.Transactions()
+		signer = types.LatestSigner(params.TestChainConfig)
+		root   = chain.Genesis().Root()
+	)
+	// Reversed transactions make the nonces of repeated senders invalid
+	reversed := make(types.Transactions, len(txs))
+	for i, tx := range txs {
+		reversed[len(txs)-1-i] = tx
+	}
+	if err := processBoth(t, chain, block.WithBody(reversed, nil), root); !errors.Is(err, ErrNonceTooHigh) {
+		t.Errorf("reversed transactions: error mismatch: have %v, want %v", err, ErrNonceTooHigh)
+	}
+
+	// A transaction exceeding the remaining block gas
+	tx, _ := types.SignTx(types.NewTransaction(0, parallelCounter, new(big.Int), block.GasLimit(), big.NewInt(10*params.GWei), nil), signer, keys[0])
+	if err := processBoth(t, chain, block.WithBody(append(types.Transactions{txs[0]}, tx), nil), root); !errors.Is(err, ErrGasLimitReached) {
+		t.Errorf("block gas exceeded: error mismatch: have %v, want %v", err, ErrGasLimitReached)
+	}
+	// A transaction with an insufficient balance
+	tx, _ = types.SignTx(types.NewTransaction(0, parallelCounter, new(big.Int).Lsh(common.Big1, 100), 100000, big.NewInt(10*params.GWei), nil), signer, keys[1])
+	if err := processBoth(t, chain, block.WithBody(append(types.Transactions{txs[0]}, tx), nil), root); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("insufficient balance: error mismatch: have %v, want %v", err, ErrInsufficientFunds)
+	}
+}
@@ -311,6 +311,7 @@ func TestHeadStorage(t *testing.T) {
 	blockHead := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block header")})
 	blockFull := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block full")})
 	blockFast := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block fast")})
+	blockFinal := types.NewBlockWithHeader(&types.Header{Extra: []byte("test block finalized")})
 
 	// Check that no head entries are in a pristine database
 	if entry := ReadHeadHeaderHash(db); entry != (common.Hash{}) {
@@ -322,10 +323,14 @@ func TestHeadStorage(t *testing.T) {
 	if entry := ReadHeadFastBlockHash(db); entry != (common.Hash{}) {
 		t.Fatalf("Non fast head block entry returned: %v", entry)
 	}
+	if entry := ReadFinalizedBlockHash(db); entry != (common.Hash{}) {
+		t.Fatalf("Non finalized block entry returned: %v", entry)
+	}
 	// Assign separate entries for the head header and block
 	WriteHeadHeaderHash(db, blockHead.Hash())
 	WriteHeadBlockHash(db, blockFull.Hash())
 	WriteHeadFastBlockHash(db, blockFast.Hash())
+	WriteFinalizedBlockHash(db, blockFinal.Hash())
 
 	// Check that both heads are present, and different (i.e. two heads maintained)
 	if entry := ReadHeadHeaderHash(db); entry != blockHead.Hash() {
@@ -337,6 +342,13 @@ func TestHeadStorage(t *testing.T) {
 	if entry := ReadHeadFastBlockHash(db); entry != blockFast.Hash() {
 		t.Fatalf("Fast head block hash mismatch: have %v, want %v", entry, blockFast.Hash())
 	}
+	if entry := ReadFinalizedBlockHash(db); entry != blockFinal.Hash() {
+		t.Fatalf("Finalized block hash mismatch: have %v, want %v", entry, blockFinal.Hash())
+	}
+	DeleteFinalizedBlockHash(db)
+	if entry := ReadFinalizedBlockHash(db); entry != (common.Hash{}) {
+		t.Fatalf("Deleted finalized block entry returned: %v", entry)
+	}
 }
 
 // Tests that receipts associated with a single block can be stored and retrieved.
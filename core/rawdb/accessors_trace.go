@@ -0,0 +1,147 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// TraceIndexEntry is the position of a transaction trace touching an address.
+type TraceIndexEntry struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxIndex     uint32
+	TraceIndex  uint32
+}
+
+// ReadTraceIndexHead retrieves the hash of the latest block whose traces were
+// indexed, or an empty hash if the trace index doesn't exist.
+func ReadTraceIndexHead(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(traceIndexHeadKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteTraceIndexHead stores the hash of the latest block whose traces were
+// indexed.
+func WriteTraceIndexHead(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(traceIndexHeadKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store the trace index head", "err", err)
+	}
+}
+
+// ReadTraceIndexTail retrieves the number of the oldest block whose traces were
+// indexed.
+func ReadTraceIndexTail(db ethdb.KeyValueReader) *uint64 {
+	data, _ := db.Get(traceIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTraceIndexTail stores the number of the oldest block whose traces were
+// indexed.
+func WriteTraceIndexTail(db ethdb.KeyValueWriter, number uint64) {
+	if err := db.Put(traceIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store the trace index tail", "err", err)
+	}
+}
+
+// WriteTraceIndexEntry stores the position of a transaction trace touching the
+// given address.
+func WriteTraceIndexEntry(db ethdb.KeyValueWriter, address common.Address, entry TraceIndexEntry) {
+	if err := db.Put(traceIndexKey(address, entry.BlockNumber, entry.TxIndex, entry.TraceIndex), entry.BlockHash.Bytes()); err != nil {
+		log.Crit("Failed to store trace index entry", "err", err)
+	}
+}
+
+// ReadTraceIndexEntries retrieves the positions of all the transaction traces
+// touching the given address within the given block range (both inclusive),
+// ordered by block, transaction and trace. Entries of reorged blocks are not
+// removed from the index, it's up to the caller to filter them by block hash.
+func ReadTraceIndexEntries(db ethdb.Iteratee, address common.Address, from uint64, to uint64) []TraceIndexEntry {
+	var (
+		prefix  = append(append([]byte{}, traceIndexPrefix...), address.Bytes()...)
+		entries []TraceIndexEntry
+	)
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+16 || len(it.Value()) != common.HashLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(prefix):])
+		if number > to {
+			break
+		}
+		entries = append(entries, TraceIndexEntry{
+			BlockNumber: number,
+			BlockHash:   common.BytesToHash(it.Value()),
+			TxIndex:     binary.BigEndian.Uint32(key[len(prefix)+8:]),
+			TraceIndex:  binary.BigEndian.Uint32(key[len(prefix)+12:]),
+		})
+	}
+	if it.Error() != nil {
+		log.Error("Failed to iterate trace index", "address", address, "err", it.Error())
+	}
+	return entries
+}
+
+// WriteTraceIndexGap records that the traces of the given block could not be
+// indexed, even though the block is within the range of the trace index.
+func WriteTraceIndexGap(db ethdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Put(traceIndexGapKey(number), hash.Bytes()); err != nil {
+		log.Crit("Failed to store trace index gap", "err", err)
+	}
+}
+
+// ReadTraceIndexGaps retrieves the blocks within the given block range (both
+// inclusive) whose traces could not be indexed, mapping their numbers to their
+// hashes. Gaps of reorged blocks are not removed from the index, it's up to the
+// caller to filter them by block hash.
+func ReadTraceIndexGaps(db ethdb.Iteratee, from uint64, to uint64) map[uint64]common.Hash {
+	gaps := make(map[uint64]common.Hash)
+
+	it := db.NewIterator(traceIndexGapPrefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(traceIndexGapPrefix)+8 || len(it.Value()) != common.HashLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(key[len(traceIndexGapPrefix):])
+		if number > to {
+			break
+		}
+		gaps[number] = common.BytesToHash(it.Value())
+	}
+	if it.Error() != nil {
+		log.Error("Failed to iterate trace index gaps", "err", it.Error())
+	}
+	return gaps
+}
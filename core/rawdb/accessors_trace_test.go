@@ -0,0 +1,89 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that trace index entries can be stored and retrieved by address and
+// block range.
+func TestTraceIndexStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	if head := ReadTraceIndexHead(db); head != (common.Hash{}) {
+		t.Fatalf("non existent trace index head returned: %x", head)
+	}
+	WriteTraceIndexHead(db, common.Hash{0x01})
+	if head := ReadTraceIndexHead(db); head != (common.Hash{0x01}) {
+		t.Fatalf("trace index head mismatch: have %x, want %x", head, common.Hash{0x01})
+	}
+	if tail := ReadTraceIndexTail(db); tail != nil {
+		t.Fatalf("non existent trace index tail returned: %d", *tail)
+	}
+	WriteTraceIndexTail(db, 10)
+	if tail := ReadTraceIndexTail(db); tail == nil || *tail != 10 {
+		t.Fatalf("trace index tail mismatch: have %v, want %d", tail, 10)
+	}
+	var (
+		addr1   = common.Address{0x01}
+		addr2   = common.Address{0x02}
+		entries = []TraceIndexEntry{
+			{BlockNumber: 1, BlockHash: common.Hash{0x01}, TxIndex: 0, TraceIndex: 0},
+			{BlockNumber: 1, BlockHash: common.Hash{0x01}, TxIndex: 0, TraceIndex: 3},
+			{BlockNumber: 1, BlockHash: common.Hash{0x01}, TxIndex: 2, TraceIndex: 1},
+			{BlockNumber: 5, BlockHash: common.Hash{0x05}, TxIndex: 1, TraceIndex: 0},
+			{BlockNumber: 256, BlockHash: common.Hash{0x06}, TxIndex: 0, TraceIndex: 0},
+		}
+	)
+	for i := len(entries) - 1; i >= 0; i-- {
+		WriteTraceIndexEntry(db, addr1, entries[i])
+	}
+	WriteTraceIndexEntry(db, addr2, TraceIndexEntry{BlockNumber: 2, BlockHash: common.Hash{0x02}})
+
+	tests := []struct {
+		from, to uint64
+		want     []TraceIndexEntry
+	}{
+		{0, 1000, entries},
+		{1, 1, entries[:3]},
+		{2, 5, entries[3:4]},
+		{6, 255, nil},
+		{5, 256, entries[3:]},
+	}
+	for i, tt := range tests {
+		if have := ReadTraceIndexEntries(db, addr1, tt.from, tt.to); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: entries mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	if have := ReadTraceIndexEntries(db, common.Address{0x03}, 0, 1000); len(have) != 0 {
+		t.Errorf("entries returned for unknown address: %v", have)
+	}
+	// Gaps must be retrievable by block range too
+	WriteTraceIndexGap(db, 3, common.Hash{0x03})
+	WriteTraceIndexGap(db, 300, common.Hash{0x30})
+
+	if have, want := ReadTraceIndexGaps(db, 0, 1000), map[uint64]common.Hash{3: {0x03}, 300: {0x30}}; !reflect.DeepEqual(have, want) {
+		t.Errorf("gaps mismatch: have %v, want %v", have, want)
+	}
+	if have, want := ReadTraceIndexGaps(db, 4, 299), map[uint64]common.Hash{}; !reflect.DeepEqual(have, want) {
+		t.Errorf("gaps mismatch: have %v, want %v", have, want)
+	}
+}
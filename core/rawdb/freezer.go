@@ -328,20 +328,32 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 			continue
 		}
 		number := ReadHeaderNumber(nfdb, hash)
-		threshold := atomic.LoadUint64(&f.threshold)
-
-		switch {
-		case number == nil:
+		if number == nil {
 			log.Error("Current full block number unavailable", "hash", hash)
 			backoff = true
 			continue
-
-		case *number < threshold:
+		}
+		// Blocks older than the immutability threshold can be frozen, as well as
+		// any canonical block already finalized by the external consensus engine.
+		var (
+			threshold = atomic.LoadUint64(&f.threshold)
+			limit     uint64
+		)
+		if *number >= threshold {
+			limit = *number - threshold
+		}
+		if final := ReadFinalizedBlockHash(nfdb); final != (common.Hash{}) {
+			if n := ReadHeaderNumber(nfdb, final); n != nil && *n > limit && *n <= *number && ReadCanonicalHash(nfdb, *n) == final {
+				limit = *n
+			}
+		}
+		switch {
+		case limit == 0:
 			log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", threshold)
 			backoff = true
 			continue
 
-		case *number-threshold <= f.frozen:
+		case limit <= f.frozen:
 			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", f.frozen)
 			backoff = true
 			continue
@@ -353,7 +365,6 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 			continue
 		}
 		// Seems we have data ready to be frozen, process in usable batches
-		limit := *number - threshold
 		if limit-f.frozen > freezerBatchLimit {
 			limit = f.frozen + freezerBatchLimit
 		}
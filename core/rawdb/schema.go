@@ -39,6 +39,9 @@ var (
 	// headFastBlockKey tracks the latest known incomplete block's hash during fast sync.
 	headFastBlockKey = []byte("LastFast")
 
+	// headFinalizedBlockKey tracks the latest block finalized by the external consensus engine.
+	headFinalizedBlockKey = []byte("LastFinalized")
+
 	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
 	lastPivotKey = []byte("LastPivot")
 
@@ -69,6 +72,12 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// traceIndexHeadKey tracks the latest block whose traces were indexed.
+	traceIndexHeadKey = []byte("TraceIndexHead")
+
+	// traceIndexTailKey tracks the oldest block whose traces were indexed.
+	traceIndexTailKey = []byte("TraceIndexTail")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -90,6 +99,9 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	traceIndexPrefix    = []byte("ti") // traceIndexPrefix + address + num (uint64 big endian) + tx index (uint32 big endian) + trace index (uint32 big endian) -> block hash
+	traceIndexGapPrefix = []byte("tg") // traceIndexGapPrefix + num (uint64 big endian) -> block hash
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -207,6 +219,22 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// traceIndexKey = traceIndexPrefix + address + num (uint64 big endian) + tx index (uint32 big endian) + trace index (uint32 big endian)
+func traceIndexKey(address common.Address, number uint64, txIndex uint32, traceIndex uint32) []byte {
+	key := append(append(traceIndexPrefix, address.Bytes()...), make([]byte, 16)...)
+
+	binary.BigEndian.PutUint64(key[len(traceIndexPrefix)+common.AddressLength:], number)
+	binary.BigEndian.PutUint32(key[len(traceIndexPrefix)+common.AddressLength+8:], txIndex)
+	binary.BigEndian.PutUint32(key[len(traceIndexPrefix)+common.AddressLength+12:], traceIndex)
+
+	return key
+}
+
+// traceIndexGapKey = traceIndexGapPrefix + num (uint64 big endian)
+func traceIndexGapKey(number uint64) []byte {
+	return append(append([]byte{}, traceIndexGapPrefix...), encodeBlockNumber(number)...)
+}
+
 // preimageKey = preimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(preimagePrefix, hash.Bytes()...)
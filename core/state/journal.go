@@ -138,6 +138,11 @@ type (
 		address *common.Address
 		slot    *common.Hash
 	}
+	// Changes to the transient storage
+	transientStorageChange struct {
+		account       *common.Address
+		key, prevalue common.Hash
+	}
 )
 
 func (ch createObjectChange) revert(s *StateDB) {
@@ -267,3 +272,85 @@ func (ch accessListAddSlotChange) revert(s *StateDB) {
 func (ch accessListAddSlotChange) dirtied() *common.Address {
 	return nil
 }
+
+func (ch transientStorageChange) revert(s *StateDB) {
+	s.transientStorage.Set(*ch.account, ch.key, ch.prevalue)
+}
+
+func (ch transientStorageChange) dirtied() *common.Address {
+	return nil
+}
+
+// JournalAccount is the original state of an account modified since the journal
+// was last cleared, reconstructed from the journal entries. Only the modified
+// fields are populated, the rest of them are unchanged.
+type JournalAccount struct {
+	Created bool     // Whether the account did not exist before the modifications
+	Balance *big.Int // Original balance, nil if unchanged
+	Nonce   *uint64  // Original nonce, nil if unchanged
+	Code    *[]byte  // Original code, nil if unchanged
+
+	Storage map[common.Hash]common.Hash // Original values of the modified slots
+}
+
+// JournalAccounts returns the original state of all the accounts modified since
+// the journal was last cleared, i.e. since the last call to Finalise. Reverted
+// modifications are not included.
+func (s *StateDB) JournalAccounts() map[common.Address]*JournalAccount {
+	accounts := make(map[common.Address]*JournalAccount)
+	account := func(addr common.Address) *JournalAccount {
+		if accounts[addr] == nil {
+			accounts[addr] = &JournalAccount{Storage: make(map[common.Hash]common.Hash)}
+		}
+		return accounts[addr]
+	}
+	// Iterate the journal in order, so the first change of each field holds its
+	// original value
+	for _, entry := range s.journal.entries {
+		switch ch := entry.(type) {
+		case createObjectChange:
+			if _, ok := accounts[*ch.account]; !ok {
+				account(*ch.account).Created = true
+			}
+		case resetObjectChange:
+			if _, ok := accounts[ch.prev.address]; ok {
+				continue
+			}
+			acc := account(ch.prev.address)
+			if ch.prev.deleted {
+				acc.Created = true
+				continue
+			}
+			code := ch.prev.Code(s.db)
+			nonce := ch.prev.Nonce()
+			acc.Balance, acc.Nonce, acc.Code = new(big.Int).Set(ch.prev.Balance()), &nonce, &code
+
+		case suicideChange:
+			if acc := account(*ch.account); acc.Balance == nil {
+				acc.Balance = new(big.Int).Set(ch.prevbalance)
+			}
+		case balanceChange:
+			if acc := account(*ch.account); acc.Balance == nil {
+				acc.Balance = new(big.Int).Set(ch.prev)
+			}
+		case nonceChange:
+			if acc := account(*ch.account); acc.Nonce == nil {
+				nonce := ch.prev
+				acc.Nonce = &nonce
+			}
+		case codeChange:
+			if acc := account(*ch.account); acc.Code == nil {
+				code := common.CopyBytes(ch.prevcode)
+				acc.Code = &code
+			}
+		case storageChange:
+			acc := account(*ch.account)
+			if _, ok := acc.Storage[ch.key]; !ok {
+				acc.Storage[ch.key] = ch.prevalue
+			}
+		case touchChange:
+			account(*ch.account)
+		}
+	}
+	return accounts
+}
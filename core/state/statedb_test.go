@@ -915,3 +915,51 @@ func TestStateDBAccessList(t *testing.T) {
 		t.Fatalf("expected empty, got %d", got)
 	}
 }
+
+// Tests that the original state of the modified accounts is reconstructed from
+// the journal, ignoring reverted modifications.
+func TestJournalAccounts(t *testing.T) {
+	state, _ := New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+
+	var (
+		existing = common.BytesToAddress([]byte("existing"))
+		created  = common.BytesToAddress([]byte("created"))
+		reverted = common.BytesToAddress([]byte("reverted"))
+		slot     = common.Hash{0x01}
+	)
+	state.SetBalance(existing, big.NewInt(1))
+	state.SetNonce(existing, 2)
+	state.SetCode(existing, []byte{0x03})
+	state.SetState(existing, slot, common.Hash{0x04})
+	state.Finalise(true)
+
+	if accounts := state.JournalAccounts(); len(accounts) != 0 {
+		t.Fatalf("journal accounts not cleared by finalisation: %v", accounts)
+	}
+	// Modify the existing account multiple times and create a new one
+	state.AddBalance(existing, big.NewInt(10))
+	state.AddBalance(existing, big.NewInt(10))
+	state.SetState(existing, slot, common.Hash{0x05})
+	state.SetState(existing, slot, common.Hash{0x06})
+	state.SetBalance(created, big.NewInt(1))
+
+	id := state.Snapshot()
+	state.SetBalance(reverted, big.NewInt(1))
+	state.SetNonce(existing, 10)
+	state.RevertToSnapshot(id)
+
+	accounts := state.JournalAccounts()
+	if len(accounts) != 2 {
+		t.Fatalf("journal account count mismatch: have %d, want %d", len(accounts), 2)
+	}
+	acc := accounts[existing]
+	if acc == nil || acc.Created || acc.Balance.Cmp(big.NewInt(1)) != 0 || acc.Nonce != nil || acc.Code != nil {
+		t.Fatalf("existing account mismatch: %+v", acc)
+	}
+	if want := map[common.Hash]common.Hash{slot: {0x04}}; !reflect.DeepEqual(acc.Storage, want) {
+		t.Errorf("existing account storage mismatch: have %v, want %v", acc.Storage, want)
+	}
+	if acc := accounts[created]; acc == nil || !acc.Created {
+		t.Errorf("created account mismatch: %+v", acc)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// transientStorage is the per-transaction storage introduced by EIP-1153. It
+// is discarded at the end of every transaction and never persisted.
+type transientStorage map[common.Address]Storage
+
+// newTransientStorage creates an empty transient storage.
+func newTransientStorage() transientStorage {
+	return make(transientStorage)
+}
+
+// Set sets the transient storage slot of an address to the given value,
+// removing the slot if the value is zero.
+func (t transientStorage) Set(addr common.Address, key, value common.Hash) {
+	if value == (common.Hash{}) {
+		if storage, ok := t[addr]; ok {
+			delete(storage, key)
+			if len(storage) == 0 {
+				delete(t, addr)
+			}
+		}
+		return
+	}
+	if _, ok := t[addr]; !ok {
+		t[addr] = make(Storage)
+	}
+	t[addr][key] = value
+}
+
+// Get retrieves a transient storage slot of an address.
+func (t transientStorage) Get(addr common.Address, key common.Hash) common.Hash {
+	storage, ok := t[addr]
+	if !ok {
+		return common.Hash{}
+	}
+	return storage[key]
+}
+
+// Copy creates a deep copy of the transient storage.
+func (t transientStorage) Copy() transientStorage {
+	cpy := make(transientStorage, len(t))
+	for addr, storage := range t {
+		cpy[addr] = storage.Copy()
+	}
+	return cpy
+}
@@ -111,6 +111,7 @@ var (
 	invalidTxMeter     = metrics.NewRegisteredMeter("txpool/invalid", nil)
 	underpricedTxMeter = metrics.NewRegisteredMeter("txpool/underpriced", nil)
 	overflowedTxMeter  = metrics.NewRegisteredMeter("txpool/overflowed", nil)
+	rejectedTxMeter    = metrics.NewRegisteredMeter("txpool/rejected", nil) // Rejected by admission policies
 
 	pendingGauge = metrics.NewRegisteredGauge("txpool/pending", nil)
 	queuedGauge  = metrics.NewRegisteredGauge("txpool/queued", nil)
@@ -147,6 +148,8 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	PersistJournal string // Journal of all pending and queued transactions to survive node restarts (disabled if empty)
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -156,6 +159,10 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	PrivateLifetime uint64 // Number of blocks private transactions are kept for inclusion
+
+	Policies TxPolicyConfig // Built-in admission policies restricting the transactions entering the pool
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -173,6 +180,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	PrivateLifetime: 50,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -211,6 +220,10 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.PrivateLifetime < 1 {
+		log.Warn("Sanitizing invalid txpool private lifetime", "provided", conf.PrivateLifetime, "updated", DefaultTxPoolConfig.PrivateLifetime)
+		conf.PrivateLifetime = DefaultTxPoolConfig.PrivateLifetime
+	}
 	return conf
 }
 
@@ -239,8 +252,12 @@ type TxPool struct {
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
+	locals  *accountSet    // Set of local transaction to exempt from eviction rules
+	journal *txJournal     // Journal of local transaction to back up to disk
+	persist *txPoolJournal // Journal of all the transactions, if the whole pool is persisted
+
+	builtins []TxPolicy // Built-in admission policies created from the configuration
+	policies []TxPolicy // Admission policies registered by the pool users
 
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
@@ -248,6 +265,11 @@ type TxPool struct {
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	privates map[common.Hash]uint64 // Private transactions never to be propagated, mapped to their expiry block
+
+	lifecycle *txLifecycle           // Lifecycle event feed and history of the transactions
+	mined     map[common.Hash]uint64 // Transactions included by the current reset, mapped to their block
+
 	chainHeadCh     chan ChainHeadEvent
 	chainHeadSub    event.Subscription
 	reqResetCh      chan *txpoolResetRequest
@@ -278,6 +300,8 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:           make(map[common.Address]*txList),
 		beats:           make(map[common.Address]time.Time),
 		all:             newTxLookup(),
+		privates:        make(map[common.Hash]uint64),
+		lifecycle:       newTxLifecycle(),
 		chainHeadCh:     make(chan ChainHeadEvent, chainHeadChanSize),
 		reqResetCh:      make(chan *txpoolResetRequest),
 		reqPromoteCh:    make(chan *accountSet),
@@ -291,6 +315,12 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		log.Info("Setting new local account", "address", addr)
 		pool.locals.add(addr)
 	}
+	if policies, err := config.Policies.Policies(); err != nil {
+		log.Error("Ignoring invalid txpool admission policies", "err", err)
+		pool.config.Policies = TxPolicyConfig{}
+	} else {
+		pool.builtins = policies
+	}
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
@@ -309,6 +339,11 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If the whole pool is persisted, restore the transactions from disk
+	if config.PersistJournal != "" {
+		pool.persist = newTxPoolJournal(config.PersistJournal)
+		pool.restore()
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -375,7 +410,7 @@ func (pool *TxPool) loop() {
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					list := pool.queue[addr].Flatten()
 					for _, tx := range list {
-						pool.removeTx(tx.Hash(), true)
+						pool.removeTx(tx.Hash(), true, TxDropLifetime)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
@@ -391,6 +426,9 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.persist != nil {
+				pool.save()
+			}
 		}
 	}
 }
@@ -403,10 +441,14 @@ func (pool *TxPool) Stop() {
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
 	pool.wg.Wait()
+	pool.lifecycle.close()
 
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.persist != nil {
+		pool.save()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -416,6 +458,19 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeLifecycleEvent registers a subscription of TxLifecycleEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeLifecycleEvent(ch chan<- TxLifecycleEvent) event.Subscription {
+	return pool.lifecycle.subscribe(ch)
+}
+
+// Lifecycle returns the recent lifecycle events of a transaction, oldest first.
+// Only a bounded number of events are retained for a bounded number of recently
+// seen transactions.
+func (pool *TxPool) Lifecycle(hash common.Hash) []TxLifecycleEvent {
+	return pool.lifecycle.events(hash)
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -437,7 +492,7 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 		// pool.priced is sorted by GasFeeCap, so we have to iterate through pool.all instead
 		drop := pool.all.RemotesBelowTip(price)
 		for _, tx := range drop {
-			pool.removeTx(tx.Hash(), false)
+			pool.removeTx(tx.Hash(), false, TxDropUnderpriced)
 		}
 		pool.priced.Removed(len(drop))
 	}
@@ -445,6 +500,85 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// PolicyConfig returns the configuration of the built-in admission policies of
+// the transaction pool.
+func (pool *TxPool) PolicyConfig() TxPolicyConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.config.Policies
+}
+
+// SetPolicyConfig replaces the built-in admission policies of the transaction
+// pool, and drops all transactions rejected by the new ones.
+func (pool *TxPool) SetPolicyConfig(config TxPolicyConfig) error {
+	policies, err := config.Policies()
+	if err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.config.Policies = config
+	pool.builtins = policies
+	pool.enforcePolicies()
+
+	log.Info("Transaction pool admission policies updated", "policies", len(policies))
+	return nil
+}
+
+// RegisterPolicy adds a custom admission policy to the transaction pool, and
+// drops all transactions rejected by it.
+func (pool *TxPool) RegisterPolicy(policy TxPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.policies = append(pool.policies, policy)
+	pool.enforcePolicies()
+
+	log.Info("Transaction pool admission policy registered", "policy", policy.Name())
+}
+
+// admit checks whether a transaction is accepted by all the admission policies
+// of the pool, returning the rejection of the first one refusing it.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) admit(tx *types.Transaction, from common.Address, local bool, peer string) error {
+	if len(pool.builtins) == 0 && len(pool.policies) == 0 {
+		return nil
+	}
+	adm := &TxAdmission{Tx: tx, From: from, Local: local, Peer: peer}
+	for _, policies := range [][]TxPolicy{pool.builtins, pool.policies} {
+		for _, policy := range policies {
+			if err := policy.Admit(adm); err != nil {
+				return &TxPolicyError{Policy: policy.Name(), Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// enforcePolicies drops all the pooled transactions rejected by the current
+// admission policies.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) enforcePolicies() {
+	var drop []common.Hash
+	pool.all.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
+		from, _ := types.Sender(pool.signer, tx) // already validated during insertion
+		if err := pool.admit(tx, from, local, ""); err != nil {
+			log.Trace("Dropping rejected transaction", "hash", hash, "err", err)
+			drop = append(drop, hash)
+		}
+		return true
+	}, true, true)
+
+	for _, hash := range drop {
+		pool.removeTx(hash, true, TxDropPolicy)
+	}
+	rejectedTxMeter.Mark(int64(len(drop)))
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.Address) uint64 {
@@ -566,6 +700,89 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// save writes all the pending and queued transactions of the pool into the pool
+// journal.
+func (pool *TxPool) save() {
+	pool.mu.RLock()
+	var txs []*persistedTx
+	for _, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			local := pool.locals.contains(addr)
+			for _, tx := range list.Flatten() {
+				if _, private := pool.privates[tx.Hash()]; private {
+					continue
+				}
+				txs = append(txs, &persistedTx{Tx: tx, Time: uint64(tx.Time().UnixNano()), Local: local})
+			}
+		}
+	}
+	pool.mu.RUnlock()
+
+	if err := pool.persist.save(txs); err != nil {
+		log.Warn("Failed to save transaction pool journal", "err", err)
+		return
+	}
+	log.Info("Saved transaction pool journal", "transactions", len(txs))
+}
+
+// restore loads the transactions of the pool journal, re-admitting the ones
+// still valid on top of the current head with their original arrival times.
+func (pool *TxPool) restore() {
+	txs, err := pool.persist.load()
+	if err != nil {
+		log.Warn("Failed to load transaction pool journal", "err", err)
+	}
+	var (
+		locals, remotes []*types.Transaction
+		arrivals        = make(map[common.Address]time.Time)
+	)
+	for _, ptx := range txs {
+		ptx.Tx.SetTime(time.Unix(0, int64(ptx.Time)))
+		if ptx.Local && !pool.config.NoLocals {
+			locals = append(locals, ptx.Tx)
+		} else {
+			remotes = append(remotes, ptx.Tx)
+		}
+	}
+	// Add the transactions in batches, tracking the last arrival per account
+	readmitted := 0
+	for _, batch := range []struct {
+		txs   []*types.Transaction
+		local bool
+	}{{locals, true}, {remotes, false}} {
+		for start := 0; start < len(batch.txs); start += 1024 {
+			end := start + 1024
+			if end > len(batch.txs) {
+				end = len(batch.txs)
+			}
+			for i, err := range pool.addTxs(batch.txs[start:end], batch.local, true, "") {
+				if err != nil {
+					log.Debug("Failed to readmit journaled transaction", "hash", batch.txs[start+i].Hash(), "err", err)
+					continue
+				}
+				readmitted++
+
+				tx := batch.txs[start+i]
+				from, _ := types.Sender(pool.signer, tx) // already validated
+				if tx.Time().After(arrivals[from]) {
+					arrivals[from] = tx.Time()
+				}
+			}
+		}
+	}
+	// Restore the heartbeats of the accounts from the arrival times, so that
+	// stale queued transactions still get evicted
+	pool.mu.Lock()
+	for addr, arrival := range arrivals {
+		if beat, ok := pool.beats[addr]; ok && arrival.Before(beat) {
+			pool.beats[addr] = arrival
+		}
+	}
+	pool.mu.Unlock()
+
+	log.Info("Loaded transaction pool journal", "transactions", len(txs), "readmitted", readmitted, "dropped", len(txs)-readmitted)
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -637,7 +854,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // be added to the allowlist, preventing any associated transaction from being dropped
 // out of the pool due to pricing constraints.
-func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err error) {
+func (pool *TxPool) add(tx *types.Transaction, local bool, peer string) (replaced bool, err error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
@@ -647,7 +864,9 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	}
 	// Make the local flag. If it's from local source or it's from the network but
 	// the sender is marked as local previously, treat it as the local transaction.
-	isLocal := local || pool.locals.containsTx(tx)
+	// Private transactions were submitted locally, so they are local too.
+	_, private := pool.privates[hash]
+	isLocal := local || private || pool.locals.containsTx(tx)
 
 	// If the transaction fails basic validation, discard it
 	if err := pool.validateTx(tx, isLocal); err != nil {
@@ -655,6 +874,13 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		invalidTxMeter.Mark(1)
 		return false, err
 	}
+	// If the transaction is refused by any admission policy, discard it
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	if err := pool.admit(tx, from, isLocal, peer); err != nil {
+		log.Trace("Discarding rejected transaction", "hash", hash, "err", err)
+		rejectedTxMeter.Mark(1)
+		return false, err
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
@@ -678,11 +904,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
-			pool.removeTx(tx.Hash(), false)
+			pool.removeTx(tx.Hash(), false, TxDropUnderpriced)
 		}
 	}
 	// Try to replace an existing transaction in the pending pool
-	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
 		inserted, old := list.Add(tx, pool.config.PriceBump)
@@ -695,11 +920,14 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.notifyReplaced(old, tx)
 		}
 		pool.all.Add(tx, isLocal)
 		pool.priced.Put(tx, isLocal)
 		pool.journalTx(from, tx)
 		pool.queueTxEvent(tx)
+		pool.notifyLifecycle(TxLifecycleAdded, tx)
+		pool.notifyLifecycle(TxLifecyclePromoted, tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// Successful promotion, bump the heartbeat
@@ -711,13 +939,14 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	if err != nil {
 		return false, err
 	}
+	pool.notifyLifecycle(TxLifecycleAdded, tx)
 	// Mark local addresses and journal local transactions
 	if local && !pool.locals.contains(from) {
 		log.Info("Setting new local account", "address", from)
 		pool.locals.add(from)
 		pool.priced.Removed(pool.all.RemoteToLocals(pool.locals)) // Migrate the remotes if it's marked as local first time.
 	}
-	if isLocal {
+	if pool.locals.contains(from) {
 		localGauge.Inc(1)
 	}
 	pool.journalTx(from, tx)
@@ -746,6 +975,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.notifyReplaced(old, tx)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
@@ -773,6 +1003,10 @@ func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
 	if pool.journal == nil || !pool.locals.contains(from) {
 		return
 	}
+	// Never journal private transactions, they would be reloaded as public ones
+	if _, private := pool.privates[tx.Hash()]; private {
+		return
+	}
 	if err := pool.journal.insert(tx); err != nil {
 		log.Warn("Failed to journal local transaction", "err", err)
 	}
@@ -795,6 +1029,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
+		pool.notifyDropped(tx, TxDropUnderpriced)
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -802,10 +1037,12 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
+		pool.notifyReplaced(old, tx)
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc(1)
 	}
+	pool.notifyLifecycle(TxLifecyclePromoted, tx)
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.pendingNonces.set(addr, tx.Nonce()+1)
 
@@ -820,7 +1057,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 // This method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, !pool.config.NoLocals, true, "")
 }
 
 // AddLocal enqueues a single local transaction into the pool if it is valid. This is
@@ -830,18 +1067,64 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return errs[0]
 }
 
+// AddPrivate enqueues a single private transaction into the pool if it is valid.
+// Private transactions are treated as local ones, but are never propagated to the
+// network and are dropped if not included within the configured number of blocks.
+func (pool *TxPool) AddPrivate(tx *types.Transaction) error {
+	// Filter out known and invalidly signed transactions without the pool lock
+	hash := tx.Hash()
+	if pool.all.Get(hash) != nil {
+		knownTxMeter.Mark(1)
+		return ErrAlreadyKnown
+	}
+	if _, err := types.Sender(pool.signer, tx); err != nil {
+		invalidTxMeter.Mark(1)
+		return ErrInvalidSender
+	}
+	// Mark the transaction private before adding it, so it's never announced
+	pool.mu.Lock()
+	_, known := pool.privates[hash]
+	pool.privates[hash] = pool.chain.CurrentBlock().NumberU64() + pool.config.PrivateLifetime
+
+	errs, dirtyAddrs := pool.addTxsLocked([]*types.Transaction{tx}, false, "")
+	if errs[0] != nil && !known {
+		delete(pool.privates, hash)
+	}
+	pool.mu.Unlock()
+
+	<-pool.requestPromoteExecutables(dirtyAddrs)
+	return errs[0]
+}
+
+// Private returns whether a transaction was submitted privately, and so must never
+// be propagated to the network.
+func (pool *TxPool) Private(hash common.Hash) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, private := pool.privates[hash]
+	return private
+}
+
 // AddRemotes enqueues a batch of transactions into the pool if they are valid. If the
 // senders are not among the locally tracked ones, full pricing constraints will apply.
 //
 // This method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, false, false, "")
+}
+
+// AddRemotesFrom enqueues a batch of transactions relayed by the given remote peer
+// into the pool if they are valid. It is like AddRemotes, but the admission
+// policies are aware of the origin of the transactions.
+func (pool *TxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return pool.addTxs(txs, false, false, peer)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this method.
 func (pool *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, false, true, "")
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this method.
@@ -859,8 +1142,9 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 	return errs[0]
 }
 
-// addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
+// addTxs attempts to queue a batch of transactions if they are valid. The peer
+// is the remote peer the transactions were received from, if any.
+func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool, peer string) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -891,7 +1175,7 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, local, peer)
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -912,11 +1196,11 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool, peer string) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
-		replaced, err := pool.add(tx, local)
+		replaced, err := pool.add(tx, local, peer)
 		errs[i] = err
 		if err == nil && !replaced {
 			dirty.addTx(tx)
@@ -961,14 +1245,16 @@ func (pool *TxPool) Has(hash common.Hash) bool {
 }
 
 // removeTx removes a single transaction from the queue, moving all subsequent
-// transactions back to the future queue.
-func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
+// transactions back to the future queue. The reason of the removal is reported
+// to the lifecycle event subscribers.
+func (pool *TxPool) removeTx(hash common.Hash, outofbound bool, reason TxDropReason) {
 	// Fetch the transaction we wish to delete
 	tx := pool.all.Get(hash)
 	if tx == nil {
 		return
 	}
 	addr, _ := types.Sender(pool.signer, tx) // already validated during insertion
+	pool.notifyDropped(tx, reason)
 
 	// Remove it from the list of known transactions
 	pool.all.Remove(hash)
@@ -989,6 +1275,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 			for _, tx := range invalids {
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(tx.Hash(), tx, false, false)
+				pool.notifyLifecycle(TxLifecycleDemoted, tx)
 			}
 			// Update the account nonce if needed
 			pool.pendingNonces.setIfLower(addr, tx.Nonce())
@@ -1149,6 +1436,7 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	// because of another transaction (e.g. higher gas price).
 	if reset != nil {
 		pool.demoteUnexecutables()
+		pool.mined = nil
 		if reset.newHead != nil && pool.chainconfig.IsLondon(new(big.Int).Add(reset.newHead.Number, big.NewInt(1))) {
 			pendingBaseFee := misc.CalcBaseFee(pool.chainconfig, reset.newHead)
 			pool.priced.SetBaseFee(pendingBaseFee)
@@ -1227,6 +1515,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 				}
 				for add.NumberU64() > rem.NumberU64() {
 					included = append(included, add.Transactions()...)
+					pool.markMined(add)
 					if add = pool.chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
 						log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
 						return
@@ -1239,6 +1528,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 						return
 					}
 					included = append(included, add.Transactions()...)
+					pool.markMined(add)
 					if add = pool.chain.GetBlock(add.ParentHash(), add.NumberU64()-1); add == nil {
 						log.Error("Unrooted new chain seen by tx pool", "block", newHead.Number, "hash", newHead.Hash())
 						return
@@ -1247,6 +1537,11 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 				reinject = types.TxDifference(discarded, included)
 			}
 		}
+	} else if oldHead != nil {
+		// Plain chain extension, track the new head's transactions as included
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			pool.markMined(block)
+		}
 	}
 	// Initialize the internal state to the current head
 	if newHead == nil {
@@ -1264,7 +1559,23 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+	pool.addTxsLocked(reinject, false, "")
+
+	// Drop the private transactions not included within their lifetime. They
+	// are tracked until then even if included, to stay private on reorgs.
+	var expired int
+	for hash, deadline := range pool.privates {
+		if newHead.Number.Uint64() >= deadline {
+			if pool.all.Get(hash) != nil {
+				pool.removeTx(hash, true, TxDropPrivate)
+				expired++
+			}
+			delete(pool.privates, hash)
+		}
+	}
+	if expired > 0 {
+		log.Debug("Dropped expired private transactions", "count", expired)
+	}
 
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
@@ -1291,6 +1602,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range forwards {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.notifyForwarded(tx)
 		}
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
@@ -1298,6 +1610,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.notifyDropped(tx, TxDropUnpayable)
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
@@ -1320,6 +1633,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
+				pool.notifyDropped(tx, TxDropQueueCap)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 			queuedRateLimitMeter.Mark(int64(len(caps)))
@@ -1382,6 +1696,7 @@ func (pool *TxPool) truncatePending() {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						pool.all.Remove(hash)
+						pool.notifyDropped(tx, TxDropPendingCap)
 
 						// Update the account nonce to the dropped transaction
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
@@ -1409,6 +1724,7 @@ func (pool *TxPool) truncatePending() {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
 					pool.all.Remove(hash)
+					pool.notifyDropped(tx, TxDropPendingCap)
 
 					// Update the account nonce to the dropped transaction
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
@@ -1455,7 +1771,7 @@ func (pool *TxPool) truncateQueue() {
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
-				pool.removeTx(tx.Hash(), true)
+				pool.removeTx(tx.Hash(), true, TxDropQueueCap)
 			}
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
@@ -1464,7 +1780,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
-			pool.removeTx(txs[i].Hash(), true)
+			pool.removeTx(txs[i].Hash(), true, TxDropQueueCap)
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1488,6 +1804,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.notifyForwarded(tx)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -1496,6 +1813,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
+			pool.notifyDropped(tx, TxDropUnpayable)
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -1505,6 +1823,7 @@ func (pool *TxPool) demoteUnexecutables() {
 
 			// Internal shuffle shouldn't touch the lookup set.
 			pool.enqueueTx(hash, tx, false, false)
+			pool.notifyLifecycle(TxLifecycleDemoted, tx)
 		}
 		pendingGauge.Dec(int64(len(olds) + len(drops) + len(invalids)))
 		if pool.locals.contains(addr) {
@@ -1519,6 +1838,7 @@ func (pool *TxPool) demoteUnexecutables() {
 
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(hash, tx, false, false)
+				pool.notifyLifecycle(TxLifecycleDemoted, tx)
 			}
 			pendingGauge.Dec(int64(len(gapped)))
 			// This might happen in a reorg, so log it to the metering
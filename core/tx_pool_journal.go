@@ -0,0 +1,103 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// persistedTx is a pooled transaction along with the metadata needed to restore
+// it into the pool.
+type persistedTx struct {
+	Tx    *types.Transaction
+	Time  uint64 // Arrival time of the transaction in unix nanoseconds
+	Local bool   // Whether the transaction was local
+}
+
+// txPoolJournal is a snapshot of all the pending and queued transactions of the
+// pool, local and remote, allowing the whole pool to survive node restarts.
+//
+// Contrary to the txJournal, which appends local transactions as they arrive,
+// the pool journal is only written in full, periodically and on shutdown.
+type txPoolJournal struct {
+	path string // Filesystem path to store the transactions at
+}
+
+// newTxPoolJournal creates a new pool journal stored at the given path.
+func newTxPoolJournal(path string) *txPoolJournal {
+	return &txPoolJournal{
+		path: path,
+	}
+}
+
+// load parses the transactions of the pool journal. Transactions parsed before
+// a failure are returned along with the error.
+func (journal *txPoolJournal) load() ([]*persistedTx, error) {
+	// Skip the parsing if the journal file doesn't exist at all
+	input, err := os.Open(journal.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var (
+		stream = rlp.NewStream(bufio.NewReader(input), 0)
+		txs    []*persistedTx
+	)
+	for {
+		tx := new(persistedTx)
+		if err = stream.Decode(tx); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return txs, err
+		}
+		txs = append(txs, tx)
+	}
+}
+
+// save replaces the pool journal with the given transactions.
+func (journal *txPoolJournal) save(txs []*persistedTx) error {
+	replacement, err := os.OpenFile(journal.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	output := bufio.NewWriter(replacement)
+	for _, tx := range txs {
+		if err = rlp.Encode(output, tx); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err = output.Flush(); err != nil {
+		replacement.Close()
+		return err
+	}
+	if err = replacement.Close(); err != nil {
+		return err
+	}
+	// Replace the previous journal with the newly generated one
+	return os.Rename(journal.path+".new", journal.path)
+}
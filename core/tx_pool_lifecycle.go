@@ -0,0 +1,275 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// txLifecycleHistory is the number of transactions whose lifecycle events are
+	// retained for later queries.
+	txLifecycleHistory = 8192
+
+	// txLifecycleEvents is the number of lifecycle events retained per transaction.
+	txLifecycleEvents = 16
+
+	// txLifecycleBacklog is the number of lifecycle events waiting for delivery to
+	// the subscribers above which new events are not delivered any more.
+	txLifecycleBacklog = 16384
+)
+
+// lifecycleOverflowMeter counts the lifecycle events not delivered to the
+// subscribers, because they could not keep up.
+var lifecycleOverflowMeter = metrics.NewRegisteredMeter("txpool/lifecycle/overflow", nil)
+
+// TxLifecycle is a state change of a transaction within the transaction pool.
+type TxLifecycle uint
+
+const (
+	TxLifecycleAdded    TxLifecycle = iota // Transaction accepted into the pool
+	TxLifecyclePromoted                    // Transaction became executable
+	TxLifecycleDemoted                     // Transaction became non-executable again
+	TxLifecycleReplaced                    // Transaction replaced by another with the same nonce
+	TxLifecycleDropped                     // Transaction dropped from the pool
+	TxLifecycleIncluded                    // Transaction included in the canonical chain
+)
+
+// String returns the name of a transaction lifecycle event.
+func (l TxLifecycle) String() string {
+	switch l {
+	case TxLifecycleAdded:
+		return "added"
+	case TxLifecyclePromoted:
+		return "promoted"
+	case TxLifecycleDemoted:
+		return "demoted"
+	case TxLifecycleReplaced:
+		return "replaced"
+	case TxLifecycleDropped:
+		return "dropped"
+	case TxLifecycleIncluded:
+		return "included"
+	default:
+		return "unknown"
+	}
+}
+
+// TxDropReason is the cause of a transaction being dropped from the pool.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced = TxDropReason("underpriced")     // Evicted by better priced transactions or price limit increase
+	TxDropPendingCap  = TxDropReason("pending-cap")     // Evicted by the global pending slot limit
+	TxDropQueueCap    = TxDropReason("queue-cap")       // Evicted by the account or global queue limits
+	TxDropLifetime    = TxDropReason("lifetime")        // Queued non-executable for longer than the lifetime
+	TxDropStaleNonce  = TxDropReason("stale-nonce")     // Nonce used up on chain by another transaction
+	TxDropUnpayable   = TxDropReason("unpayable")       // Balance too low or gas above the block gas limit
+	TxDropPolicy      = TxDropReason("policy")          // Rejected by an admission policy
+	TxDropPrivate     = TxDropReason("private-expired") // Private transaction not included within its lifetime
+)
+
+// TxLifecycleEvent is posted when a transaction changes state in the pool.
+type TxLifecycleEvent struct {
+	Hash  common.Hash    // Hash of the transaction
+	From  common.Address // Sender of the transaction
+	Nonce uint64         // Nonce of the transaction
+	Kind  TxLifecycle    // State change of the transaction
+	Time  time.Time      // Time of the state change
+
+	Reason TxDropReason // Cause of the drop, only set for TxLifecycleDropped
+	By     common.Hash  // Replacement transaction, only set for TxLifecycleReplaced
+	Block  uint64       // Including block number, only set for TxLifecycleIncluded
+}
+
+// txLifecycle delivers the lifecycle events of the transaction pool to its
+// subscribers and retains a bounded history of them for every transaction.
+//
+// Events are posted while the pool lock is held, so they are delivered from a
+// separate goroutine, never blocking the pool on slow subscribers.
+type txLifecycle struct {
+	feed  event.Feed
+	scope event.SubscriptionScope
+
+	lock    sync.Mutex
+	history *lru.Cache         // Recent lifecycle events by transaction hash
+	backlog []TxLifecycleEvent // Events waiting for delivery to the subscribers
+
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newTxLifecycle creates a new lifecycle event tracker and starts delivering
+// events to its subscribers.
+func newTxLifecycle() *txLifecycle {
+	history, _ := lru.New(txLifecycleHistory)
+	l := &txLifecycle{
+		history: history,
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	l.wg.Add(1)
+	go l.loop()
+	return l
+}
+
+// post records a lifecycle event and schedules it for delivery.
+func (l *txLifecycle) post(ev TxLifecycleEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var events []TxLifecycleEvent
+	if cached, ok := l.history.Get(ev.Hash); ok {
+		events = cached.([]TxLifecycleEvent)
+	}
+	if len(events) >= txLifecycleEvents {
+		events = events[len(events)-txLifecycleEvents+1:]
+	}
+	// Never append in place, the slice might be shared with a query result
+	events = append(append(make([]TxLifecycleEvent, 0, len(events)+1), events...), ev)
+	l.history.Add(ev.Hash, events)
+
+	if len(l.backlog) >= txLifecycleBacklog {
+		lifecycleOverflowMeter.Mark(1)
+		return
+	}
+	l.backlog = append(l.backlog, ev)
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// events retrieves the retained lifecycle events of a transaction, oldest first.
+func (l *txLifecycle) events(hash common.Hash) []TxLifecycleEvent {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if cached, ok := l.history.Get(hash); ok {
+		return cached.([]TxLifecycleEvent)
+	}
+	return nil
+}
+
+// subscribe registers a subscription for the lifecycle events.
+func (l *txLifecycle) subscribe(ch chan<- TxLifecycleEvent) event.Subscription {
+	return l.scope.Track(l.feed.Subscribe(ch))
+}
+
+// loop delivers the posted lifecycle events to the subscribers in order.
+func (l *txLifecycle) loop() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case <-l.wake:
+			l.lock.Lock()
+			backlog := l.backlog
+			l.backlog = nil
+			l.lock.Unlock()
+
+			for _, ev := range backlog {
+				l.feed.Send(ev)
+			}
+		case <-l.quit:
+			return
+		}
+	}
+}
+
+// close terminates the event delivery and unsubscribes all subscribers.
+func (l *txLifecycle) close() {
+	l.scope.Close()
+	close(l.quit)
+	l.wg.Wait()
+}
+
+// notifyLifecycle posts a state change of a pooled transaction to the lifecycle
+// event subscribers.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) notifyLifecycle(kind TxLifecycle, tx *types.Transaction) {
+	pool.lifecycle.post(pool.lifecycleEvent(kind, tx))
+}
+
+// notifyDropped posts the removal of a transaction from the pool, along with
+// the reason of it, to the lifecycle event subscribers.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) notifyDropped(tx *types.Transaction, reason TxDropReason) {
+	ev := pool.lifecycleEvent(TxLifecycleDropped, tx)
+	ev.Reason = reason
+	pool.lifecycle.post(ev)
+}
+
+// notifyReplaced posts the replacement of a transaction by another one with the
+// same nonce to the lifecycle event subscribers.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) notifyReplaced(old, tx *types.Transaction) {
+	ev := pool.lifecycleEvent(TxLifecycleReplaced, old)
+	ev.By = tx.Hash()
+	pool.lifecycle.post(ev)
+}
+
+// notifyForwarded posts the removal of a transaction whose nonce was used up on
+// chain, either by the transaction itself or by another one with the same nonce.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) notifyForwarded(tx *types.Transaction) {
+	number, ok := pool.mined[tx.Hash()]
+	if !ok {
+		pool.notifyDropped(tx, TxDropStaleNonce)
+		return
+	}
+	ev := pool.lifecycleEvent(TxLifecycleIncluded, tx)
+	ev.Block = number
+	pool.lifecycle.post(ev)
+}
+
+// markMined tracks the transactions of a block newly added to the canonical
+// chain, so they are reported as included when removed from the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) markMined(block *types.Block) {
+	if pool.mined == nil {
+		pool.mined = make(map[common.Hash]uint64)
+	}
+	for _, tx := range block.Transactions() {
+		pool.mined[tx.Hash()] = block.NumberU64()
+	}
+}
+
+// lifecycleEvent assembles a lifecycle event of a pooled transaction.
+func (pool *TxPool) lifecycleEvent(kind TxLifecycle, tx *types.Transaction) TxLifecycleEvent {
+	from, _ := types.Sender(pool.signer, tx) // already validated during insertion
+	return TxLifecycleEvent{
+		Hash:  tx.Hash(),
+		From:  from,
+		Nonce: tx.Nonce(),
+		Kind:  kind,
+		Time:  time.Now(),
+	}
+}
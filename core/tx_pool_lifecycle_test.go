@@ -0,0 +1,182 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// expectLifecycle waits for the given lifecycle events to be delivered, in order.
+func expectLifecycle(t *testing.T, events chan TxLifecycleEvent, want ...TxLifecycleEvent) {
+	t.Helper()
+
+	for i, w := range want {
+		select {
+		case ev := <-events:
+			if ev.Hash != w.Hash || ev.Kind != w.Kind || ev.Reason != w.Reason || ev.By != w.By || ev.Block != w.Block {
+				t.Fatalf("event %d mismatch: have %s %x (reason %q, by %x, block %d), want %s %x (reason %q, by %x, block %d)",
+					i, ev.Kind, ev.Hash, ev.Reason, ev.By, ev.Block, w.Kind, w.Hash, w.Reason, w.By, w.Block)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %d (%s %x) not delivered", i, w.Kind, w.Hash)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event: %s %x", ev.Kind, ev.Hash)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Tests that the state changes of transactions are reported to the lifecycle
+// event subscribers and retained in the per transaction history.
+func TestTransactionLifecycle(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testReorgChain{
+		testBlockChain: &testBlockChain{statedb, 1000000, new(event.Feed)},
+		blocks:         make(map[common.Hash]*types.Block),
+	}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	events := make(chan TxLifecycleEvent, 32)
+	sub := pool.SubscribeLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, addr, big.NewInt(1000000000))
+
+	// Add a gapped transaction, then fill the gap and ensure both get promoted
+	tx0 := transaction(0, 100000, key)
+	tx1 := transaction(1, 100000, key)
+
+	if err := pool.addRemoteSync(tx1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectLifecycle(t, events, TxLifecycleEvent{Hash: tx1.Hash(), Kind: TxLifecycleAdded})
+
+	if err := pool.addRemoteSync(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectLifecycle(t, events,
+		TxLifecycleEvent{Hash: tx0.Hash(), Kind: TxLifecycleAdded},
+		TxLifecycleEvent{Hash: tx0.Hash(), Kind: TxLifecyclePromoted},
+		TxLifecycleEvent{Hash: tx1.Hash(), Kind: TxLifecyclePromoted},
+	)
+	// Replace the first transaction and ensure the replacement is reported
+	repl := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(repl); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	expectLifecycle(t, events,
+		TxLifecycleEvent{Hash: tx0.Hash(), Kind: TxLifecycleReplaced, By: repl.Hash()},
+		TxLifecycleEvent{Hash: repl.Hash(), Kind: TxLifecycleAdded},
+		TxLifecycleEvent{Hash: repl.Hash(), Kind: TxLifecyclePromoted},
+	)
+	// Include the replacement and ensure the inclusion is reported
+	block := func(parent *types.Block, txs ...*types.Transaction) *types.Block {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   1000000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		}
+		block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+		blockchain.blocks[block.Hash()] = block
+		return block
+	}
+	genesis := blockchain.CurrentBlock()
+	blockchain.blocks[genesis.Hash()] = genesis
+
+	head := block(genesis, repl)
+	statedb.SetNonce(addr, 1)
+	<-pool.requestReset(genesis.Header(), head.Header())
+	expectLifecycle(t, events, TxLifecycleEvent{Hash: repl.Hash(), Kind: TxLifecycleIncluded, Block: 1})
+
+	// Use up the nonce of the second transaction and ensure it's dropped as stale
+	statedb.SetNonce(addr, 2)
+	<-pool.requestReset(head.Header(), block(head).Header())
+	expectLifecycle(t, events, TxLifecycleEvent{Hash: tx1.Hash(), Kind: TxLifecycleDropped, Reason: TxDropStaleNonce})
+
+	// Ensure the history of the transactions is retained
+	history := pool.Lifecycle(tx0.Hash())
+	if len(history) != 3 {
+		t.Fatalf("history length mismatch: have %d, want %d", len(history), 3)
+	}
+	for i, kind := range []TxLifecycle{TxLifecycleAdded, TxLifecyclePromoted, TxLifecycleReplaced} {
+		if history[i].Kind != kind || history[i].From != addr || history[i].Nonce != 0 {
+			t.Errorf("history %d mismatch: have %s from %x nonce %d, want %s from %x nonce %d", i, history[i].Kind, history[i].From, history[i].Nonce, kind, addr, 0)
+		}
+	}
+	if history := pool.Lifecycle(common.Hash{}); history != nil {
+		t.Errorf("unknown transaction has history: %v", history)
+	}
+}
+
+// Tests that evictions from the pool are reported with the reason of them.
+func TestTransactionLifecycleDrops(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxLifecycleEvent, 32)
+	sub := pool.SubscribeLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, addr, big.NewInt(1000000000))
+
+	// Raise the price limit above a pooled transaction and ensure it's dropped as underpriced
+	tx := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectLifecycle(t, events,
+		TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecycleAdded},
+		TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecyclePromoted},
+	)
+	pool.SetGasPrice(big.NewInt(2))
+	expectLifecycle(t, events, TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecycleDropped, Reason: TxDropUnderpriced})
+
+	// Drain the balance of the sender and ensure the transaction is dropped as unpayable
+	tx = pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectLifecycle(t, events,
+		TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecycleAdded},
+		TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecyclePromoted},
+	)
+	testAddBalance(pool, addr, big.NewInt(-1000000000))
+	<-pool.requestReset(nil, nil)
+	expectLifecycle(t, events, TxLifecycleEvent{Hash: tx.Hash(), Kind: TxLifecycleDropped, Reason: TxDropUnpayable})
+}
@@ -0,0 +1,329 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// ErrSenderNotAllowed is returned if the sender of a transaction is not
+	// contained in the configured sender allow list.
+	ErrSenderNotAllowed = errors.New("sender not allowed")
+
+	// ErrSenderDenied is returned if the sender of a transaction is contained in
+	// the configured sender deny list.
+	ErrSenderDenied = errors.New("sender denied")
+
+	// ErrTipBelowMinimum is returned if the tip of a transaction is below the
+	// minimum configured for its sender.
+	ErrTipBelowMinimum = errors.New("tip below sender minimum")
+
+	// ErrTargetBlocked is returned if a transaction calls a blocked contract.
+	ErrTargetBlocked = errors.New("target contract blocked")
+
+	// ErrSelectorBlocked is returned if a transaction calls a blocked method.
+	ErrSelectorBlocked = errors.New("method selector blocked")
+
+	// ErrPeerRateLimited is returned if the remote peer relaying a transaction
+	// exceeded its allowed transaction rate.
+	ErrPeerRateLimited = errors.New("peer rate limit exceeded")
+)
+
+// TxPolicyError is returned if a transaction is rejected by one of the admission
+// policies of the pool. The reason of the rejection is one of the errors above
+// for the built-in policies.
+type TxPolicyError struct {
+	Policy string // Name of the policy rejecting the transaction
+	Err    error  // Reason of the rejection
+}
+
+func (e *TxPolicyError) Error() string {
+	return fmt.Sprintf("rejected by %s policy: %v", e.Policy, e.Err)
+}
+
+func (e *TxPolicyError) Unwrap() error {
+	return e.Err
+}
+
+// TxAdmission is a transaction seeking admission into the pool along with the
+// details of its origin.
+type TxAdmission struct {
+	Tx    *types.Transaction
+	From  common.Address // Sender of the transaction, already validated
+	Local bool           // Whether the transaction is treated as local
+	Peer  string         // Identifier of the relaying remote peer, empty if not from the network
+}
+
+// TxPolicy is an admission policy deciding whether a transaction may enter the
+// pool. Policies are consulted after the basic validity checks of the pool for
+// every new transaction, including the ones reinjected after reorgs.
+//
+// Admit is called with the pool lock held, so it must not call back into the
+// pool and should return quickly.
+type TxPolicy interface {
+	// Name returns a short identifier of the policy, used in rejection errors.
+	Name() string
+
+	// Admit returns a non-nil error if the transaction must be rejected.
+	Admit(tx *TxAdmission) error
+}
+
+// TxPolicySenderTip is a minimum tip required from the transactions of a sender.
+type TxPolicySenderTip struct {
+	Sender common.Address `json:"sender"`
+	MinTip *big.Int       `json:"minTip"`
+}
+
+// TxPolicyConfig are the configuration parameters of the built-in admission
+// policies of the transaction pool. Policies without parameters are disabled.
+type TxPolicyConfig struct {
+	AllowSenders []common.Address `json:"allowSenders,omitempty" toml:",omitempty"` // Senders permitted to submit transactions (all if empty)
+	DenySenders  []common.Address `json:"denySenders,omitempty" toml:",omitempty"`  // Senders rejected even if allowed
+
+	MinTip     *big.Int            `json:"minTip,omitempty" toml:",omitempty"`     // Minimum tip required from non-local transactions
+	SenderTips []TxPolicySenderTip `json:"senderTips,omitempty" toml:",omitempty"` // Sender specific minimum tips, overriding MinTip
+
+	BlockedContracts []common.Address `json:"blockedContracts,omitempty" toml:",omitempty"` // Contracts transactions may not call
+	BlockedSelectors []hexutil.Bytes  `json:"blockedSelectors,omitempty" toml:",omitempty"` // 4 byte method selectors transactions may not call
+
+	PeerRate  float64 `json:"peerRate,omitempty" toml:",omitempty"`  // Transactions accepted per second from a single remote peer
+	PeerBurst int     `json:"peerBurst,omitempty" toml:",omitempty"` // Transactions a single remote peer may send in a burst
+}
+
+// Policies validates the configuration and creates the enabled built-in policies.
+func (config *TxPolicyConfig) Policies() ([]TxPolicy, error) {
+	var policies []TxPolicy
+
+	if len(config.AllowSenders) > 0 || len(config.DenySenders) > 0 {
+		policies = append(policies, NewSenderPolicy(config.AllowSenders, config.DenySenders))
+	}
+	if config.MinTip != nil || len(config.SenderTips) > 0 {
+		if config.MinTip != nil && config.MinTip.Sign() < 0 {
+			return nil, fmt.Errorf("negative minimum tip %v", config.MinTip)
+		}
+		senders := make(map[common.Address]*big.Int)
+		for _, tip := range config.SenderTips {
+			if tip.MinTip == nil || tip.MinTip.Sign() < 0 {
+				return nil, fmt.Errorf("invalid minimum tip %v for sender %x", tip.MinTip, tip.Sender)
+			}
+			senders[tip.Sender] = tip.MinTip
+		}
+		policies = append(policies, NewTipPolicy(config.MinTip, senders))
+	}
+	if len(config.BlockedContracts) > 0 || len(config.BlockedSelectors) > 0 {
+		selectors := make([][4]byte, len(config.BlockedSelectors))
+		for i, selector := range config.BlockedSelectors {
+			if len(selector) != 4 {
+				return nil, fmt.Errorf("invalid method selector %v", selector)
+			}
+			copy(selectors[i][:], selector)
+		}
+		policies = append(policies, NewTargetPolicy(config.BlockedContracts, selectors))
+	}
+	if config.PeerRate != 0 || config.PeerBurst != 0 {
+		if config.PeerRate <= 0 || config.PeerBurst <= 0 {
+			return nil, fmt.Errorf("invalid peer rate limit %v/s with burst %d", config.PeerRate, config.PeerBurst)
+		}
+		policies = append(policies, NewPeerRatePolicy(config.PeerRate, config.PeerBurst))
+	}
+	return policies, nil
+}
+
+// senderPolicy is an admission policy filtering transactions by sender.
+type senderPolicy struct {
+	allow map[common.Address]struct{} // Senders permitted to submit transactions, all if empty
+	deny  map[common.Address]struct{} // Senders never permitted to submit transactions
+}
+
+// NewSenderPolicy creates an admission policy rejecting the transactions of the
+// denied senders and, if the allow list is non-empty, of all the unlisted ones.
+func NewSenderPolicy(allow []common.Address, deny []common.Address) TxPolicy {
+	policy := &senderPolicy{
+		allow: make(map[common.Address]struct{}),
+		deny:  make(map[common.Address]struct{}),
+	}
+	for _, addr := range allow {
+		policy.allow[addr] = struct{}{}
+	}
+	for _, addr := range deny {
+		policy.deny[addr] = struct{}{}
+	}
+	return policy
+}
+
+func (p *senderPolicy) Name() string { return "sender" }
+
+func (p *senderPolicy) Admit(tx *TxAdmission) error {
+	if _, ok := p.deny[tx.From]; ok {
+		return ErrSenderDenied
+	}
+	if _, ok := p.allow[tx.From]; !ok && len(p.allow) > 0 {
+		return ErrSenderNotAllowed
+	}
+	return nil
+}
+
+// tipPolicy is an admission policy enforcing minimum tips on remote transactions.
+type tipPolicy struct {
+	min     *big.Int                    // Minimum tip required from unlisted senders, if any
+	senders map[common.Address]*big.Int // Minimum tips required from specific senders
+}
+
+// NewTipPolicy creates an admission policy rejecting the non-local transactions
+// with a tip below the minimum configured for their sender, or below the default
+// minimum if non-nil for unlisted senders.
+func NewTipPolicy(min *big.Int, senders map[common.Address]*big.Int) TxPolicy {
+	return &tipPolicy{
+		min:     min,
+		senders: senders,
+	}
+}
+
+func (p *tipPolicy) Name() string { return "tip" }
+
+func (p *tipPolicy) Admit(tx *TxAdmission) error {
+	if tx.Local {
+		return nil
+	}
+	min, ok := p.senders[tx.From]
+	if !ok {
+		min = p.min
+	}
+	if min != nil && tx.Tx.GasTipCapIntCmp(min) < 0 {
+		return fmt.Errorf("%w: have %v, want %v", ErrTipBelowMinimum, tx.Tx.GasTipCap(), min)
+	}
+	return nil
+}
+
+// targetPolicy is an admission policy filtering transactions by the contract
+// and method called.
+type targetPolicy struct {
+	contracts map[common.Address]struct{} // Contracts transactions may not call
+	selectors map[[4]byte]struct{}        // Method selectors transactions may not call
+}
+
+// NewTargetPolicy creates an admission policy rejecting the transactions calling
+// any of the given contracts, or any contract with one of the given method
+// selectors as the prefix of the call data.
+func NewTargetPolicy(contracts []common.Address, selectors [][4]byte) TxPolicy {
+	policy := &targetPolicy{
+		contracts: make(map[common.Address]struct{}),
+		selectors: make(map[[4]byte]struct{}),
+	}
+	for _, addr := range contracts {
+		policy.contracts[addr] = struct{}{}
+	}
+	for _, selector := range selectors {
+		policy.selectors[selector] = struct{}{}
+	}
+	return policy
+}
+
+func (p *targetPolicy) Name() string { return "target" }
+
+func (p *targetPolicy) Admit(tx *TxAdmission) error {
+	to := tx.Tx.To()
+	if to == nil {
+		return nil
+	}
+	if _, ok := p.contracts[*to]; ok {
+		return fmt.Errorf("%w: %x", ErrTargetBlocked, *to)
+	}
+	if data := tx.Tx.Data(); len(data) >= 4 {
+		var selector [4]byte
+		copy(selector[:], data)
+		if _, ok := p.selectors[selector]; ok {
+			return fmt.Errorf("%w: %x", ErrSelectorBlocked, selector)
+		}
+	}
+	return nil
+}
+
+// peerRatePolicy is an admission policy rate limiting the transactions relayed
+// by each remote peer.
+type peerRatePolicy struct {
+	limit rate.Limit // Transactions accepted per second from a single peer
+	burst int        // Transactions a single peer may send in a burst
+	idle  time.Duration
+
+	limiters map[string]*peerLimiter // Rate limiters of the recently active peers
+	pruned   time.Time               // Last time the idle peers were pruned
+	lock     sync.Mutex
+}
+
+// peerLimiter is the rate limiter of a single peer along with its last activity.
+type peerLimiter struct {
+	limiter *rate.Limiter
+	active  time.Time
+}
+
+// NewPeerRatePolicy creates an admission policy allowing each remote peer to
+// relay the given number of transactions per second on average, in bursts of
+// up to the given size. Local transactions and the ones not relayed by a peer
+// are not limited.
+func NewPeerRatePolicy(limit float64, burst int) TxPolicy {
+	return &peerRatePolicy{
+		limit:    rate.Limit(limit),
+		burst:    burst,
+		idle:     time.Duration(float64(burst) / limit * float64(time.Second)),
+		limiters: make(map[string]*peerLimiter),
+		pruned:   time.Now(),
+	}
+}
+
+func (p *peerRatePolicy) Name() string { return "peer-rate" }
+
+func (p *peerRatePolicy) Admit(tx *TxAdmission) error {
+	if tx.Local || tx.Peer == "" {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+
+	// Drop the limiters idle long enough to have refilled, they are equivalent
+	// to fresh ones
+	if now.Sub(p.pruned) > p.idle {
+		for peer, l := range p.limiters {
+			if now.Sub(l.active) > p.idle {
+				delete(p.limiters, peer)
+			}
+		}
+		p.pruned = now
+	}
+	l := p.limiters[tx.Peer]
+	if l == nil {
+		l = &peerLimiter{limiter: rate.NewLimiter(p.limit, p.burst)}
+		p.limiters[tx.Peer] = l
+	}
+	l.active = now
+	if !l.limiter.AllowN(now, 1) {
+		return ErrPeerRateLimited
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// callTransaction creates a signed legacy transaction calling the given contract
+// with the given data.
+func callTransaction(nonce uint64, to *common.Address, data []byte, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	var tx *types.Transaction
+	if to == nil {
+		tx = types.NewContractCreation(nonce, big.NewInt(0), 100000, gasprice, data)
+	} else {
+		tx = types.NewTransaction(nonce, *to, big.NewInt(0), 100000, gasprice, data)
+	}
+	tx, _ = types.SignTx(tx, types.HomesteadSigner{}, key)
+	return tx
+}
+
+// Tests that invalid policy configurations are rejected and only the policies
+// with parameters are enabled.
+func TestTxPolicyConfig(t *testing.T) {
+	tests := []struct {
+		config   TxPolicyConfig
+		policies int
+		err      bool
+	}{
+		{config: TxPolicyConfig{}},
+		{config: TxPolicyConfig{DenySenders: []common.Address{{0x01}}}, policies: 1},
+		{config: TxPolicyConfig{MinTip: big.NewInt(1), BlockedContracts: []common.Address{{0x01}}}, policies: 2},
+		{config: TxPolicyConfig{SenderTips: []TxPolicySenderTip{{Sender: common.Address{0x01}, MinTip: big.NewInt(0)}}}, policies: 1},
+		{config: TxPolicyConfig{PeerRate: 1, PeerBurst: 1, BlockedSelectors: []hexutil.Bytes{{1, 2, 3, 4}}}, policies: 2},
+		{config: TxPolicyConfig{MinTip: big.NewInt(-1)}, err: true},
+		{config: TxPolicyConfig{SenderTips: []TxPolicySenderTip{{Sender: common.Address{0x01}}}}, err: true},
+		{config: TxPolicyConfig{BlockedSelectors: []hexutil.Bytes{{1, 2, 3}}}, err: true},
+		{config: TxPolicyConfig{PeerRate: 1}, err: true},
+		{config: TxPolicyConfig{PeerRate: -1, PeerBurst: 1}, err: true},
+	}
+	for i, tt := range tests {
+		policies, err := tt.config.Policies()
+		if tt.err {
+			if err == nil {
+				t.Errorf("test %d: expected error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+			continue
+		}
+		if len(policies) != tt.policies {
+			t.Errorf("test %d: policy count mismatch: have %d, want %d", i, len(policies), tt.policies)
+		}
+	}
+}
+
+// Tests that the built-in admission policies reject the transactions they are
+// configured to, with the typed rejection errors.
+func TestTransactionPolicyAdmission(t *testing.T) {
+	t.Parallel()
+
+	var (
+		alice, _ = crypto.GenerateKey()
+		bob, _   = crypto.GenerateKey()
+		aliceAdr = crypto.PubkeyToAddress(alice.PublicKey)
+		bobAdr   = crypto.PubkeyToAddress(bob.PublicKey)
+		target   = common.Address{0xbb}
+		other    = common.Address{0xcc}
+		selector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	)
+	tests := []struct {
+		name   string
+		config TxPolicyConfig
+		tx     *types.Transaction
+		local  bool
+		policy string
+		err    error
+	}{
+		{
+			name:   "allowed sender",
+			config: TxPolicyConfig{AllowSenders: []common.Address{aliceAdr}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), alice),
+		},
+		{
+			name:   "unlisted sender",
+			config: TxPolicyConfig{AllowSenders: []common.Address{aliceAdr}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), bob),
+			policy: "sender", err: ErrSenderNotAllowed,
+		},
+		{
+			name:   "denied sender",
+			config: TxPolicyConfig{AllowSenders: []common.Address{aliceAdr}, DenySenders: []common.Address{aliceAdr}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), alice),
+			policy: "sender", err: ErrSenderDenied,
+		},
+		{
+			name:   "denied local sender",
+			config: TxPolicyConfig{DenySenders: []common.Address{aliceAdr}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), alice), local: true,
+			policy: "sender", err: ErrSenderDenied,
+		},
+		{
+			name:   "tip below minimum",
+			config: TxPolicyConfig{MinTip: big.NewInt(10)},
+			tx:     callTransaction(0, &target, nil, big.NewInt(9), alice),
+			policy: "tip", err: ErrTipBelowMinimum,
+		},
+		{
+			name:   "tip at minimum",
+			config: TxPolicyConfig{MinTip: big.NewInt(10)},
+			tx:     callTransaction(0, &target, nil, big.NewInt(10), alice),
+		},
+		{
+			name:   "local tip below minimum",
+			config: TxPolicyConfig{MinTip: big.NewInt(10)},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), alice), local: true,
+		},
+		{
+			name:   "sender tip below minimum",
+			config: TxPolicyConfig{SenderTips: []TxPolicySenderTip{{Sender: bobAdr, MinTip: big.NewInt(10)}}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(9), bob),
+			policy: "tip", err: ErrTipBelowMinimum,
+		},
+		{
+			name:   "sender tip overriding minimum",
+			config: TxPolicyConfig{MinTip: big.NewInt(10), SenderTips: []TxPolicySenderTip{{Sender: bobAdr, MinTip: big.NewInt(1)}}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), bob),
+		},
+		{
+			name:   "blocked contract",
+			config: TxPolicyConfig{BlockedContracts: []common.Address{target}},
+			tx:     callTransaction(0, &target, nil, big.NewInt(1), alice),
+			policy: "target", err: ErrTargetBlocked,
+		},
+		{
+			name:   "blocked selector",
+			config: TxPolicyConfig{BlockedSelectors: []hexutil.Bytes{selector}},
+			tx:     callTransaction(0, &other, append(selector, 0x01), big.NewInt(1), alice),
+			policy: "target", err: ErrSelectorBlocked,
+		},
+		{
+			name:   "short call data",
+			config: TxPolicyConfig{BlockedSelectors: []hexutil.Bytes{selector}},
+			tx:     callTransaction(0, &other, selector[:3], big.NewInt(1), alice),
+		},
+		{
+			name:   "contract creation",
+			config: TxPolicyConfig{BlockedContracts: []common.Address{{}}, BlockedSelectors: []hexutil.Bytes{selector}},
+			tx:     callTransaction(0, nil, selector, big.NewInt(1), alice),
+		},
+	}
+	for _, tt := range tests {
+		pool, _ := setupTxPool()
+		testAddBalance(pool, aliceAdr, big.NewInt(1000000000))
+		testAddBalance(pool, bobAdr, big.NewInt(1000000000))
+
+		if err := pool.SetPolicyConfig(tt.config); err != nil {
+			t.Fatalf("%s: failed to set policies: %v", tt.name, err)
+		}
+		var err error
+		if tt.local {
+			err = pool.AddLocal(tt.tx)
+		} else {
+			err = pool.addRemoteSync(tt.tx)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error mismatch: have %v, want %v", tt.name, err, tt.err)
+		}
+		if tt.err != nil {
+			var perr *TxPolicyError
+			if !errors.As(err, &perr) || perr.Policy != tt.policy {
+				t.Errorf("%s: rejecting policy mismatch: have %v, want %s", tt.name, err, tt.policy)
+			}
+		}
+		if have, want := pool.Has(tt.tx.Hash()), tt.err == nil; have != want {
+			t.Errorf("%s: pooled mismatch: have %v, want %v", tt.name, have, want)
+		}
+		pool.Stop()
+	}
+}
+
+// Tests that the transactions relayed by remote peers are rate limited per peer,
+// without limiting the ones not received from the network.
+func TestTransactionPolicyPeerRate(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	if err := pool.SetPolicyConfig(TxPolicyConfig{PeerRate: 0.001, PeerBurst: 2}); err != nil {
+		t.Fatalf("failed to set policies: %v", err)
+	}
+	errs := pool.AddRemotesFrom("a", []*types.Transaction{
+		transaction(0, 100000, key),
+		transaction(1, 100000, key),
+		transaction(2, 100000, key),
+	})
+	for i, want := range []error{nil, nil, ErrPeerRateLimited} {
+		if !errors.Is(errs[i], want) {
+			t.Errorf("transaction %d: error mismatch: have %v, want %v", i, errs[i], want)
+		}
+	}
+	if err := pool.AddRemotesFrom("b", []*types.Transaction{transaction(2, 100000, key)})[0]; err != nil {
+		t.Errorf("transaction from other peer rejected: %v", err)
+	}
+	if err := pool.addRemoteSync(transaction(3, 100000, key)); err != nil {
+		t.Errorf("transaction without peer rejected: %v", err)
+	}
+	if err := pool.AddRemotesFrom("a", []*types.Transaction{transaction(4, 100000, key)})[0]; !errors.Is(err, ErrPeerRateLimited) {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrPeerRateLimited)
+	}
+}
+
+// denyNoncePolicy is a custom admission policy rejecting transactions by nonce.
+type denyNoncePolicy uint64
+
+func (p denyNoncePolicy) Name() string { return "nonce" }
+
+func (p denyNoncePolicy) Admit(tx *TxAdmission) error {
+	if tx.Tx.Nonce() == uint64(p) {
+		return errors.New("denied nonce")
+	}
+	return nil
+}
+
+// Tests that updating the admission policies drops the pooled transactions they
+// reject, and that invalid updates are refused.
+func TestTransactionPolicyUpdate(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	alice, _ := crypto.GenerateKey()
+	bob, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(alice.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(bob.PublicKey), big.NewInt(1000000000))
+
+	pool.AddRemotesSync([]*types.Transaction{
+		transaction(0, 100000, alice),
+		transaction(1, 100000, alice),
+		transaction(3, 100000, alice),
+		transaction(0, 100000, bob),
+		transaction(1, 100000, bob),
+	})
+	if pending, queued := pool.Stats(); pending != 4 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 4, 1)
+	}
+	// Deny bob and ensure all of his transactions are dropped
+	config := TxPolicyConfig{DenySenders: []common.Address{crypto.PubkeyToAddress(bob.PublicKey)}}
+	if err := pool.SetPolicyConfig(config); err != nil {
+		t.Fatalf("failed to set policies: %v", err)
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Ensure invalid updates are refused, retaining the current policies
+	if err := pool.SetPolicyConfig(TxPolicyConfig{PeerBurst: 1}); err == nil {
+		t.Fatalf("invalid policies accepted")
+	}
+	if have := pool.PolicyConfig(); len(have.DenySenders) != 1 {
+		t.Fatalf("policies changed by invalid update: %+v", have)
+	}
+	// Register a custom policy and ensure the gapped transactions are demoted
+	pool.RegisterPolicy(denyNoncePolicy(0))
+	if pending, queued := pool.Stats(); pending != 0 || queued != 2 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 0, 2)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	var perr *TxPolicyError
+	if err := pool.addRemoteSync(transaction(0, 100000, alice)); !errors.As(err, &perr) || perr.Policy != "nonce" {
+		t.Fatalf("error mismatch: have %v, want rejection by nonce policy", err)
+	}
+}
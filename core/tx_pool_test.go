@@ -24,6 +24,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -432,14 +433,14 @@ func TestTransactionChainFork(t *testing.T) {
 	resetState()
 
 	tx := transaction(0, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, ""); err != nil {
 		t.Error("didn't expect error", err)
 	}
-	pool.removeTx(tx.Hash(), true)
+	pool.removeTx(tx.Hash(), true, TxDropUnderpriced)
 
 	// reset the pool's internal state
 	resetState()
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, ""); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -466,10 +467,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 1000000, big.NewInt(1), nil), signer, key)
 
 	// Add the first two transaction, ensure higher priced stays only
-	if replace, err := pool.add(tx1, false); err != nil || replace {
+	if replace, err := pool.add(tx1, false, ""); err != nil || replace {
 		t.Errorf("first transaction insert failed (%v) or reported replacement (%v)", err, replace)
 	}
-	if replace, err := pool.add(tx2, false); err != nil || !replace {
+	if replace, err := pool.add(tx2, false, ""); err != nil || !replace {
 		t.Errorf("second transaction insert failed (%v) or not reported replacement (%v)", err, replace)
 	}
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
@@ -481,7 +482,7 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	}
 
 	// Add the third transaction and ensure it's not saved (smaller price)
-	pool.add(tx3, false)
+	pool.add(tx3, false, "")
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
 	if pool.pending[addr].Len() != 1 {
 		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
@@ -504,7 +505,7 @@ func TestTransactionMissingNonce(t *testing.T) {
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 	testAddBalance(pool, addr, big.NewInt(100000000000000))
 	tx := transaction(1, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, ""); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -1959,7 +1960,7 @@ func TestDualHeapEviction(t *testing.T) {
 				highCap = txs[i]
 			}
 		}
-		pool.AddRemotes(txs)
+		pool.AddRemotesSync(txs)
 		pending, queued := pool.Stats()
 		if pending+queued != 20 {
 			t.Fatalf("transaction count mismatch: have %d, want %d", pending+queued, 10)
@@ -2348,6 +2349,248 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that the whole pool, local and remote transactions alike, is persisted
+// across restarts if enabled, with the transactions invalidated meanwhile being
+// dropped on startup.
+func TestTransactionPoolPersistence(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary directory for the pool journal
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.PersistJournal = filepath.Join(dir, "txpool.rlp")
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local and remote pending transactions along with a queued remote one,
+	// all with distinct arrival times
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), local),
+		pricedTransaction(0, 100000, big.NewInt(1), remote),
+		pricedTransaction(1, 100000, big.NewInt(1), remote),
+		pricedTransaction(3, 100000, big.NewInt(1), remote),
+	}
+	arrival := time.Now().Add(-time.Hour).Round(0)
+	for i, tx := range txs {
+		tx.SetTime(arrival.Add(time.Duration(i) * time.Minute))
+	}
+	if err := pool.AddLocal(txs[0]); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	for _, err := range pool.AddRemotesSync(txs[1:]) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction: %v", err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 3 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 3, 1)
+	}
+	// Terminate the old pool, include the first remote transaction, create a new
+	// pool and ensure all the other transactions survive
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if pool.Has(txs[1].Hash()) {
+		t.Errorf("included transaction readmitted")
+	}
+	for i, tx := range []*types.Transaction{txs[0], txs[2], txs[3]} {
+		restored := pool.Get(tx.Hash())
+		if restored == nil {
+			t.Fatalf("transaction %d missing", i)
+		}
+		if !restored.Time().Equal(tx.Time()) {
+			t.Errorf("transaction %d: arrival time mismatch: have %v, want %v", i, restored.Time(), tx.Time())
+		}
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Errorf("local account not restored")
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Errorf("remote account restored as local")
+	}
+	if beat := pool.beats[crypto.PubkeyToAddress(remote.PublicKey)]; !beat.Equal(txs[3].Time()) {
+		t.Errorf("remote heartbeat mismatch: have %v, want %v", beat, txs[3].Time())
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that private transactions are pooled like local ones, but are neither
+// journaled nor persisted, so they can't resurface as public ones on restart.
+func TestTransactionPrivate(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary directory for the journals
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+	config.PersistJournal = filepath.Join(dir, "txpool.rlp")
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	key, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Submit a local and an underpriced private transaction, both should be pooled
+	local := pricedTransaction(0, 100000, big.NewInt(1), key)
+	private := pricedTransaction(1, 100000, big.NewInt(0), key)
+
+	if err := pool.AddLocal(local); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddPrivate(private); err != ErrAlreadyKnown {
+		t.Fatalf("duplicate private transaction error mismatch: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 0)
+	}
+	if pool.Private(local.Hash()) || !pool.Private(private.Hash()) {
+		t.Fatalf("private flags mismatch: local %v, private %v", pool.Private(local.Hash()), pool.Private(private.Hash()))
+	}
+	// Rejected private transactions should not be tracked
+	invalid := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.AddPrivate(invalid); err == nil {
+		t.Fatalf("conflicting private transaction accepted")
+	}
+	if pool.Private(invalid.Hash()) {
+		t.Fatalf("rejected private transaction tracked")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Restart the pool and ensure only the local transaction is restored
+	pool.Stop()
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 1, 0)
+	}
+	if pool.Has(private.Hash()) {
+		t.Fatalf("private transaction restored")
+	}
+}
+
+// testReorgChain is a test blockchain whose blocks can be retrieved, to exercise
+// the reorg handling of the pool.
+type testReorgChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *testReorgChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// Tests that private transactions remain private if reinjected into the pool by
+// a reorg, and are dropped once their lifetime passes.
+func TestTransactionPrivateReorg(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testReorgChain{
+		testBlockChain: &testBlockChain{statedb, 1000000, new(event.Feed)},
+		blocks:         make(map[common.Hash]*types.Block),
+	}
+	config := testTxPoolConfig
+	config.PrivateLifetime = 4
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, addr, big.NewInt(1000000000))
+
+	private := transaction(0, 100000, key)
+	if err := pool.AddPrivate(private); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	// Create a chain including the transaction and a longer fork excluding it
+	block := func(parent *types.Block, txs ...*types.Transaction) *types.Block {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			GasLimit:   1000000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		}
+		if parent.Transactions().Len() > 0 {
+			header.Extra = []byte("fork")
+		}
+		block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+		blockchain.blocks[block.Hash()] = block
+		return block
+	}
+	genesis := blockchain.CurrentBlock()
+	blockchain.blocks[genesis.Hash()] = genesis
+
+	included := block(genesis, private)
+	forked := block(block(genesis))
+
+	// Include the transaction and ensure it's still tracked as private
+	statedb.SetNonce(addr, 1)
+	<-pool.requestReset(genesis.Header(), included.Header())
+	if pool.Has(private.Hash()) || !pool.Private(private.Hash()) {
+		t.Fatalf("included transaction state mismatch: pooled %v, private %v", pool.Has(private.Hash()), pool.Private(private.Hash()))
+	}
+	// Reorg the transaction out and ensure it's reinjected as private
+	statedb.SetNonce(addr, 0)
+	<-pool.requestReset(included.Header(), forked.Header())
+	if !pool.Has(private.Hash()) || !pool.Private(private.Hash()) {
+		t.Fatalf("reinjected transaction state mismatch: pooled %v, private %v", pool.Has(private.Hash()), pool.Private(private.Hash()))
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Advance the chain to the end of the lifetime and ensure the transaction is dropped
+	head := block(forked)
+	<-pool.requestReset(forked.Header(), head.Header())
+	if !pool.Has(private.Hash()) {
+		t.Fatalf("private transaction dropped before the end of its lifetime")
+	}
+	<-pool.requestReset(head.Header(), block(head).Header())
+	if pool.Has(private.Hash()) || pool.Private(private.Hash()) {
+		t.Fatalf("expired transaction state mismatch: pooled %v, private %v", pool.Has(private.Hash()), pool.Private(private.Hash()))
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -293,6 +293,13 @@ func (tx *Transaction) To() *common.Address {
 	return &cpy
 }
 
+// Time returns the time the transaction was first seen locally.
+func (tx *Transaction) Time() time.Time { return tx.time }
+
+// SetTime sets the time the transaction was first seen locally, e.g. when it is
+// restored from disk.
+func (tx *Transaction) SetTime(t time.Time) { tx.time = t }
+
 // Cost returns gas * gasPrice + value.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
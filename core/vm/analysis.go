@@ -16,6 +16,50 @@
 
 package vm
 
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// defaultAnalysisCacheSize is the number of JUMPDEST analyses retained in the
+// process wide cache by default. Bitmaps are an eighth of the code size, which
+// bounds the cache to about 12MB with the maximum contract size.
+const defaultAnalysisCacheSize = 4096
+
+var (
+	// analysisCache is a process wide cache of the JUMPDEST analyses of deployed
+	// code, keyed by code hash. It is shared among all EVM instances, including
+	// the ones of the state prefetcher, which allows the block processor to reuse
+	// the analyses done while prefetching.
+	analysisCache, _ = lru.New(defaultAnalysisCacheSize)
+
+	analysisHitMeter  = metrics.NewRegisteredMeter("vm/analysis/hit", nil)
+	analysisMissMeter = metrics.NewRegisteredMeter("vm/analysis/miss", nil)
+)
+
+// ResetAnalysisCache drops all the cached JUMPDEST analyses and resizes the cache
+// to hold the given number of them. A size of zero disables caching.
+func ResetAnalysisCache(size int) {
+	analysisCache.Resize(size)
+	analysisCache.Purge()
+}
+
+// cachedCodeBitmap returns the JUMPDEST analysis of the code with the given hash,
+// retrieving it from the process wide cache or storing it there if missing. The
+// returned bitmap is shared and must not be modified.
+func cachedCodeBitmap(hash common.Hash, code []byte) bitvec {
+	if analysis, ok := analysisCache.Get(hash); ok {
+		analysisHitMeter.Mark(1)
+		return analysis.(bitvec)
+	}
+	analysisMissMeter.Mark(1)
+
+	analysis := codeBitmap(code)
+	analysisCache.Add(hash, analysis)
+	return analysis
+}
+
 const (
 	set2BitsMask = uint16(0b1100_0000_0000_0000)
 	set3BitsMask = uint16(0b1110_0000_0000_0000)
@@ -17,9 +17,12 @@
 package vm
 
 import (
+	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
 )
 
 func TestJumpDestAnalysis(t *testing.T) {
@@ -55,6 +58,44 @@ func TestJumpDestAnalysis(t *testing.T) {
 	}
 }
 
+func TestJumpDestAnalysisCache(t *testing.T) {
+	defer ResetAnalysisCache(defaultAnalysisCacheSize)
+	ResetAnalysisCache(2)
+
+	var (
+		code = []byte{byte(PUSH1), byte(JUMPDEST), byte(JUMPDEST)}
+		hash = crypto.Keccak256Hash(code)
+		dest = uint256.NewInt(2)
+	)
+	newContract := func() *Contract {
+		contract := NewContract(AccountRef(common.Address{}), AccountRef(common.Address{}), new(big.Int), 0)
+		contract.SetCallCode(&common.Address{}, hash, code)
+		return contract
+	}
+	// Separate contracts with the same code should share the analysis
+	first, second := newContract(), newContract()
+	if !first.validJumpdest(dest) || !second.validJumpdest(dest) {
+		t.Fatalf("jumpdest not valid")
+	}
+	if first.validJumpdest(uint256.NewInt(1)) {
+		t.Fatalf("push data accepted as jumpdest")
+	}
+	if &first.analysis[0] != &second.analysis[0] {
+		t.Errorf("analysis not shared between contracts")
+	}
+	// Cache resets should drop the analyses
+	ResetAnalysisCache(2)
+	if third := newContract(); !third.validJumpdest(dest) || &third.analysis[0] == &first.analysis[0] {
+		t.Errorf("analysis retained after cache reset")
+	}
+	// Disabled caches should not retain anything
+	ResetAnalysisCache(0)
+	newContract().validJumpdest(dest)
+	if analysisCache.Len() != 0 {
+		t.Errorf("disabled cache retained %d analyses", analysisCache.Len())
+	}
+}
+
 func BenchmarkJumpdestAnalysis_1200k(bench *testing.B) {
 	// 1.4 ms
 	code := make([]byte, 1200000)
@@ -110,9 +110,10 @@ func (c *Contract) isCode(udest uint64) bool {
 		// Does parent context have the analysis?
 		analysis, exist := c.jumpdests[c.CodeHash]
 		if !exist {
-			// Do the analysis and save in parent context
+			// Retrieve the analysis from the process wide cache, or do it
+			// and save in parent context and in the cache.
 			// We do not need to store it in c.analysis
-			analysis = codeBitmap(c.Code)
+			analysis = cachedCodeBitmap(c.CodeHash, c.Code)
 			c.jumpdests[c.CodeHash] = analysis
 		}
 		// Also stash it in current contract for faster access
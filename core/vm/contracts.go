@@ -130,16 +130,23 @@ func init() {
 
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
+	var precompiles []common.Address
 	switch {
 	case rules.IsBerlin:
-		return PrecompiledAddressesBerlin
+		precompiles = PrecompiledAddressesBerlin
 	case rules.IsIstanbul:
-		return PrecompiledAddressesIstanbul
+		precompiles = PrecompiledAddressesIstanbul
 	case rules.IsByzantium:
-		return PrecompiledAddressesByzantium
+		precompiles = PrecompiledAddressesByzantium
 	default:
-		return PrecompiledAddressesHomestead
+		precompiles = PrecompiledAddressesHomestead
 	}
+	if len(rules.ExtraPrecompiles) == 0 {
+		return precompiles
+	}
+	active := make([]common.Address, 0, len(precompiles)+len(rules.ExtraPrecompiles))
+	active = append(active, precompiles...)
+	return append(active, rules.ExtraPrecompiles...)
 }
 
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
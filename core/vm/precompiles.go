@@ -0,0 +1,203 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// errMissingPrecompileContext is returned if a stateful precompile is invoked
+// through the stateless interface.
+var errMissingPrecompileContext = errors.New("stateful precompile invoked without context")
+
+// customPrecompiles contains the precompiled contracts which can be activated by
+// the chain config on top of the ones of the active fork. It contains the EIP-2537
+// contracts by default, further ones may be added via RegisterPrecompile.
+var customPrecompiles = make(map[common.Address]PrecompiledContract)
+
+func init() {
+	for addr, p := range PrecompiledContractsBLS {
+		customPrecompiles[addr] = p
+	}
+}
+
+// StatefulPrecompiledContract is the interface for native Go contracts requiring
+// access to the EVM state. Besides the static cost of the input charged upfront,
+// the implementation may charge dynamic gas through the context.
+type StatefulPrecompiledContract interface {
+	RequiredGas(input []byte) uint64                                  // RequiredGas calculates the gas charged upfront
+	RunStateful(ctx *PrecompileContext, input []byte) ([]byte, error) // RunStateful runs the precompiled contract
+}
+
+// PrecompileContext is the environment a stateful precompile is executed in.
+//
+// All state modifications done through the EVM state database are journaled, so
+// they are reverted if the precompile or any of its callers fail. Returning
+// ErrExecutionReverted reverts the modifications while refunding the remaining
+// gas, any other error consumes all of it.
+type PrecompileContext struct {
+	EVM      *EVM
+	Caller   common.Address // Caller of the precompile, the parent's caller for delegate calls
+	Address  common.Address // Address whose storage is used, the caller's for delegate and code calls
+	Value    *big.Int       // Value transferred along with the call
+	ReadOnly bool           // Whether state modifications are forbidden (static calls)
+
+	gas uint64 // Gas remaining after the upfront cost was charged
+}
+
+// Gas returns the gas remaining for the precompile execution.
+func (ctx *PrecompileContext) Gas() uint64 {
+	return ctx.gas
+}
+
+// UseGas charges the given amount of gas, returning ErrOutOfGas if there is not
+// enough of it left.
+func (ctx *PrecompileContext) UseGas(gas uint64) error {
+	if ctx.gas < gas {
+		ctx.gas = 0
+		return ErrOutOfGas
+	}
+	ctx.gas -= gas
+	return nil
+}
+
+// StateDB returns the state database of the EVM. Modifications done through it
+// directly need to check ReadOnly beforehand.
+func (ctx *PrecompileContext) StateDB() StateDB {
+	return ctx.EVM.StateDB
+}
+
+// GetState retrieves a storage slot of the executing address.
+func (ctx *PrecompileContext) GetState(key common.Hash) common.Hash {
+	return ctx.EVM.StateDB.GetState(ctx.Address, key)
+}
+
+// SetState updates a storage slot of the executing address, returning
+// ErrWriteProtection in a read-only context.
+func (ctx *PrecompileContext) SetState(key, value common.Hash) error {
+	if ctx.ReadOnly {
+		return ErrWriteProtection
+	}
+	ctx.EVM.StateDB.SetState(ctx.Address, key, value)
+	return nil
+}
+
+// statefulPrecompile wraps a stateful precompile to be stored alongside the
+// stateless ones. The EVM detects the wrapper and runs it with a context.
+type statefulPrecompile struct {
+	StatefulPrecompiledContract
+}
+
+// Run implements PrecompiledContract, but always fails as the contract requires
+// a context to execute in.
+func (p *statefulPrecompile) Run(input []byte) ([]byte, error) {
+	return nil, errMissingPrecompileContext
+}
+
+// RegisterPrecompile registers a precompiled contract at the given address, to
+// be activated by chain configs scheduling it. It panics if the address is taken
+// by a builtin or an already registered contract.
+//
+// Registration is not thread safe and must be done before any EVM is created,
+// e.g. from an init function.
+func RegisterPrecompile(addr common.Address, p PrecompiledContract) {
+	if _, ok := PrecompiledContractsBerlin[addr]; ok {
+		panic(fmt.Sprintf("precompile %x is builtin", addr))
+	}
+	if _, ok := customPrecompiles[addr]; ok {
+		panic(fmt.Sprintf("precompile %x already registered", addr))
+	}
+	customPrecompiles[addr] = p
+}
+
+// RegisterStatefulPrecompile registers a stateful precompiled contract at the
+// given address, under the same conditions as RegisterPrecompile.
+func RegisterStatefulPrecompile(addr common.Address, p StatefulPrecompiledContract) {
+	RegisterPrecompile(addr, &statefulPrecompile{p})
+}
+
+// CheckPrecompiles verifies that all the precompiles scheduled by the chain
+// config have a registered implementation.
+func CheckPrecompiles(config *params.ChainConfig) error {
+	for addr := range config.Precompiles {
+		if _, ok := PrecompiledContractsBerlin[addr]; ok {
+			return fmt.Errorf("precompile %x is builtin", addr)
+		}
+		if _, ok := customPrecompiles[addr]; !ok {
+			return fmt.Errorf("precompile %x not registered", addr)
+		}
+	}
+	return nil
+}
+
+// ActivePrecompiledContracts returns the precompiled contracts enabled with the
+// current configuration.
+func ActivePrecompiledContracts(rules params.Rules) map[common.Address]PrecompiledContract {
+	var precompiles map[common.Address]PrecompiledContract
+	switch {
+	case rules.IsBerlin:
+		precompiles = PrecompiledContractsBerlin
+	case rules.IsIstanbul:
+		precompiles = PrecompiledContractsIstanbul
+	case rules.IsByzantium:
+		precompiles = PrecompiledContractsByzantium
+	default:
+		precompiles = PrecompiledContractsHomestead
+	}
+	if len(rules.ExtraPrecompiles) == 0 {
+		return precompiles
+	}
+	active := make(map[common.Address]PrecompiledContract, len(precompiles)+len(rules.ExtraPrecompiles))
+	for addr, p := range precompiles {
+		active[addr] = p
+	}
+	for _, addr := range rules.ExtraPrecompiles {
+		if p, ok := customPrecompiles[addr]; ok {
+			active[addr] = p
+		}
+	}
+	return active
+}
+
+// runPrecompile executes a precompiled contract, providing stateful ones with
+// their execution context. The caller and address are the ones of the context,
+// see PrecompileContext.
+func (evm *EVM) runPrecompile(p PrecompiledContract, caller, addr common.Address, input []byte, gas uint64, value *big.Int, readOnly bool) (ret []byte, remainingGas uint64, err error) {
+	sp, ok := p.(*statefulPrecompile)
+	if !ok {
+		return RunPrecompiledContract(p, input, gas)
+	}
+	gasCost := sp.RequiredGas(input)
+	if gas < gasCost {
+		return nil, 0, ErrOutOfGas
+	}
+	ctx := &PrecompileContext{
+		EVM:      evm,
+		Caller:   caller,
+		Address:  addr,
+		Value:    value,
+		ReadOnly: readOnly || evm.interpreter.readOnly,
+		gas:      gas - gasCost,
+	}
+	ret, err = sp.RunStateful(ctx, input)
+	return ret, ctx.gas, err
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// counterPrecompile is a stateful precompile incrementing the first storage slot
+// of the executing address, returning the new value. It reverts the increment
+// if the input is non-empty.
+type counterPrecompile struct{}
+
+const (
+	counterBaseGas      = 100
+	counterIncrementGas = 5000
+)
+
+func (c *counterPrecompile) RequiredGas(input []byte) uint64 {
+	return counterBaseGas
+}
+
+func (c *counterPrecompile) RunStateful(ctx *PrecompileContext, input []byte) ([]byte, error) {
+	if err := ctx.UseGas(counterIncrementGas); err != nil {
+		return nil, err
+	}
+	count := ctx.GetState(common.Hash{}).Big()
+	count.Add(count, big.NewInt(1))
+	if err := ctx.SetState(common.Hash{}, common.BigToHash(count)); err != nil {
+		return nil, err
+	}
+	if len(input) > 0 {
+		return nil, ErrExecutionReverted
+	}
+	return common.BigToHash(count).Bytes(), nil
+}
+
+var counterAddress = common.BytesToAddress([]byte{1, 0})
+
+func init() {
+	RegisterStatefulPrecompile(counterAddress, &counterPrecompile{})
+}
+
+func TestStatefulPrecompile(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		// Contract calling the counter and reverting afterwards
+		reverter     = common.BytesToAddress([]byte("reverter"))
+		reverterCode = common.FromHex("60006000600060006000610100" + "5af150" + "60006000fd")
+	)
+	config := *params.AllEthashProtocolChanges
+	config.Precompiles = map[common.Address]*big.Int{
+		counterAddress:                    big.NewInt(5),
+		common.BytesToAddress([]byte{10}): big.NewInt(0),
+	}
+	if err := CheckPrecompiles(&config); err != nil {
+		t.Fatalf("failed to check precompiles: %v", err)
+	}
+	newEVM := func(number int64) (*EVM, *state.StateDB) {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(reverter, reverterCode)
+
+		vmctx := BlockContext{
+			CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+			Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+			BlockNumber: big.NewInt(number),
+		}
+		return NewEVM(vmctx, TxContext{}, statedb, &config, Config{}), statedb
+	}
+	count := func(statedb *state.StateDB, addr common.Address) int64 {
+		return statedb.GetState(addr, common.Hash{}).Big().Int64()
+	}
+	// Before the activation the address is an ordinary empty account
+	evm, statedb := newEVM(4)
+	if active := ActivePrecompiles(evm.chainRules); len(active) != len(PrecompiledAddressesBerlin)+1 {
+		t.Errorf("active precompile count mismatch: have %d, want %d", len(active), len(PrecompiledAddressesBerlin)+1)
+	}
+	ret, gas, err := evm.Call(AccountRef(caller), counterAddress, nil, 10000, new(big.Int))
+	if err != nil || len(ret) != 0 || gas != 10000 || count(statedb, counterAddress) != 0 {
+		t.Fatalf("inactive precompile executed: ret %x, gas %d, err %v", ret, gas, err)
+	}
+	// After the activation the state is updated and dynamic gas charged
+	evm, statedb = newEVM(5)
+	if active := ActivePrecompiles(evm.chainRules); len(active) != len(PrecompiledAddressesBerlin)+2 {
+		t.Errorf("active precompile count mismatch: have %d, want %d", len(active), len(PrecompiledAddressesBerlin)+2)
+	}
+	ret, gas, err = evm.Call(AccountRef(caller), counterAddress, nil, 10000, new(big.Int))
+	if err != nil {
+		t.Fatalf("failed to call precompile: %v", err)
+	}
+	if have := new(big.Int).SetBytes(ret).Int64(); have != 1 {
+		t.Errorf("return value mismatch: have %d, want %d", have, 1)
+	}
+	if want := uint64(10000 - counterBaseGas - counterIncrementGas); gas != want {
+		t.Errorf("remaining gas mismatch: have %d, want %d", gas, want)
+	}
+	if have := count(statedb, counterAddress); have != 1 {
+		t.Errorf("counter mismatch: have %d, want %d", have, 1)
+	}
+	// Insufficient gas for the dynamic cost fails the call
+	if _, gas, err = evm.Call(AccountRef(caller), counterAddress, nil, 1000, new(big.Int)); err != ErrOutOfGas || gas != 0 {
+		t.Errorf("out of gas mismatch: gas %d, err %v", gas, err)
+	}
+	// Reverts roll back the state while refunding the remaining gas
+	if _, gas, err = evm.Call(AccountRef(caller), counterAddress, []byte{1}, 10000, new(big.Int)); err != ErrExecutionReverted || gas == 0 {
+		t.Errorf("revert mismatch: gas %d, err %v", gas, err)
+	}
+	// Static calls forbid state modifications
+	if _, _, err = evm.StaticCall(AccountRef(caller), counterAddress, nil, 10000); err != ErrWriteProtection {
+		t.Errorf("static call error mismatch: have %v, want %v", err, ErrWriteProtection)
+	}
+	if have := count(statedb, counterAddress); have != 1 {
+		t.Errorf("counter mismatch: have %d, want %d", have, 1)
+	}
+	// Code calls execute in the storage of the caller
+	if _, _, err = evm.CallCode(AccountRef(caller), counterAddress, nil, 10000, new(big.Int)); err != nil {
+		t.Fatalf("failed to callcode precompile: %v", err)
+	}
+	if have := count(statedb, caller); have != 1 {
+		t.Errorf("caller counter mismatch: have %d, want %d", have, 1)
+	}
+	// Reverting callers roll back the modifications of the precompile
+	if _, _, err = evm.Call(AccountRef(caller), reverter, nil, 100000, new(big.Int)); err != ErrExecutionReverted {
+		t.Fatalf("reverter error mismatch: have %v, want %v", err, ErrExecutionReverted)
+	}
+	if have := count(statedb, counterAddress); have != 1 {
+		t.Errorf("counter mismatch after revert: have %d, want %d", have, 1)
+	}
+	// The EIP-2537 contracts are available once scheduled
+	if _, ok := evm.precompile(common.BytesToAddress([]byte{10})); !ok {
+		t.Errorf("BLS12-381 G1 addition precompile not active")
+	}
+}
+
+func TestCheckPrecompiles(t *testing.T) {
+	tests := []struct {
+		addr common.Address
+		ok   bool
+	}{
+		{counterAddress, true},
+		{common.BytesToAddress([]byte{18}), true},
+		{common.BytesToAddress([]byte{1}), false},
+		{common.BytesToAddress([]byte{2, 0}), false},
+	}
+	for _, tt := range tests {
+		config := &params.ChainConfig{Precompiles: map[common.Address]*big.Int{tt.addr: big.NewInt(0)}}
+		if err := CheckPrecompiles(config); (err == nil) != tt.ok {
+			t.Errorf("precompile %x: check mismatch: have %v, want ok %v", tt.addr, err, tt.ok)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("duplicate registration accepted")
+		}
+	}()
+	RegisterPrecompile(counterAddress, &sha256hash{})
+}
@@ -688,3 +688,272 @@ func TestColdAccountAccessCost(t *testing.T) {
 		}
 	}
 }
+
+// TestEip1153Cases ports the EIP-1153 transient storage state tests. Each case
+// executes contract 0xaa, which may invoke contracts 0xbb and 0xcc or itself, and
+// checks the values they persisted into their storage.
+func TestEip1153Cases(t *testing.T) {
+	var (
+		aa = common.BytesToAddress([]byte{0xaa})
+		bb = common.BytesToAddress([]byte{0xbb})
+	)
+	// Helpers assembling the recurring code snippets
+	tstore := func(key, val byte) []byte {
+		return []byte{byte(vm.PUSH1), val, byte(vm.PUSH1), key, byte(vm.TSTORE)}
+	}
+	persist := func(slot, key byte) []byte { // SSTORE(slot, TLOAD(key))
+		return []byte{byte(vm.PUSH1), key, byte(vm.TLOAD), byte(vm.PUSH1), slot, byte(vm.SSTORE)}
+	}
+	callTo := func(op vm.OpCode, to byte, gas []byte, slot byte) []byte { // SSTORE(slot, op(to)), return data into memory 0
+		code := []byte{byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1)}
+		if op == vm.CALL || op == vm.CALLCODE {
+			code = append(code, byte(vm.DUP1))
+		}
+		code = append(append(append(code, byte(vm.PUSH1), to), gas...), byte(op))
+		return append(code, byte(vm.PUSH1), slot, byte(vm.SSTORE))
+	}
+	call := func(op vm.OpCode, slot byte) []byte { // SSTORE(slot, op(0xbb))
+		return callTo(op, 0xbb, []byte{byte(vm.GAS)}, slot)
+	}
+	reenter := func(op vm.OpCode, slot byte) []byte { // SSTORE(slot, op(0xaa))
+		return callTo(op, 0xaa, []byte{byte(vm.GAS)}, slot)
+	}
+	reentrant := func(outer, inner []byte) []byte { // Run inner if called by itself, outer otherwise
+		code := []byte{byte(vm.ADDRESS), byte(vm.CALLER), byte(vm.EQ), byte(vm.PUSH1), byte(7 + len(outer)), byte(vm.JUMPI)}
+		code = append(append(code, outer...), byte(vm.STOP), byte(vm.JUMPDEST))
+		return append(code, inner...)
+	}
+	gasOf := func(slot byte, code []byte) []byte { // SSTORE(slot, gas used by code and a GAS)
+		code = append(append([]byte{byte(vm.GAS)}, code...), byte(vm.GAS), byte(vm.SWAP1), byte(vm.SUB))
+		return append(code, byte(vm.PUSH1), slot, byte(vm.SSTORE))
+	}
+	tstoreLoop := func(pc int, count uint16) []byte { // TSTORE(i, i) for i in [0, count), placed at pc
+		return []byte{
+			byte(vm.PUSH2), byte(count >> 8), byte(count),
+			byte(vm.JUMPDEST), byte(vm.PUSH1), 1, byte(vm.SWAP1), byte(vm.SUB),
+			byte(vm.DUP1), byte(vm.DUP1), byte(vm.TSTORE),
+			byte(vm.DUP1), byte(vm.PUSH1), byte(pc + 3), byte(vm.JUMPI), byte(vm.POP),
+		}
+	}
+	revert := []byte{byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)}
+	returnWord := []byte{byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	persistReturned := []byte{byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.PUSH1), 2, byte(vm.SSTORE)} // SSTORE(2, MLOAD(0))
+
+	concat := func(snippets ...[]byte) []byte {
+		var code []byte
+		for _, snippet := range snippets {
+			code = append(code, snippet...)
+		}
+		return code
+	}
+	word := func(b ...byte) common.Hash { return common.BytesToHash(b) }
+	gas := func(gas uint64) common.Hash { return common.BigToHash(new(big.Int).SetUint64(gas)) }
+	one := word(1)
+
+	tests := []struct {
+		name   string
+		codeA  []byte
+		codeB  []byte
+		codeC  []byte
+		prestA map[common.Hash]common.Hash
+		wantA  map[common.Hash]common.Hash
+		wantB  map[common.Hash]common.Hash
+	}{
+		{
+			name:   "01_tloadBeginningTxn",
+			codeA:  persist(0, 0),
+			prestA: map[common.Hash]common.Hash{{}: one},
+			wantA:  map[common.Hash]common.Hash{{}: {}},
+		},
+		{
+			name:  "02_tloadAfterTstore",
+			codeA: concat(tstore(0, 0x2a), persist(0, 0)),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a)},
+		},
+		{
+			name:  "03_tloadAfterStoreIs0",
+			codeA: concat(tstore(0, 0x2a), tstore(0, 0), persist(0, 0)),
+			wantA: map[common.Hash]common.Hash{{}: {}},
+		},
+		{
+			name:  "04_tloadAfterCall",
+			codeA: concat(tstore(0, 0x2a), call(vm.CALL, 1)),
+			codeB: persist(0, 0),
+			wantA: map[common.Hash]common.Hash{word(1): one},
+			wantB: map[common.Hash]common.Hash{{}: {}},
+		},
+		{
+			name:  "05_tloadReentrancy",
+			codeA: reentrant(concat(tstore(0, 0x2a), reenter(vm.CALL, 1)), persist(0, 0)),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a), word(1): one},
+		},
+		{
+			name:  "06_tstoreInReentrancyCall",
+			codeA: reentrant(concat(reenter(vm.CALL, 1), persist(0, 0)), tstore(0, 0x2a)),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a), word(1): one},
+		},
+		{
+			name:  "07_tloadAfterReentrancyStore",
+			codeA: reentrant(concat(tstore(0, 1), reenter(vm.CALL, 1), persist(0, 0)), concat(persist(2, 0), tstore(0, 2))),
+			wantA: map[common.Hash]common.Hash{{}: word(2), word(1): one, word(2): one},
+		},
+		{
+			name:  "08_revertUndoesTransientStore",
+			codeA: concat(tstore(0, 1), call(vm.DELEGATECALL, 1), persist(0, 0)),
+			codeB: concat(tstore(0, 2), revert),
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}},
+		},
+		{
+			name:  "09_revertUndoesAll",
+			codeA: reentrant(concat(tstore(0, 1), reenter(vm.CALL, 1), persist(0, 0)), concat(tstore(0, 2), tstore(0, 3), revert)),
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}},
+		},
+		{
+			name:  "10_revertUndoesStoreAfterReturn",
+			codeA: concat(tstore(0, 1), call(vm.DELEGATECALL, 1), persist(0, 0)),
+			codeB: concat(callTo(vm.DELEGATECALL, 0xcc, []byte{byte(vm.GAS)}, 2), revert),
+			codeC: tstore(0, 2),
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}, word(2): {}},
+		},
+		{
+			name:  "11_tstoreDelegateCall",
+			codeA: concat(call(vm.DELEGATECALL, 1), persist(0, 0)),
+			codeB: tstore(0, 0x2a),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a), word(1): one},
+		},
+		{
+			name:  "12_tloadDelegateCall",
+			codeA: concat(tstore(0, 0x2a), call(vm.DELEGATECALL, 1)),
+			codeB: persist(0, 0),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a), word(1): one},
+		},
+		{
+			name:  "13_tloadStaticCall",
+			codeA: reentrant(concat(tstore(0, 0x2a), reenter(vm.STATICCALL, 1), persistReturned), concat([]byte{byte(vm.PUSH1), 0, byte(vm.TLOAD)}, returnWord)),
+			wantA: map[common.Hash]common.Hash{word(1): one, word(2): word(0x2a)},
+		},
+		{
+			name:  "13_tstoreStaticCall",
+			codeA: concat(tstore(0, 1), call(vm.STATICCALL, 1), persist(0, 0)),
+			codeB: tstore(0, 2),
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}},
+		},
+		{
+			name:  "14_revertAfterNestedStaticcall",
+			codeA: concat(call(vm.STATICCALL, 1), persistReturned),
+			codeB: concat([]byte{byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.PUSH1), 0xcc, byte(vm.GAS), byte(vm.CALL), byte(vm.PUSH1), 0x10, byte(vm.ADD)}, returnWord),
+			codeC: tstore(0, 1),
+			wantA: map[common.Hash]common.Hash{word(1): one, word(2): word(0x10)},
+		},
+		{
+			name:  "15_tstoreCannotBeDosd",
+			codeA: concat(tstoreLoop(0, 1000), []byte{byte(vm.PUSH2), 0x03, 0xe7, byte(vm.TLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE)}, persist(1, 1)),
+			wantA: map[common.Hash]common.Hash{{}: word(0x03, 0xe7), word(1): one},
+		},
+		{
+			name:  "16_tloadGas",
+			codeA: gasOf(0, []byte{byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.POP)}),
+			wantA: map[common.Hash]common.Hash{{}: gas(vm.GasFastestStep + params.WarmStorageReadCostEIP2929 + 2*vm.GasQuickStep)},
+		},
+		{
+			name:  "17_tstoreGas",
+			codeA: gasOf(0, tstore(0, 0x2a)),
+			wantA: map[common.Hash]common.Hash{{}: gas(2*vm.GasFastestStep + params.WarmStorageReadCostEIP2929 + vm.GasQuickStep)},
+		},
+		{
+			name:  "18_tloadAfterStore",
+			codeA: concat([]byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.SSTORE)}, persist(1, 0)),
+			wantA: map[common.Hash]common.Hash{{}: word(0x2a), word(1): {}},
+		},
+		{
+			name:  "19_oogUndoesTransientStore",
+			codeA: concat(tstore(0, 1), call(vm.DELEGATECALL, 1), persist(0, 0)),
+			codeB: concat(tstore(0, 2), []byte{0xfe}), // INVALID
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}},
+		},
+		{
+			name: "20_oogUndoesTransientStoreInCall",
+			codeA: func() []byte {
+				outer := concat(tstore(0, 1), callTo(vm.CALL, 0xaa, []byte{byte(vm.PUSH2), 0x27, 0x10}, 1), persist(0, 0))
+				return reentrant(outer, concat(tstore(0, 2), tstoreLoop(len(outer)+13, 1000)))
+			}(),
+			wantA: map[common.Hash]common.Hash{{}: one, word(1): {}},
+		},
+		{
+			name:  "21_tstoreCannotBeDosdOOO",
+			codeA: concat(callTo(vm.CALL, 0xbb, []byte{byte(vm.PUSH3), 0x01, 0x86, 0xa0}, 1), persist(0, 0)),
+			codeB: []byte{byte(vm.JUMPDEST), byte(vm.GAS), byte(vm.DUP1), byte(vm.TSTORE), byte(vm.PUSH1), 0, byte(vm.JUMP)},
+			wantA: map[common.Hash]common.Hash{{}: {}, word(1): {}},
+		},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(aa, tt.codeA)
+		statedb.SetCode(bb, tt.codeB)
+		statedb.SetCode(common.BytesToAddress([]byte{0xcc}), tt.codeC)
+		for key, val := range tt.prestA {
+			statedb.SetState(aa, key, val)
+		}
+		_, _, err := Call(aa, nil, &Config{State: statedb, EVMConfig: vm.Config{ExtraEips: []int{1153}}})
+		if err != nil {
+			t.Errorf("%s: execution failed: %v", tt.name, err)
+			continue
+		}
+		for key, want := range tt.wantA {
+			if have := statedb.GetState(aa, key); have != want {
+				t.Errorf("%s: 0xaa slot %x mismatch: have %x, want %x", tt.name, key, have, want)
+			}
+		}
+		for key, want := range tt.wantB {
+			if have := statedb.GetState(bb, key); have != want {
+				t.Errorf("%s: 0xbb slot %x mismatch: have %x, want %x", tt.name, key, have, want)
+			}
+		}
+	}
+}
+
+// TestEip1153Activation tests that the transient storage opcodes are enabled by
+// the chain config or the extra EIPs, are priced independently of the access
+// list and are cleared between transactions.
+func TestEip1153Activation(t *testing.T) {
+	// Persist the value stored in the previous execution, then store a new one
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0, byte(vm.TSTORE),
+	}
+	if _, _, err := Execute(code, nil, nil); err == nil {
+		t.Fatalf("transient storage available without activation")
+	}
+	eip1153 := *params.AllEthashProtocolChanges
+	eip1153.EIP1153Block = big.NewInt(1)
+	if _, _, err := Execute(code, nil, &Config{ChainConfig: &eip1153}); err == nil {
+		t.Fatalf("transient storage available before fork block")
+	}
+	tracer := vm.NewStructLogger(nil)
+	cfg := &Config{ChainConfig: &eip1153, BlockNumber: big.NewInt(1), EVMConfig: vm.Config{Debug: true, Tracer: tracer}}
+	if _, _, err := Execute(code, nil, cfg); err != nil {
+		t.Fatalf("transient storage unavailable after fork block: %v", err)
+	}
+	for _, step := range []int{1, 6} {
+		if have := tracer.StructLogs()[step].GasCost; have != params.WarmStorageReadCostEIP2929 {
+			t.Errorf("%v gas cost mismatch: have %d, want %d", tracer.StructLogs()[step].Op, have, params.WarmStorageReadCostEIP2929)
+		}
+	}
+	// Executing again within the same transaction should see the stored value
+	address := common.BytesToAddress([]byte("contract"))
+	cfg.EVMConfig = vm.Config{}
+	if _, _, err := Call(address, nil, cfg); err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if have := cfg.State.GetState(address, common.Hash{}); have != common.BytesToHash([]byte{0x2a}) {
+		t.Errorf("transient storage lost within transaction: have %x", have)
+	}
+	// Whereas a new transaction should start with empty transient storage
+	cfg.State.Prepare(common.Hash{1}, 1)
+	if _, _, err := Call(address, nil, cfg); err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if have := cfg.State.GetState(address, common.Hash{}); have != (common.Hash{}) {
+		t.Errorf("transient storage retained across transactions: have %x", have)
+	}
+}
@@ -197,6 +197,21 @@ func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64
 	return true, nil
 }
 
+// TxPolicies retrieves the configuration of the built-in transaction admission
+// policies of the transaction pool.
+func (api *PrivateAdminAPI) TxPolicies() core.TxPolicyConfig {
+	return api.eth.TxPool().PolicyConfig()
+}
+
+// SetTxPolicies replaces the built-in transaction admission policies of the
+// transaction pool, dropping the pooled transactions rejected by the new ones.
+func (api *PrivateAdminAPI) SetTxPolicies(config core.TxPolicyConfig) (bool, error) {
+	if err := api.eth.TxPool().SetPolicyConfig(config); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -284,6 +299,8 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 	var block *types.Block
 	if blockNr == rpc.LatestBlockNumber {
 		block = api.eth.blockchain.CurrentBlock()
+	} else if blockNr == rpc.FinalizedBlockNumber || blockNr == rpc.SafeBlockNumber {
+		block = api.eth.blockchain.CurrentFinalizedBlock()
 	} else {
 		block = api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
 	}
@@ -372,6 +389,8 @@ func (api *PublicDebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, sta
 			var block *types.Block
 			if number == rpc.LatestBlockNumber {
 				block = api.eth.blockchain.CurrentBlock()
+			} else if number == rpc.FinalizedBlockNumber || number == rpc.SafeBlockNumber {
+				block = api.eth.blockchain.CurrentFinalizedBlock()
 			} else {
 				block = api.eth.blockchain.GetBlockByNumber(uint64(number))
 			}
@@ -24,8 +24,11 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth"
@@ -34,35 +37,61 @@ import (
 	chainParams "github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
 )
 
+const (
+	// badBlockCacheLimit is the maximum number of rejected block hashes to remember.
+	badBlockCacheLimit = 128
+
+	// maxBodyRequestCount is the maximum number of payload bodies that can be
+	// requested at once.
+	maxBodyRequestCount = 1024
+
+	// maxBodyResponseSize is the soft limit on the total size of the transactions
+	// returned by a body request. Once exceeded, the remaining bodies are omitted
+	// from the response and need to be requested again.
+	maxBodyResponseSize = 2 * 1024 * 1024
+)
+
+var errInvalidBlockHash = errors.New("block hash mismatch")
+
 // Register adds catalyst APIs to the node.
 func Register(stack *node.Node, backend *eth.Ethereum) error {
 	chainconfig := backend.BlockChain().Config()
-	if chainconfig.CatalystBlock == nil {
-		return errors.New("catalystBlock is not set in genesis config")
-	} else if chainconfig.CatalystBlock.Sign() != 0 {
-		return errors.New("catalystBlock of genesis config must be zero")
+	if chainconfig.CatalystBlock == nil && chainconfig.CatalystTotalDifficulty == nil {
+		return errors.New("neither catalystBlock nor catalystTotalDifficulty is set in genesis config")
 	}
 
 	log.Warn("Catalyst mode enabled")
 	stack.RegisterAPIs([]rpc.API{
 		{
-			Namespace: "consensus",
-			Version:   "1.0",
-			Service:   newConsensusAPI(backend),
-			Public:    true,
+			Namespace:     "consensus",
+			Version:       "1.0",
+			Service:       newConsensusAPI(backend),
+			Public:        true,
+			Authenticated: true,
 		},
 	})
 	return nil
 }
 
 type consensusAPI struct {
-	eth *eth.Ethereum
+	eth      *eth.Ethereum
+	payloads *payloadQueue // Payloads being built in the background, retrievable by id
+	blocks   *blockQueue   // Blocks with unknown ancestors, waiting for the sync to finish
+
+	badBlocks *lru.Cache // Recently rejected block hashes, mapped to their latest valid ancestor
 }
 
 func newConsensusAPI(eth *eth.Ethereum) *consensusAPI {
-	return &consensusAPI{eth: eth}
+	badBlocks, _ := lru.New(badBlockCacheLimit)
+	return &consensusAPI{
+		eth:       eth,
+		payloads:  newPayloadQueue(),
+		blocks:    newBlockQueue(),
+		badBlocks: badBlocks,
+	}
 }
 
 // blockExecutionEnv gathers all the data required to execute
@@ -110,15 +139,11 @@ func (api *consensusAPI) makeEnv(parent *types.Block, header *types.Header) (*bl
 func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableData, error) {
 	log.Info("Producing block", "parentHash", params.ParentHash)
 
-	bc := api.eth.BlockChain()
-	parent := bc.GetBlockByHash(params.ParentHash)
+	parent := api.eth.BlockChain().GetBlockByHash(params.ParentHash)
 	if parent == nil {
 		log.Warn("Cannot assemble block with parent hash to unknown block", "parentHash", params.ParentHash)
 		return nil, fmt.Errorf("cannot assemble block with unknown parent %s", params.ParentHash)
 	}
-
-	pool := api.eth.TxPool()
-
 	if parent.Time() >= params.Timestamp {
 		return nil, fmt.Errorf("child timestamp lower than parent's: %d >= %d", parent.Time(), params.Timestamp)
 	}
@@ -127,107 +152,248 @@ func (api *consensusAPI) AssembleBlock(params assembleBlockParams) (*executableD
 		log.Info("Producing block too far in the future", "wait", common.PrettyDuration(wait))
 		time.Sleep(wait)
 	}
-
-	pending, err := pool.Pending(true)
+	coinbase, err := api.eth.Etherbase()
 	if err != nil {
 		return nil, err
 	}
-
-	coinbase, err := api.eth.Etherbase()
+	block, _, err := api.assembleBlock(parent, params.Timestamp, coinbase, false)
 	if err != nil {
 		return nil, err
 	}
-	num := parent.Number()
+	return blockToExecutableData(block), nil
+}
+
+// assembleBlock builds a block on top of the given parent, filled with the
+// pending transactions of the pool unless an empty block is requested. Besides
+// the block, the total priority fees earned by the coinbase are returned.
+func (api *consensusAPI) assembleBlock(parent *types.Block, timestamp uint64, coinbase common.Address, empty bool) (*types.Block, *big.Int, error) {
+	bc := api.eth.BlockChain()
+
+	num := new(big.Int).Set(parent.Number())
 	header := &types.Header{
 		ParentHash: parent.Hash(),
 		Number:     num.Add(num, common.Big1),
 		Coinbase:   coinbase,
 		GasLimit:   parent.GasLimit(), // Keep the gas limit constant in this prototype
 		Extra:      []byte{},
-		Time:       params.Timestamp,
+		Time:       timestamp,
 	}
-	if config := api.eth.BlockChain().Config(); config.IsLondon(header.Number) {
+	if config := bc.Config(); config.IsLondon(header.Number) {
 		header.BaseFee = misc.CalcBaseFee(config, parent.Header())
 	}
-	err = api.eth.Engine().Prepare(bc, header)
+	if err := api.eth.Engine().Prepare(bc, header); err != nil {
+		return nil, nil, err
+	}
+	env, err := api.makeEnv(parent, header)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	var (
+		fees         = new(big.Int)
+		transactions []*types.Transaction
+	)
+	if !empty {
+		pending, err := api.eth.TxPool().Pending(true)
+		if err != nil {
+			return nil, nil, err
+		}
+		signer := types.MakeSigner(bc.Config(), header.Number)
+
+		// Commit the transactions in the order configured for the miner, which puts
+		// the local ones first by default. Use the global-price order to rank all
+		// of them in a single price-and-nonce heap instead.
+		for _, txHeap := range api.eth.Miner().TxOrderer().Order(signer, pending, api.eth.TxPool().Locals(), nil) {
+			for {
+				if env.gasPool.Gas() < chainParams.TxGas {
+					log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", chainParams.TxGas)
+					break
+				}
+				tx := txHeap.Peek()
+				if tx == nil {
+					break
+				}
+
+				// The sender is only for logging purposes, and it doesn't really matter if it's correct.
+				from, _ := types.Sender(signer, tx)
+
+				// Execute the transaction
+				env.state.Prepare(tx.Hash(), env.tcount)
+				err = env.commitTransaction(tx, coinbase)
+				switch err {
+				case core.ErrGasLimitReached:
+					// Pop the current out-of-gas transaction without shifting in the next from the account
+					log.Trace("Gas limit exceeded for current block", "sender", from)
+					txHeap.Pop()
+
+				case core.ErrNonceTooLow:
+					// New head notification data race between the transaction pool and miner, shift
+					log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
+					txHeap.Shift()
+
+				case core.ErrNonceTooHigh:
+					// Reorg notification data race between the transaction pool and miner, skip account =
+					log.Trace("Skipping account with high nonce", "sender", from, "nonce", tx.Nonce())
+					txHeap.Pop()
+
+				case nil:
+					// Everything ok, collect the fees and shift in the next transaction from the same account
+					receipt := env.receipts[len(env.receipts)-1]
+					fees.Add(fees, new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), tx.EffectiveGasTipValue(header.BaseFee)))
+					env.tcount++
+					txHeap.Shift()
+					transactions = append(transactions, tx)
+
+				default:
+					// Strange error, discard the transaction and get the next in line (note, the
+					// nonce-too-high clause will prevent us from executing in vain).
+					log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
+					txHeap.Shift()
+				}
+			}
+		}
+	}
+	// Create the block.
+	block, err := api.eth.Engine().FinalizeAndAssemble(bc, header, env.state, transactions, nil /* uncles */, env.receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, fees, nil
+}
 
-	env, err := api.makeEnv(parent, header)
+// PreparePayload starts building a payload on top of the given parent in the
+// background and returns an identifier to retrieve it with. The payload keeps
+// being improved as new transactions arrive until it is retrieved.
+func (api *consensusAPI) PreparePayload(params payloadAttributes) (*preparePayloadResponse, error) {
+	id := computePayloadID(params)
+	if api.payloads.get(id) != nil {
+		return &preparePayloadResponse{PayloadID: id}, nil
+	}
+	parent := api.eth.BlockChain().GetBlockByHash(params.ParentHash)
+	if parent == nil {
+		log.Warn("Cannot prepare payload with parent hash to unknown block", "parentHash", params.ParentHash)
+		return nil, fmt.Errorf("cannot prepare payload with unknown parent %s", params.ParentHash)
+	}
+	if parent.Time() >= params.Timestamp {
+		return nil, fmt.Errorf("child timestamp lower than parent's: %d >= %d", parent.Time(), params.Timestamp)
+	}
+	payload, err := api.buildPayload(parent, params)
 	if err != nil {
 		return nil, err
 	}
+	log.Info("Preparing payload", "id", id, "parentHash", params.ParentHash, "timestamp", params.Timestamp)
+	api.payloads.put(id, payload)
+	return &preparePayloadResponse{PayloadID: id}, nil
+}
 
+// GetPayload returns the best version of a previously prepared payload and
+// stops improving it any further.
+func (api *consensusAPI) GetPayload(id payloadID) (*executableData, error) {
+	payload := api.payloads.get(id)
+	if payload == nil {
+		return nil, fmt.Errorf("unknown payload %s", id)
+	}
+	block := payload.resolve()
+	log.Info("Retrieved payload", "id", id, "number", block.NumberU64(), "hash", block.Hash(), "txs", len(block.Transactions()))
+	return blockToExecutableData(block), nil
+}
+
+// GetPayloadBodiesByHash returns the bodies of the payloads with the given block
+// hashes, in the requested order. Unknown payloads are reported as null. If the
+// response grows too large, it is truncated and the remainder has to be requested
+// again.
+func (api *consensusAPI) GetPayloadBodiesByHash(hashes []common.Hash) ([]*payloadBody, error) {
+	if len(hashes) > maxBodyRequestCount {
+		return nil, fmt.Errorf("too many bodies requested: %d > %d", len(hashes), maxBodyRequestCount)
+	}
 	var (
-		signer       = types.MakeSigner(bc.Config(), header.Number)
-		txHeap       = types.NewTransactionsByPriceAndNonce(signer, pending, nil)
-		transactions []*types.Transaction
+		bc     = api.eth.BlockChain()
+		bodies = make([]*payloadBody, 0, len(hashes))
+		size   int
 	)
-	for {
-		if env.gasPool.Gas() < chainParams.TxGas {
-			log.Trace("Not enough gas for further transactions", "have", env.gasPool, "want", chainParams.TxGas)
+	for _, hash := range hashes {
+		if size >= maxBodyResponseSize {
 			break
 		}
-		tx := txHeap.Peek()
-		if tx == nil {
+		body := encodeBody(bc.GetBody(hash))
+		bodies, size = append(bodies, body), size+body.size()
+	}
+	return bodies, nil
+}
+
+// GetPayloadBodiesByRange returns the bodies of count canonical payloads starting
+// at the given block number, both from the ancient store and the live database.
+// The response ends at the current head, and is truncated if it grows too large.
+func (api *consensusAPI) GetPayloadBodiesByRange(start, count hexutil.Uint64) ([]*payloadBody, error) {
+	if count == 0 {
+		return nil, errors.New("invalid body count: 0")
+	}
+	if count > maxBodyRequestCount {
+		return nil, fmt.Errorf("too many bodies requested: %d > %d", count, maxBodyRequestCount)
+	}
+	var (
+		bc     = api.eth.BlockChain()
+		head   = bc.CurrentBlock().NumberU64()
+		bodies = make([]*payloadBody, 0, count)
+		size   int
+	)
+	for number := uint64(start); number < uint64(start+count) && number <= head; number++ {
+		if size >= maxBodyResponseSize {
 			break
 		}
-
-		// The sender is only for logging purposes, and it doesn't really matter if it's correct.
-		from, _ := types.Sender(signer, tx)
-
-		// Execute the transaction
-		env.state.Prepare(tx.Hash(), env.tcount)
-		err = env.commitTransaction(tx, coinbase)
-		switch err {
-		case core.ErrGasLimitReached:
-			// Pop the current out-of-gas transaction without shifting in the next from the account
-			log.Trace("Gas limit exceeded for current block", "sender", from)
-			txHeap.Pop()
-
-		case core.ErrNonceTooLow:
-			// New head notification data race between the transaction pool and miner, shift
-			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
-			txHeap.Shift()
-
-		case core.ErrNonceTooHigh:
-			// Reorg notification data race between the transaction pool and miner, skip account =
-			log.Trace("Skipping account with high nonce", "sender", from, "nonce", tx.Nonce())
-			txHeap.Pop()
-
-		case nil:
-			// Everything ok, collect the logs and shift in the next transaction from the same account
-			env.tcount++
-			txHeap.Shift()
-			transactions = append(transactions, tx)
-
-		default:
-			// Strange error, discard the transaction and get the next in line (note, the
-			// nonce-too-high clause will prevent us from executing in vain).
-			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
-			txHeap.Shift()
+		hash := bc.GetCanonicalHash(number)
+		if hash == (common.Hash{}) {
+			break
 		}
+		body := encodeBody(bc.GetBody(hash))
+		bodies, size = append(bodies, body), size+body.size()
 	}
+	return bodies, nil
+}
 
-	// Create the block.
-	block, err := api.eth.Engine().FinalizeAndAssemble(bc, header, env.state, transactions, nil /* uncles */, env.receipts)
-	if err != nil {
-		return nil, err
+// encodeBody converts a block body into its payload representation, or returns
+// nil if the body is unknown.
+func encodeBody(body *types.Body) *payloadBody {
+	if body == nil {
+		return nil
+	}
+	txs := make([]hexutil.Bytes, len(body.Transactions))
+	for i, tx := range encodeTransactions(body.Transactions) {
+		txs[i] = tx
+	}
+	return &payloadBody{Transactions: txs}
+}
+
+// size returns the total size of the transactions in the body.
+func (body *payloadBody) size() int {
+	if body == nil {
+		return 0
+	}
+	var size int
+	for _, tx := range body.Transactions {
+		size += len(tx)
 	}
+	return size
+}
+
+func blockToExecutableData(block *types.Block) *executableData {
 	return &executableData{
-		BlockHash:    block.Hash(),
-		ParentHash:   block.ParentHash(),
-		Miner:        block.Coinbase(),
-		StateRoot:    block.Root(),
-		Number:       block.NumberU64(),
-		GasLimit:     block.GasLimit(),
-		GasUsed:      block.GasUsed(),
-		Timestamp:    block.Time(),
-		ReceiptRoot:  block.ReceiptHash(),
-		LogsBloom:    block.Bloom().Bytes(),
-		Transactions: encodeTransactions(block.Transactions()),
-	}, nil
+		BlockHash:     block.Hash(),
+		ParentHash:    block.ParentHash(),
+		Miner:         block.Coinbase(),
+		StateRoot:     block.Root(),
+		Number:        block.NumberU64(),
+		GasLimit:      block.GasLimit(),
+		GasUsed:       block.GasUsed(),
+		Timestamp:     block.Time(),
+		ReceiptRoot:   block.ReceiptHash(),
+		LogsBloom:     block.Bloom().Bytes(),
+		ExtraData:     block.Extra(),
+		BaseFeePerGas: block.BaseFee(),
+		Difficulty:    block.Difficulty(),
+		Random:        block.MixDigest(),
+		Nonce:         types.EncodeNonce(block.Nonce()),
+		Transactions:  encodeTransactions(block.Transactions()),
+	}
 }
 
 func encodeTransactions(txs []*types.Transaction) [][]byte {
@@ -250,14 +416,19 @@ func decodeTransactions(enc [][]byte) ([]*types.Transaction, error) {
 	return txs, nil
 }
 
-func insertBlockParamsToBlock(config *chainParams.ChainConfig, parent *types.Header, params executableData) (*types.Block, error) {
+// insertBlockParamsToBlock assembles a block from the given execution data,
+// verifying that its hash matches the one committed to by the consensus client.
+func insertBlockParamsToBlock(params executableData) (*types.Block, error) {
 	txs, err := decodeTransactions(params.Transactions)
 	if err != nil {
 		return nil, err
 	}
-
-	number := big.NewInt(0)
-	number.SetUint64(params.Number)
+	if len(params.LogsBloom) != types.BloomByteLength {
+		return nil, fmt.Errorf("invalid logsBloom length: %d", len(params.LogsBloom))
+	}
+	if params.Difficulty == nil {
+		return nil, errors.New("missing difficulty")
+	}
 	header := &types.Header{
 		ParentHash:  params.ParentHash,
 		UncleHash:   types.EmptyUncleHash,
@@ -266,33 +437,120 @@ func insertBlockParamsToBlock(config *chainParams.ChainConfig, parent *types.Hea
 		TxHash:      types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil)),
 		ReceiptHash: params.ReceiptRoot,
 		Bloom:       types.BytesToBloom(params.LogsBloom),
-		Difficulty:  big.NewInt(1),
-		Number:      number,
+		Difficulty:  params.Difficulty,
+		Number:      new(big.Int).SetUint64(params.Number),
 		GasLimit:    params.GasLimit,
 		GasUsed:     params.GasUsed,
 		Time:        params.Timestamp,
-	}
-	if config.IsLondon(number) {
-		header.BaseFee = misc.CalcBaseFee(config, parent)
+		Extra:       params.ExtraData,
+		MixDigest:   params.Random,
+		Nonce:       params.Nonce,
+		BaseFee:     params.BaseFeePerGas,
 	}
 	block := types.NewBlockWithHeader(header).WithBody(txs, nil /* uncles */)
+	if block.Hash() != params.BlockHash {
+		return nil, fmt.Errorf("%w: computed %x, payload %x", errInvalidBlockHash, block.Hash(), params.BlockHash)
+	}
 	return block, nil
 }
 
-// NewBlock creates an Eth1 block, inserts it in the chain, and either returns true,
-// or false + an error. This is a bit redundant for go, but simplifies things on the
-// eth2 side.
+// NewBlock creates an Eth1 block, inserts it in the chain, and returns the status
+// of the block. Invalid blocks are remembered, so that their descendants can be
+// rejected without being executed.
+//
+// If the parent of the block is unknown, the block is queued and its ancestors
+// are retrieved from the network in the background. In that case the returned
+// status is SYNCING, or ACCEPTED if the block extends an already queued one.
 func (api *consensusAPI) NewBlock(params executableData) (*newBlockResponse, error) {
-	parent := api.eth.BlockChain().GetBlockByHash(params.ParentHash)
-	if parent == nil {
-		return &newBlockResponse{false}, fmt.Errorf("could not find parent %x", params.ParentHash)
-	}
-	block, err := insertBlockParamsToBlock(api.eth.BlockChain().Config(), parent.Header(), params)
+	block, err := insertBlockParamsToBlock(params)
 	if err != nil {
-		return nil, err
+		log.Warn("Rejected invalid execution data", "number", params.Number, "hash", params.BlockHash, "err", err)
+		if errors.Is(err, errInvalidBlockHash) {
+			return &newBlockResponse{Status: statusInvalidBlockHash, ErrorCode: errCodeInvalidBlockHash, ValidationError: err.Error()}, nil
+		}
+		// The announced hash of a malformed payload can't be trusted, so nothing is
+		// remembered about it: caching it would allow poisoning any hash.
+		return invalidResponse(api.latestValidAncestor(params.ParentHash), errCodeInvalidPayload, err), nil
+	}
+	hash := block.Hash()
+	if latestValid, ok := api.badBlocks.Get(hash); ok {
+		return invalidResponse(latestValid.(common.Hash), errCodeInvalidBlock, errors.New("previously rejected block")), nil
+	}
+	if latestValid, ok := api.badBlocks.Get(block.ParentHash()); ok {
+		log.Warn("Rejected descendant of invalid block", "number", block.Number(), "hash", hash, "parentHash", block.ParentHash())
+		api.badBlocks.Add(hash, latestValid)
+		return invalidResponse(latestValid.(common.Hash), errCodeInvalidAncestor, fmt.Errorf("invalid ancestor %x", block.ParentHash())), nil
+	}
+	if !api.eth.BlockChain().HasBlock(block.ParentHash(), block.NumberU64()-1) {
+		status := api.delayBlock(block)
+		log.Info("Queued block with unknown parent", "number", block.Number(), "hash", hash, "parentHash", block.ParentHash(), "status", status)
+		return &newBlockResponse{Status: status}, nil
+	}
+	if _, err := api.eth.BlockChain().InsertChainWithoutSealVerification(block); err != nil {
+		if !api.isInvalidBlockErr(block, err) {
+			log.Error("Failed to import block", "number", block.Number(), "hash", hash, "err", err)
+			return nil, err
+		}
+		log.Warn("Rejected invalid block", "number", block.Number(), "hash", hash, "err", err)
+		return api.invalidBlock(hash, block.ParentHash(), errCodeInvalidBlock, err), nil
+	}
+	return &newBlockResponse{Status: statusValid, LatestValidHash: &hash}, nil
+}
+
+// isInvalidBlockErr reports whether a block import failed because the block
+// violates the consensus rules, as opposed to a local failure (database errors,
+// missing parent state, reorgs below the finalized block) which says nothing
+// about the validity of the block and must not get it remembered as bad.
+func (api *consensusAPI) isInvalidBlockErr(block *types.Block, err error) bool {
+	// Blocks failing header, body or state validation are recorded by the chain
+	if rawdb.ReadBadBlock(api.eth.ChainDb(), block.Hash()) != nil {
+		return true
+	}
+	// The bad block records are capped, so recognise the typed errors too
+	for _, invalid := range []error{
+		consensus.ErrInvalidNumber, core.ErrBannedHash,
+		core.ErrNonceTooLow, core.ErrNonceTooHigh, core.ErrGasLimitReached,
+		core.ErrInsufficientFundsForTransfer, core.ErrInsufficientFunds, core.ErrGasUintOverflow,
+		core.ErrIntrinsicGas, core.ErrTipAboveFeeCap, core.ErrTipVeryHigh,
+		core.ErrFeeCapVeryHigh, core.ErrFeeCapTooLow, core.ErrSenderNoEOA,
+	} {
+		if errors.Is(err, invalid) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidBlock records the given block as bad and creates the response for it.
+func (api *consensusAPI) invalidBlock(hash common.Hash, parent common.Hash, code int, err error) *newBlockResponse {
+	latestValid := api.latestValidAncestor(parent)
+	api.badBlocks.Add(hash, latestValid)
+	return invalidResponse(latestValid, code, err)
+}
+
+// latestValidAncestor returns the latest valid ancestor of a rejected block: the
+// parent if it was imported, or otherwise the one recorded for the parent if it's
+// a known bad block.
+func (api *consensusAPI) latestValidAncestor(parent common.Hash) common.Hash {
+	if cached, ok := api.badBlocks.Get(parent); ok {
+		return cached.(common.Hash)
+	}
+	if api.eth.BlockChain().GetBlockByHash(parent) != nil {
+		return parent
 	}
-	_, err = api.eth.BlockChain().InsertChainWithoutSealVerification(block)
-	return &newBlockResponse{err == nil}, err
+	return common.Hash{}
+}
+
+func invalidResponse(latestValid common.Hash, code int, err error) *newBlockResponse {
+	resp := &newBlockResponse{
+		Status:          statusInvalid,
+		ErrorCode:       code,
+		ValidationError: err.Error(),
+	}
+	if latestValid != (common.Hash{}) {
+		resp.LatestValidHash = &latestValid
+	}
+	return resp
 }
 
 // Used in tests to add a the list of transactions from a block to the tx pool.
@@ -304,12 +562,30 @@ func (api *consensusAPI) addBlockTxs(block *types.Block) error {
 }
 
 // FinalizeBlock is called to mark a block as synchronized, so
-// that data that is no longer needed can be removed.
+// that data that is no longer needed can be removed. The finalized
+// block is persisted and exposed via the "finalized" block tag.
 func (api *consensusAPI) FinalizeBlock(blockHash common.Hash) (*genericResponse, error) {
+	bc := api.eth.BlockChain()
+	block := bc.GetBlockByHash(blockHash)
+	if block == nil {
+		return &genericResponse{false}, fmt.Errorf("could not find block %x", blockHash)
+	}
+	if bc.GetCanonicalHash(block.NumberU64()) != blockHash {
+		return &genericResponse{false}, fmt.Errorf("block %x is not canonical", blockHash)
+	}
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() > block.NumberU64() {
+		return &genericResponse{false}, fmt.Errorf("block %x is below the finalized block #%d", blockHash, finalized.NumberU64())
+	}
+	bc.SetFinalized(block)
 	return &genericResponse{true}, nil
 }
 
-// SetHead is called to perform a force choice.
+// SetHead is called to perform a force choice. The given block becomes the head
+// of the canonical chain, reorganising the chain if needed.
 func (api *consensusAPI) SetHead(newHead common.Hash) (*genericResponse, error) {
+	if err := api.eth.BlockChain().SetChainHead(newHead); err != nil {
+		log.Warn("Failed to set chain head", "hash", newHead, "err", err)
+		return &genericResponse{false}, err
+	}
 	return &genericResponse{true}, nil
 }
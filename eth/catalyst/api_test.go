@@ -17,16 +17,22 @@
 package catalyst
 
 import (
+	"bytes"
+	"errors"
 	"math/big"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/transition"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -99,7 +105,7 @@ func generateTestChainWithFork(n int, fork int) (*core.Genesis, []*types.Block,
 		g.SetExtra([]byte("testF"))
 	}
 	gblock := genesis.ToBlock(db)
-	engine := ethash.NewFaker()
+	engine := transition.New(ethash.NewFaker())
 	blocks, _ := core.GenerateChain(config, gblock, engine, db, n, generate)
 	blocks = append([]*types.Block{gblock}, blocks...)
 	forkedBlocks, _ := core.GenerateChain(config, blocks[fork], engine, db, n-fork, generateFork)
@@ -156,6 +162,152 @@ func TestEth2AssembleBlockWithAnotherBlocksTxs(t *testing.T) {
 	}
 }
 
+// Tests that assembled blocks commit the transactions in the configured order,
+// putting the local ones first by default.
+func TestEth2AssembleBlockTxOrder(t *testing.T) {
+	remoteKey, _ := crypto.GenerateKey()
+	genesis := &core.Genesis{
+		Config: params.AllEthashProtocolChanges,
+		Alloc: core.GenesisAlloc{
+			testAddr: {Balance: testBalance},
+			crypto.PubkeyToAddress(remoteKey.PublicKey): {Balance: testBalance},
+		},
+		ExtraData: []byte("test genesis"),
+		Timestamp: 9000,
+		BaseFee:   big.NewInt(params.InitialBaseFee),
+	}
+	signer := types.LatestSigner(genesis.Config)
+	local := types.MustSignNewTx(testKey, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &common.Address{},
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(2 * params.InitialBaseFee),
+	})
+	remote := types.MustSignNewTx(remoteKey, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &common.Address{},
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(3 * params.InitialBaseFee),
+	})
+	for _, tt := range []struct {
+		order string
+		want  []*types.Transaction
+	}{
+		{"", []*types.Transaction{local, remote}},
+		{miner.TxOrderPrice, []*types.Transaction{local, remote}},
+		{miner.TxOrderGlobalPrice, []*types.Transaction{remote, local}},
+	} {
+		ethcfg := &ethconfig.Config{Genesis: genesis, Ethash: ethash.Config{PowMode: ethash.ModeFake}}
+		ethcfg.Miner.TxOrder = tt.order
+
+		n, ethservice := startEthServiceWithConfig(t, ethcfg, nil)
+		if err := ethservice.TxPool().AddLocal(local); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+		if errs := ethservice.TxPool().AddRemotesSync([]*types.Transaction{remote}); errs[0] != nil {
+			t.Fatalf("failed to add remote transaction: %v", errs[0])
+		}
+		api := newConsensusAPI(ethservice)
+		parent := ethservice.BlockChain().CurrentBlock()
+		execData, err := api.AssembleBlock(assembleBlockParams{ParentHash: parent.Hash(), Timestamp: parent.Time() + 5})
+		n.Close()
+		if err != nil {
+			t.Fatalf("order %q: error producing block: %v", tt.order, err)
+		}
+		if len(execData.Transactions) != len(tt.want) {
+			t.Fatalf("order %q: transaction count mismatch: have %d, want %d", tt.order, len(execData.Transactions), len(tt.want))
+		}
+		for i, enc := range execData.Transactions {
+			var tx types.Transaction
+			if err := tx.UnmarshalBinary(enc); err != nil {
+				t.Fatalf("order %q: failed to decode transaction %d: %v", tt.order, i, err)
+			}
+			if tx.Hash() != tt.want[i].Hash() {
+				t.Errorf("order %q: transaction %d mismatch: have %x, want %x", tt.order, i, tx.Hash(), tt.want[i].Hash())
+			}
+		}
+	}
+}
+
+// waitPayloadTxs waits until the background builder included the given number
+// of transactions into the payload.
+func waitPayloadTxs(t *testing.T, api *consensusAPI, id payloadID, txs int) {
+	t.Helper()
+
+	payload := api.payloads.get(id)
+	for i := 0; i < 100; i++ {
+		payload.lock.Lock()
+		have := len(payload.block.Transactions())
+		payload.lock.Unlock()
+		if have == txs {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("payload %s not updated with %d transactions", id, txs)
+}
+
+func TestEth2PreparePayload(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	var (
+		api    = newConsensusAPI(ethservice)
+		signer = types.NewEIP155Signer(ethservice.BlockChain().Config().ChainID)
+		attrs  = payloadAttributes{
+			ParentHash:   blocks[8].Hash(),
+			Timestamp:    blocks[8].Time() + 5,
+			FeeRecipient: common.Address{0x01},
+		}
+	)
+	newTx := func(nonce uint64) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0x02}, big.NewInt(1000), params.TxGas, big.NewInt(2*params.InitialBaseFee), nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("error signing transaction, err=%v", err)
+		}
+		return tx
+	}
+	ethservice.TxPool().AddLocal(newTx(0))
+
+	resp, err := api.PreparePayload(attrs)
+	if err != nil {
+		t.Fatalf("error preparing payload, err=%v", err)
+	}
+	if again, err := api.PreparePayload(attrs); err != nil || again.PayloadID != resp.PayloadID {
+		t.Fatalf("payload id mismatch for identical attributes: have %s, want %s (err=%v)", again.PayloadID, resp.PayloadID, err)
+	}
+	waitPayloadTxs(t, api, resp.PayloadID, 1)
+
+	// New transactions should be picked up by the background builder
+	ethservice.TxPool().AddLocal(newTx(1))
+	waitPayloadTxs(t, api, resp.PayloadID, 2)
+
+	execData, err := api.GetPayload(resp.PayloadID)
+	if err != nil {
+		t.Fatalf("error retrieving payload, err=%v", err)
+	}
+	if len(execData.Transactions) != 2 {
+		t.Fatalf("invalid number of transactions %d != 2", len(execData.Transactions))
+	}
+	if execData.Miner != attrs.FeeRecipient {
+		t.Fatalf("invalid fee recipient %x != %x", execData.Miner, attrs.FeeRecipient)
+	}
+	// Retrieving the payload stops the building
+	ethservice.TxPool().AddLocal(newTx(2))
+	time.Sleep(2 * payloadRecommitDelay)
+	if again, err := api.GetPayload(resp.PayloadID); err != nil || again.BlockHash != execData.BlockHash {
+		t.Fatalf("payload changed after retrieval (err=%v)", err)
+	}
+	if _, err := api.GetPayload(payloadID{0x01}); err == nil {
+		t.Fatalf("retrieved unknown payload")
+	}
+	attrs.ParentHash = common.Hash{0x01}
+	if _, err := api.PreparePayload(attrs); err == nil {
+		t.Fatalf("prepared payload on unknown parent")
+	}
+}
+
 func TestEth2NewBlock(t *testing.T) {
 	genesis, blocks, forkedBlocks := generateTestChainWithFork(10, 4)
 	n, ethservice := startEthService(t, genesis, blocks[1:5])
@@ -163,70 +315,287 @@ func TestEth2NewBlock(t *testing.T) {
 
 	api := newConsensusAPI(ethservice)
 	for i := 5; i < 10; i++ {
-		p := executableData{
-			ParentHash:   ethservice.BlockChain().CurrentBlock().Hash(),
-			Miner:        blocks[i].Coinbase(),
-			StateRoot:    blocks[i].Root(),
-			GasLimit:     blocks[i].GasLimit(),
-			GasUsed:      blocks[i].GasUsed(),
-			Transactions: encodeTransactions(blocks[i].Transactions()),
-			ReceiptRoot:  blocks[i].ReceiptHash(),
-			LogsBloom:    blocks[i].Bloom().Bytes(),
-			BlockHash:    blocks[i].Hash(),
-			Timestamp:    blocks[i].Time(),
-			Number:       uint64(i),
-		}
-		success, err := api.NewBlock(p)
-		if err != nil || !success.Valid {
+		success, err := api.NewBlock(*blockToExecutableData(blocks[i]))
+		if err != nil || success.Status != statusValid {
 			t.Fatalf("Failed to insert block: %v", err)
 		}
 	}
-
 	exp := ethservice.BlockChain().CurrentBlock().Hash()
 
 	// Introduce the fork point.
-	lastBlockNum := blocks[4].Number()
-	lastBlock := blocks[4]
 	for i := 0; i < 4; i++ {
-		lastBlockNum.Add(lastBlockNum, big.NewInt(1))
-		p := executableData{
-			ParentHash:   lastBlock.Hash(),
-			Miner:        forkedBlocks[i].Coinbase(),
-			StateRoot:    forkedBlocks[i].Root(),
-			Number:       lastBlockNum.Uint64(),
-			GasLimit:     forkedBlocks[i].GasLimit(),
-			GasUsed:      forkedBlocks[i].GasUsed(),
-			Transactions: encodeTransactions(blocks[i].Transactions()),
-			ReceiptRoot:  forkedBlocks[i].ReceiptHash(),
-			LogsBloom:    forkedBlocks[i].Bloom().Bytes(),
-			BlockHash:    forkedBlocks[i].Hash(),
-			Timestamp:    forkedBlocks[i].Time(),
-		}
-		success, err := api.NewBlock(p)
-		if err != nil || !success.Valid {
+		success, err := api.NewBlock(*blockToExecutableData(forkedBlocks[i]))
+		if err != nil || success.Status != statusValid {
 			t.Fatalf("Failed to insert forked block #%d: %v", i, err)
 		}
-		lastBlock, err = insertBlockParamsToBlock(ethservice.BlockChain().Config(), lastBlock.Header(), p)
-		if err != nil {
-			t.Fatal(err)
-		}
 	}
-
 	if ethservice.BlockChain().CurrentBlock().Hash() != exp {
 		t.Fatalf("Wrong head after inserting fork %x != %x", exp, ethservice.BlockChain().CurrentBlock().Hash())
 	}
 }
 
+func TestEth2NewBlockInvalidHash(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+
+	// The execution data must round-trip into the exact same block
+	params := blockToExecutableData(blocks[9])
+	block, err := insertBlockParamsToBlock(*params)
+	if err != nil {
+		t.Fatalf("Failed to convert execution data: %v", err)
+	}
+	if block.Hash() != blocks[9].Hash() || !bytes.Equal(block.Extra(), blocks[9].Extra()) || block.BaseFee().Cmp(blocks[9].BaseFee()) != 0 {
+		t.Fatalf("Block mismatch after round-trip: have %x, want %x", block.Hash(), blocks[9].Hash())
+	}
+	// Any field modification must be rejected due to the hash mismatch
+	params.ExtraData = []byte("tampered")
+	resp, err := api.NewBlock(*params)
+	if err != nil || resp.Status != statusInvalidBlockHash || resp.ErrorCode != errCodeInvalidBlockHash {
+		t.Fatalf("Tampered block accepted: %+v, err=%v", resp, err)
+	}
+	if ethservice.BlockChain().HasBlock(params.BlockHash, params.Number) {
+		t.Fatalf("Tampered block imported")
+	}
+}
+
+func TestEth2NewBlockInvalid(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+
+	// Create a block with a bogus state root and a descendant of it
+	header := blocks[9].Header()
+	header.Root = common.Hash{0x01}
+	bad := types.NewBlockWithHeader(header).WithBody(blocks[9].Transactions(), nil)
+
+	header = blocks[9].Header()
+	header.ParentHash, header.Number = bad.Hash(), new(big.Int).Add(bad.Number(), common.Big1)
+	child := types.NewBlockWithHeader(header)
+
+	latestValid := blocks[8].Hash()
+	resp, err := api.NewBlock(*blockToExecutableData(bad))
+	if err != nil || resp.Status != statusInvalid || resp.ErrorCode != errCodeInvalidBlock || resp.ValidationError == "" {
+		t.Fatalf("unexpected response for invalid block: %+v, err=%v", resp, err)
+	}
+	if resp.LatestValidHash == nil || *resp.LatestValidHash != latestValid {
+		t.Fatalf("latest valid hash mismatch: have %v, want %x", resp.LatestValidHash, latestValid)
+	}
+	// The descendant must be rejected without execution
+	resp, err = api.NewBlock(*blockToExecutableData(child))
+	if err != nil || resp.Status != statusInvalid || resp.ErrorCode != errCodeInvalidAncestor {
+		t.Fatalf("unexpected response for descendant of invalid block: %+v, err=%v", resp, err)
+	}
+	if resp.LatestValidHash == nil || *resp.LatestValidHash != latestValid {
+		t.Fatalf("latest valid hash mismatch: have %v, want %x", resp.LatestValidHash, latestValid)
+	}
+	// Malformed payloads must be rejected without remembering their announced hash
+	params := blockToExecutableData(blocks[9])
+	params.LogsBloom = params.LogsBloom[1:]
+	resp, err = api.NewBlock(*params)
+	if err != nil || resp.Status != statusInvalid || resp.ErrorCode != errCodeInvalidPayload {
+		t.Fatalf("unexpected response for malformed block: %+v, err=%v", resp, err)
+	}
+	if api.badBlocks.Contains(blocks[9].Hash()) {
+		t.Fatalf("announced hash of malformed block remembered as bad")
+	}
+	// Valid blocks report themselves as the latest valid hash
+	resp, err = api.NewBlock(*blockToExecutableData(blocks[9]))
+	if err != nil || resp.Status != statusValid || *resp.LatestValidHash != blocks[9].Hash() {
+		t.Fatalf("unexpected response for valid block: %+v, err=%v", resp, err)
+	}
+}
+
+func TestEth2NewBlockImportFailure(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	if _, err := api.FinalizeBlock(blocks[5].Hash()); err != nil {
+		t.Fatalf("Failed to finalize block: %v", err)
+	}
+	// Create a heavier fork below the finalized block
+	db := rawdb.NewMemoryDatabase()
+	fork, _ := core.GenerateChain(genesis.Config, genesis.MustCommit(db), ethash.NewFaker(), db, 10, func(i int, g *core.BlockGen) {
+		g.OffsetTime(5)
+		if i < 2 {
+			g.SetExtra([]byte("test"))
+		} else {
+			g.SetExtra([]byte("fork"))
+		}
+	})
+	if fork[1].Hash() != blocks[2].Hash() {
+		t.Fatalf("fork point mismatch: have %x, want %x", fork[1].Hash(), blocks[2].Hash())
+	}
+	fork = fork[2:]
+	// The fork is valid, so the reorg rejection must not get it remembered as bad
+	for i, block := range fork {
+		resp, err := api.NewBlock(*blockToExecutableData(block))
+		if err == nil {
+			if resp.Status != statusValid {
+				t.Fatalf("unexpected response for fork block %d: %+v", i, resp)
+			}
+			continue
+		}
+		if !errors.Is(err, core.ErrReorgBelowFinalized) {
+			t.Fatalf("fork block %d: import error mismatch: have %v, want %v", i, err, core.ErrReorgBelowFinalized)
+		}
+		if api.badBlocks.Contains(block.Hash()) {
+			t.Fatalf("fork block %d remembered as bad", i)
+		}
+		return
+	}
+	t.Fatalf("reorg below finalized block succeeded")
+}
+
+func TestEth2NewBlockUnknownParent(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:5])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+
+	defer func(delay time.Duration) { backfillRetryDelay = delay }(backfillRetryDelay)
+	backfillRetryDelay = 10 * time.Millisecond
+
+	// Blocks with unknown ancestors are queued until the gap is filled
+	if resp, err := api.NewBlock(*blockToExecutableData(blocks[7])); err != nil || resp.Status != statusSyncing {
+		t.Fatalf("unexpected response for block with unknown parent: %+v, err=%v", resp, err)
+	}
+	if resp, err := api.NewBlock(*blockToExecutableData(blocks[8])); err != nil || resp.Status != statusAccepted {
+		t.Fatalf("unexpected response for block extending queued block: %+v, err=%v", resp, err)
+	}
+	// Wait for the backfill to give up, there are no peers to sync from
+	waitSuspended := func() {
+		for i := 0; ; i++ {
+			api.blocks.lock.Lock()
+			syncing := api.blocks.syncing
+			api.blocks.lock.Unlock()
+			if !syncing {
+				break
+			}
+			if i == 100 {
+				t.Fatal("backfill did not terminate")
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	waitSuspended()
+
+	// Extending the queued chain must restart the suspended backfill
+	if resp, err := api.NewBlock(*blockToExecutableData(blocks[9])); err != nil || resp.Status != statusAccepted {
+		t.Fatalf("unexpected response for block extending queued block: %+v, err=%v", resp, err)
+	}
+	api.blocks.lock.Lock()
+	syncing := api.blocks.syncing
+	api.blocks.lock.Unlock()
+	if !syncing {
+		t.Fatalf("backfill not restarted")
+	}
+	waitSuspended()
+
+	for _, block := range blocks[5:7] {
+		if resp, err := api.NewBlock(*blockToExecutableData(block)); err != nil || resp.Status != statusValid {
+			t.Fatalf("failed to insert block: %+v, err=%v", resp, err)
+		}
+	}
+	api.importQueued()
+	if head := ethservice.BlockChain().CurrentBlock(); head.Hash() != blocks[9].Hash() {
+		t.Fatalf("wrong head after importing queued blocks: have #%d [%x], want #%d [%x]", head.NumberU64(), head.Hash(), blocks[9].NumberU64(), blocks[9].Hash())
+	}
+}
+
+func TestEth2GetPayloadBodies(t *testing.T) {
+	genesis, blocks := generateTestChain()
+	n, ethservice := startEthService(t, genesis, blocks[1:9])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+
+	// Retrieve bodies by hash, unknown ones are reported as null
+	bodies, err := api.GetPayloadBodiesByHash([]common.Hash{blocks[3].Hash(), {0x01}, blocks[8].Hash()})
+	if err != nil {
+		t.Fatalf("Failed to retrieve bodies by hash: %v", err)
+	}
+	if len(bodies) != 3 || bodies[0] == nil || bodies[1] != nil || bodies[2] == nil {
+		t.Fatalf("Unexpected bodies by hash: %v", bodies)
+	}
+	if len(bodies[0].Transactions) != len(blocks[3].Transactions()) {
+		t.Fatalf("Transaction count mismatch: have %d, want %d", len(bodies[0].Transactions), len(blocks[3].Transactions()))
+	}
+	// Retrieve bodies by range, the response must end at the head
+	bodies, err = api.GetPayloadBodiesByRange(5, 10)
+	if err != nil {
+		t.Fatalf("Failed to retrieve bodies by range: %v", err)
+	}
+	if len(bodies) != 4 {
+		t.Fatalf("Body count mismatch: have %d, want %d", len(bodies), 4)
+	}
+	// Oversized requests must be rejected
+	if _, err := api.GetPayloadBodiesByRange(0, maxBodyRequestCount+1); err == nil {
+		t.Fatalf("Oversized range request succeeded")
+	}
+	if _, err := api.GetPayloadBodiesByHash(make([]common.Hash, maxBodyRequestCount+1)); err == nil {
+		t.Fatalf("Oversized hash request succeeded")
+	}
+}
+
+func TestEth2SetHead(t *testing.T) {
+	genesis, blocks, forkedBlocks := generateTestChainWithFork(10, 4)
+	n, ethservice := startEthService(t, genesis, blocks[1:])
+	defer n.Close()
+
+	api := newConsensusAPI(ethservice)
+	if _, err := ethservice.BlockChain().InsertChain(forkedBlocks[:2]); err != nil {
+		t.Fatalf("Failed to insert forked blocks: %v", err)
+	}
+	if _, err := api.SetHead(forkedBlocks[1].Hash()); err != nil {
+		t.Fatalf("Failed to set head to forked block: %v", err)
+	}
+	if head := ethservice.BlockChain().CurrentBlock(); head.Hash() != forkedBlocks[1].Hash() {
+		t.Fatalf("Wrong head after set head %x != %x", head.Hash(), forkedBlocks[1].Hash())
+	}
+	if resp, err := api.SetHead(common.Hash{0x01}); err == nil || resp.Success {
+		t.Fatalf("Set head to unknown block succeeded")
+	}
+	if _, err := api.FinalizeBlock(blocks[4].Hash()); err != nil {
+		t.Fatalf("Failed to finalize block: %v", err)
+	}
+	if _, err := api.SetHead(blocks[9].Hash()); err != nil {
+		t.Fatalf("Failed to set head to canonical block: %v", err)
+	}
+	if head := ethservice.BlockChain().CurrentBlock(); head.Hash() != blocks[9].Hash() {
+		t.Fatalf("Wrong head after set head %x != %x", head.Hash(), blocks[9].Hash())
+	}
+	if _, err := api.FinalizeBlock(blocks[6].Hash()); err != nil {
+		t.Fatalf("Failed to finalize block: %v", err)
+	}
+	if resp, err := api.SetHead(forkedBlocks[1].Hash()); err == nil || resp.Success {
+		t.Fatalf("Set head below finalized block succeeded")
+	}
+}
+
 // startEthService creates a full node instance for testing.
 func startEthService(t *testing.T, genesis *core.Genesis, blocks []*types.Block) (*node.Node, *eth.Ethereum) {
 	t.Helper()
 
+	return startEthServiceWithConfig(t, &ethconfig.Config{Genesis: genesis, Ethash: ethash.Config{PowMode: ethash.ModeFake}}, blocks)
+}
+
+// startEthServiceWithConfig creates a full node with the given eth config and
+// imports the given blocks into it.
+func startEthServiceWithConfig(t *testing.T, ethcfg *ethconfig.Config, blocks []*types.Block) (*node.Node, *eth.Ethereum) {
+	t.Helper()
+
 	n, err := node.New(&node.Config{})
 	if err != nil {
 		t.Fatal("can't create node:", err)
 	}
-
-	ethcfg := &ethconfig.Config{Genesis: genesis, Ethash: ethash.Config{PowMode: ethash.ModeFake}}
 	ethservice, err := eth.New(n, ethcfg)
 	if err != nil {
 		t.Fatal("can't create eth service:", err)
@@ -17,8 +17,11 @@
 package catalyst
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 //go:generate go run github.com/fjl/gencodec -type assembleBlockParams -field-override assembleBlockParamsMarshaling -out gen_blockparams.go
@@ -34,37 +37,110 @@ type assembleBlockParamsMarshaling struct {
 	Timestamp hexutil.Uint64
 }
 
+//go:generate go run github.com/fjl/gencodec -type payloadAttributes -field-override payloadAttributesMarshaling -out gen_payloadattributes.go
+
+// payloadAttributes are the parameters used to prepare a payload which is built
+// in the background until it is retrieved.
+type payloadAttributes struct {
+	ParentHash   common.Hash    `json:"parentHash"    gencodec:"required"`
+	Timestamp    uint64         `json:"timestamp"     gencodec:"required"`
+	FeeRecipient common.Address `json:"feeRecipient"  gencodec:"required"`
+}
+
+// JSON type overrides for payloadAttributes.
+type payloadAttributesMarshaling struct {
+	Timestamp hexutil.Uint64
+}
+
 //go:generate go run github.com/fjl/gencodec -type executableData -field-override executableDataMarshaling -out gen_ed.go
 
 // Structure described at https://notes.ethereum.org/@n0ble/rayonism-the-merge-spec#Parameters1
 type executableData struct {
-	BlockHash    common.Hash    `json:"blockHash"     gencodec:"required"`
-	ParentHash   common.Hash    `json:"parentHash"    gencodec:"required"`
-	Miner        common.Address `json:"miner"         gencodec:"required"`
-	StateRoot    common.Hash    `json:"stateRoot"     gencodec:"required"`
-	Number       uint64         `json:"number"        gencodec:"required"`
-	GasLimit     uint64         `json:"gasLimit"      gencodec:"required"`
-	GasUsed      uint64         `json:"gasUsed"       gencodec:"required"`
-	Timestamp    uint64         `json:"timestamp"     gencodec:"required"`
-	ReceiptRoot  common.Hash    `json:"receiptsRoot"  gencodec:"required"`
-	LogsBloom    []byte         `json:"logsBloom"     gencodec:"required"`
-	Transactions [][]byte       `json:"transactions"  gencodec:"required"`
+	BlockHash     common.Hash      `json:"blockHash"     gencodec:"required"`
+	ParentHash    common.Hash      `json:"parentHash"    gencodec:"required"`
+	Miner         common.Address   `json:"miner"         gencodec:"required"`
+	StateRoot     common.Hash      `json:"stateRoot"     gencodec:"required"`
+	Number        uint64           `json:"number"        gencodec:"required"`
+	GasLimit      uint64           `json:"gasLimit"      gencodec:"required"`
+	GasUsed       uint64           `json:"gasUsed"       gencodec:"required"`
+	Timestamp     uint64           `json:"timestamp"     gencodec:"required"`
+	ReceiptRoot   common.Hash      `json:"receiptsRoot"  gencodec:"required"`
+	LogsBloom     []byte           `json:"logsBloom"     gencodec:"required"`
+	ExtraData     []byte           `json:"extraData"     gencodec:"required"`
+	BaseFeePerGas *big.Int         `json:"baseFeePerGas"`
+	Difficulty    *big.Int         `json:"difficulty"    gencodec:"required"`
+	Random        common.Hash      `json:"random"        gencodec:"required"`
+	Nonce         types.BlockNonce `json:"nonce"         gencodec:"required"`
+	Transactions  [][]byte         `json:"transactions"  gencodec:"required"`
 }
 
 // JSON type overrides for executableData.
 type executableDataMarshaling struct {
-	Number       hexutil.Uint64
-	GasLimit     hexutil.Uint64
-	GasUsed      hexutil.Uint64
-	Timestamp    hexutil.Uint64
-	LogsBloom    hexutil.Bytes
-	Transactions []hexutil.Bytes
+	Number        hexutil.Uint64
+	GasLimit      hexutil.Uint64
+	GasUsed       hexutil.Uint64
+	Timestamp     hexutil.Uint64
+	LogsBloom     hexutil.Bytes
+	ExtraData     hexutil.Bytes
+	BaseFeePerGas *hexutil.Big
+	Difficulty    *hexutil.Big
+	Transactions  []hexutil.Bytes
 }
 
+// Statuses of a block handed to NewBlock.
+const (
+	statusValid            = "VALID"              // The block was imported and is valid
+	statusInvalid          = "INVALID"            // The block or one of its ancestors failed validation
+	statusInvalidBlockHash = "INVALID_BLOCK_HASH" // The execution data doesn't hash to the announced block hash
+	statusSyncing          = "SYNCING"            // The ancestors of the block are being retrieved
+	statusAccepted         = "ACCEPTED"           // The block extends a queued block and will be imported after syncing
+)
+
+// Validation error codes reported along with invalid blocks.
+const (
+	errCodeInvalidPayload   = 1 // The execution data is malformed
+	errCodeInvalidBlockHash = 2 // The execution data doesn't hash to the announced block hash
+	errCodeInvalidBlock     = 3 // The block failed header or state transition validation
+	errCodeInvalidAncestor  = 4 // The block descends from a previously rejected block
+)
+
+// newBlockResponse is the outcome of handing a block to NewBlock. For invalid
+// blocks, LatestValidHash is the deepest ancestor known to be valid, if any.
 type newBlockResponse struct {
-	Valid bool `json:"valid"`
+	Status          string       `json:"status"`
+	LatestValidHash *common.Hash `json:"latestValidHash"`
+	ErrorCode       int          `json:"errorCode,omitempty"`
+	ValidationError string       `json:"validationError,omitempty"`
 }
 
 type genericResponse struct {
 	Success bool `json:"success"`
 }
+
+// payloadBody is the body of an execution payload. Bodies of unknown payloads
+// are reported as null.
+type payloadBody struct {
+	Transactions []hexutil.Bytes `json:"transactions"`
+}
+
+type preparePayloadResponse struct {
+	PayloadID payloadID `json:"payloadId"`
+}
+
+// payloadID is an identifier of a payload being built in the background.
+type payloadID [8]byte
+
+// String implements fmt.Stringer.
+func (id payloadID) String() string {
+	return hexutil.Encode(id[:])
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id payloadID) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(id[:]).MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *payloadID) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("payloadID", input, id[:])
+}
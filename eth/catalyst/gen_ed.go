@@ -5,9 +5,11 @@ package catalyst
 import (
 	"encoding/json"
 	"errors"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 var _ = (*executableDataMarshaling)(nil)
@@ -15,17 +17,22 @@ var _ = (*executableDataMarshaling)(nil)
 // MarshalJSON marshals as JSON.
 func (e executableData) MarshalJSON() ([]byte, error) {
 	type executableData struct {
-		BlockHash    common.Hash     `json:"blockHash"     gencodec:"required"`
-		ParentHash   common.Hash     `json:"parentHash"    gencodec:"required"`
-		Miner        common.Address  `json:"miner"         gencodec:"required"`
-		StateRoot    common.Hash     `json:"stateRoot"     gencodec:"required"`
-		Number       hexutil.Uint64  `json:"number"        gencodec:"required"`
-		GasLimit     hexutil.Uint64  `json:"gasLimit"      gencodec:"required"`
-		GasUsed      hexutil.Uint64  `json:"gasUsed"       gencodec:"required"`
-		Timestamp    hexutil.Uint64  `json:"timestamp"     gencodec:"required"`
-		ReceiptRoot  common.Hash     `json:"receiptsRoot"  gencodec:"required"`
-		LogsBloom    hexutil.Bytes   `json:"logsBloom"     gencodec:"required"`
-		Transactions []hexutil.Bytes `json:"transactions"  gencodec:"required"`
+		BlockHash     common.Hash      `json:"blockHash"     gencodec:"required"`
+		ParentHash    common.Hash      `json:"parentHash"    gencodec:"required"`
+		Miner         common.Address   `json:"miner"         gencodec:"required"`
+		StateRoot     common.Hash      `json:"stateRoot"     gencodec:"required"`
+		Number        hexutil.Uint64   `json:"number"        gencodec:"required"`
+		GasLimit      hexutil.Uint64   `json:"gasLimit"      gencodec:"required"`
+		GasUsed       hexutil.Uint64   `json:"gasUsed"       gencodec:"required"`
+		Timestamp     hexutil.Uint64   `json:"timestamp"     gencodec:"required"`
+		ReceiptRoot   common.Hash      `json:"receiptsRoot"  gencodec:"required"`
+		LogsBloom     hexutil.Bytes    `json:"logsBloom"     gencodec:"required"`
+		ExtraData     hexutil.Bytes    `json:"extraData"     gencodec:"required"`
+		BaseFeePerGas *hexutil.Big     `json:"baseFeePerGas"`
+		Difficulty    *hexutil.Big     `json:"difficulty"    gencodec:"required"`
+		Random        common.Hash      `json:"random"        gencodec:"required"`
+		Nonce         types.BlockNonce `json:"nonce"         gencodec:"required"`
+		Transactions  []hexutil.Bytes  `json:"transactions"  gencodec:"required"`
 	}
 	var enc executableData
 	enc.BlockHash = e.BlockHash
@@ -38,6 +45,11 @@ func (e executableData) MarshalJSON() ([]byte, error) {
 	enc.Timestamp = hexutil.Uint64(e.Timestamp)
 	enc.ReceiptRoot = e.ReceiptRoot
 	enc.LogsBloom = e.LogsBloom
+	enc.ExtraData = e.ExtraData
+	enc.BaseFeePerGas = (*hexutil.Big)(e.BaseFeePerGas)
+	enc.Difficulty = (*hexutil.Big)(e.Difficulty)
+	enc.Random = e.Random
+	enc.Nonce = e.Nonce
 	if e.Transactions != nil {
 		enc.Transactions = make([]hexutil.Bytes, len(e.Transactions))
 		for k, v := range e.Transactions {
@@ -50,17 +62,22 @@ func (e executableData) MarshalJSON() ([]byte, error) {
 // UnmarshalJSON unmarshals from JSON.
 func (e *executableData) UnmarshalJSON(input []byte) error {
 	type executableData struct {
-		BlockHash    *common.Hash    `json:"blockHash"     gencodec:"required"`
-		ParentHash   *common.Hash    `json:"parentHash"    gencodec:"required"`
-		Miner        *common.Address `json:"miner"         gencodec:"required"`
-		StateRoot    *common.Hash    `json:"stateRoot"     gencodec:"required"`
-		Number       *hexutil.Uint64 `json:"number"        gencodec:"required"`
-		GasLimit     *hexutil.Uint64 `json:"gasLimit"      gencodec:"required"`
-		GasUsed      *hexutil.Uint64 `json:"gasUsed"       gencodec:"required"`
-		Timestamp    *hexutil.Uint64 `json:"timestamp"     gencodec:"required"`
-		ReceiptRoot  *common.Hash    `json:"receiptsRoot"  gencodec:"required"`
-		LogsBloom    *hexutil.Bytes  `json:"logsBloom"     gencodec:"required"`
-		Transactions []hexutil.Bytes `json:"transactions"  gencodec:"required"`
+		BlockHash     *common.Hash      `json:"blockHash"     gencodec:"required"`
+		ParentHash    *common.Hash      `json:"parentHash"    gencodec:"required"`
+		Miner         *common.Address   `json:"miner"         gencodec:"required"`
+		StateRoot     *common.Hash      `json:"stateRoot"     gencodec:"required"`
+		Number        *hexutil.Uint64   `json:"number"        gencodec:"required"`
+		GasLimit      *hexutil.Uint64   `json:"gasLimit"      gencodec:"required"`
+		GasUsed       *hexutil.Uint64   `json:"gasUsed"       gencodec:"required"`
+		Timestamp     *hexutil.Uint64   `json:"timestamp"     gencodec:"required"`
+		ReceiptRoot   *common.Hash      `json:"receiptsRoot"  gencodec:"required"`
+		LogsBloom     *hexutil.Bytes    `json:"logsBloom"     gencodec:"required"`
+		ExtraData     *hexutil.Bytes    `json:"extraData"     gencodec:"required"`
+		BaseFeePerGas *hexutil.Big      `json:"baseFeePerGas"`
+		Difficulty    *hexutil.Big      `json:"difficulty"    gencodec:"required"`
+		Random        *common.Hash      `json:"random"        gencodec:"required"`
+		Nonce         *types.BlockNonce `json:"nonce"         gencodec:"required"`
+		Transactions  []hexutil.Bytes   `json:"transactions"  gencodec:"required"`
 	}
 	var dec executableData
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -106,6 +123,25 @@ func (e *executableData) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'logsBloom' for executableData")
 	}
 	e.LogsBloom = *dec.LogsBloom
+	if dec.ExtraData == nil {
+		return errors.New("missing required field 'extraData' for executableData")
+	}
+	e.ExtraData = *dec.ExtraData
+	if dec.BaseFeePerGas != nil {
+		e.BaseFeePerGas = (*big.Int)(dec.BaseFeePerGas)
+	}
+	if dec.Difficulty == nil {
+		return errors.New("missing required field 'difficulty' for executableData")
+	}
+	e.Difficulty = (*big.Int)(dec.Difficulty)
+	if dec.Random == nil {
+		return errors.New("missing required field 'random' for executableData")
+	}
+	e.Random = *dec.Random
+	if dec.Nonce == nil {
+		return errors.New("missing required field 'nonce' for executableData")
+	}
+	e.Nonce = *dec.Nonce
 	if dec.Transactions == nil {
 		return errors.New("missing required field 'transactions' for executableData")
 	}
@@ -0,0 +1,199 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxTrackedPayloads is the maximum number of prepared payloads the API
+	// keeps around. Older ones are discarded and stop being built.
+	maxTrackedPayloads = 10
+
+	// payloadRecommitDelay is the minimum time to wait after new transactions
+	// arrived before rebuilding a payload, batching up bursts of transactions.
+	payloadRecommitDelay = 500 * time.Millisecond
+
+	// payloadBuildTimeout is the maximum time a payload is improved in the
+	// background if it is never retrieved.
+	payloadBuildTimeout = 2 * time.Minute
+
+	// txChanSize is the size of channel listening to NewTxsEvent.
+	txChanSize = 4096
+)
+
+// computePayloadID derives a deterministic identifier from the attributes of a
+// payload, so that repeated preparation requests map to the same payload.
+func computePayloadID(params payloadAttributes) payloadID {
+	hasher := sha256.New()
+	hasher.Write(params.ParentHash[:])
+	binary.Write(hasher, binary.BigEndian, params.Timestamp)
+	hasher.Write(params.FeeRecipient[:])
+
+	var id payloadID
+	copy(id[:], hasher.Sum(nil)[:8])
+	return id
+}
+
+// payload is a block being built in the background. It starts out as an empty
+// block and is replaced whenever a rebuild yields more fees for the recipient.
+type payload struct {
+	lock     sync.Mutex
+	block    *types.Block // Best version of the payload built so far
+	fees     *big.Int     // Priority fees earned by the best version
+	resolved bool         // Whether the payload was retrieved and is frozen
+
+	stop     chan struct{} // Channel to signal the builder to terminate
+	stopOnce sync.Once
+}
+
+// update replaces the current version of the payload if the given block is more
+// profitable, or equally profitable but fuller. Updates after the payload was
+// resolved are ignored.
+func (p *payload) update(block *types.Block, fees *big.Int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.resolved {
+		return
+	}
+	if cmp := fees.Cmp(p.fees); cmp > 0 || (cmp == 0 && block.GasUsed() > p.block.GasUsed()) {
+		p.block, p.fees = block, fees
+		log.Debug("Updated payload", "number", block.NumberU64(), "hash", block.Hash(), "txs", len(block.Transactions()), "fees", fees)
+	}
+}
+
+// resolve terminates the background building and returns the best version of
+// the payload. Subsequent calls return the same block.
+func (p *payload) resolve() *types.Block {
+	p.lock.Lock()
+	p.resolved = true
+	block := p.block
+	p.lock.Unlock()
+
+	p.terminate()
+	return block
+}
+
+// terminate signals the background builder to stop, if it's still running.
+func (p *payload) terminate() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// buildPayload assembles an empty block on top of the given parent, so that a
+// payload is available right away, and starts improving it in the background
+// with the pending transactions of the pool.
+func (api *consensusAPI) buildPayload(parent *types.Block, params payloadAttributes) (*payload, error) {
+	empty, fees, err := api.assembleBlock(parent, params.Timestamp, params.FeeRecipient, true)
+	if err != nil {
+		return nil, err
+	}
+	p := &payload{
+		block: empty,
+		fees:  fees,
+		stop:  make(chan struct{}),
+	}
+	txsCh := make(chan core.NewTxsEvent, txChanSize)
+	txsSub := api.eth.TxPool().SubscribeNewTxsEvent(txsCh)
+
+	go func() {
+		defer txsSub.Unsubscribe()
+
+		var (
+			recommit = time.NewTimer(0) // Fill the payload right away
+			timeout  = time.NewTimer(payloadBuildTimeout)
+			pending  = true // Whether a rebuild is already scheduled
+		)
+		defer recommit.Stop()
+		defer timeout.Stop()
+
+		for {
+			select {
+			case <-recommit.C:
+				pending = false
+				block, fees, err := api.assembleBlock(parent, params.Timestamp, params.FeeRecipient, false)
+				if err != nil {
+					log.Warn("Failed to build payload", "parentHash", params.ParentHash, "err", err)
+					continue
+				}
+				p.update(block, fees)
+
+			case <-txsCh:
+				if !pending {
+					recommit.Reset(payloadRecommitDelay)
+					pending = true
+				}
+			case <-timeout.C:
+				log.Debug("Stopped building unretrieved payload", "parentHash", params.ParentHash)
+				return
+			case <-p.stop:
+				return
+			case <-txsSub.Err():
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+// payloadQueue keeps track of a bounded number of prepared payloads, discarding
+// the oldest ones when full.
+type payloadQueue struct {
+	lock     sync.Mutex
+	payloads map[payloadID]*payload
+	order    []payloadID // Payload ids in insertion order, oldest first
+}
+
+func newPayloadQueue() *payloadQueue {
+	return &payloadQueue{payloads: make(map[payloadID]*payload)}
+}
+
+// put adds a payload to the queue, evicting the oldest one if it's full.
+func (q *payloadQueue) put(id payloadID, p *payload) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if old, ok := q.payloads[id]; ok {
+		old.terminate()
+	} else {
+		q.order = append(q.order, id)
+	}
+	q.payloads[id] = p
+
+	if len(q.order) > maxTrackedPayloads {
+		q.payloads[q.order[0]].terminate()
+		delete(q.payloads, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+// get retrieves a previously prepared payload, or nil if it's unknown.
+func (q *payloadQueue) get(id payloadID) *payload {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.payloads[id]
+}
@@ -0,0 +1,284 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SimulatorConfig contains the settings of the developer mode beacon simulator.
+type SimulatorConfig struct {
+	Period      time.Duration // Time between slots (0 = produce blocks as soon as transactions arrive)
+	OnlyPending bool          // Whether to skip slots while there are no pending transactions
+	FinalityLag uint64        // Number of blocks the finalized block trails the head by
+}
+
+// simulator is an in-process stand-in for a consensus client, driving a catalyst
+// chain through the consensus API for local development.
+type simulator struct {
+	api    *consensusAPI
+	eth    *eth.Ethereum
+	config SimulatorConfig
+
+	lock        sync.Mutex // Serializes block production and reorgs
+	finalityLag uint64     // Current finality lag, adjustable via RPC
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// RegisterSimulator adds a beacon simulator to the node, producing catalyst
+// blocks on the configured slot period, along with the dev APIs to control it.
+func RegisterSimulator(stack *node.Node, backend *eth.Ethereum, config SimulatorConfig) error {
+	chainconfig := backend.BlockChain().Config()
+	if chainconfig.CatalystBlock == nil && chainconfig.CatalystTotalDifficulty == nil {
+		return errors.New("neither catalystBlock nor catalystTotalDifficulty is set in genesis config")
+	}
+	sim := newSimulator(backend, config)
+
+	log.Warn("Catalyst beacon simulator enabled", "period", config.Period, "onlyPending", config.OnlyPending)
+	stack.RegisterAPIs([]rpc.API{
+		{
+			Namespace: "dev",
+			Version:   "1.0",
+			Service:   &simulatorAPI{sim},
+			Public:    true,
+		},
+	})
+	stack.RegisterLifecycle(sim)
+	return nil
+}
+
+func newSimulator(backend *eth.Ethereum, config SimulatorConfig) *simulator {
+	return &simulator{
+		api:         newConsensusAPI(backend),
+		eth:         backend,
+		config:      config,
+		finalityLag: config.FinalityLag,
+		quit:        make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting the slot loop.
+func (s *simulator) Start() error {
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the slot loop.
+func (s *simulator) Stop() error {
+	close(s.quit)
+	s.wg.Wait()
+	return nil
+}
+
+// loop produces a block on every slot, or whenever new transactions arrive if
+// no slot period is configured.
+func (s *simulator) loop() {
+	defer s.wg.Done()
+
+	txsCh := make(chan core.NewTxsEvent, txChanSize)
+	txsSub := s.eth.TxPool().SubscribeNewTxsEvent(txsCh)
+	defer txsSub.Unsubscribe()
+
+	var slots <-chan time.Time
+	if s.config.Period > 0 {
+		ticker := time.NewTicker(s.config.Period)
+		defer ticker.Stop()
+		slots = ticker.C
+	}
+	for {
+		select {
+		case <-slots:
+			if s.config.OnlyPending && !s.hasPending() {
+				continue
+			}
+			if _, err := s.produceBlock(); err != nil {
+				log.Error("Failed to produce simulated block", "err", err)
+			}
+		case <-txsCh:
+			if slots != nil || !s.hasPending() {
+				continue
+			}
+			if _, err := s.produceBlock(); err != nil {
+				log.Error("Failed to produce simulated block", "err", err)
+			}
+		case <-txsSub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// hasPending reports whether the transaction pool has executable transactions.
+func (s *simulator) hasPending() bool {
+	pending, _ := s.eth.TxPool().Stats()
+	return pending > 0
+}
+
+// produceBlock builds a block on top of the current head, makes it the new head
+// and advances the finalized block.
+func (s *simulator) produceBlock() (*types.Block, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	parent := s.eth.BlockChain().CurrentBlock()
+	block, err := s.buildBlock(parent, s.timestamp(parent, nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setHead(block); err != nil {
+		return nil, err
+	}
+	log.Info("Produced simulated block", "number", block.Number(), "hash", block.Hash(), "txs", len(block.Transactions()))
+	return block, nil
+}
+
+// reorg replaces the given number of blocks at the head of the chain with a
+// freshly built sibling chain of the same length. The transactions of the
+// orphaned blocks return to the pool and are included in the following slots.
+func (s *simulator) reorg(depth uint64) (*types.Block, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	bc := s.eth.BlockChain()
+	head := bc.CurrentBlock()
+	if depth == 0 || depth > head.NumberU64() {
+		return nil, fmt.Errorf("invalid reorg depth %d at head #%d", depth, head.NumberU64())
+	}
+	ancestor := bc.GetBlockByNumber(head.NumberU64() - depth)
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() > ancestor.NumberU64() {
+		return nil, fmt.Errorf("reorg depth %d reaches below finalized block #%d", depth, finalized.NumberU64())
+	}
+	parent := ancestor
+	for i := uint64(1); i <= depth; i++ {
+		orphan := bc.GetBlockByNumber(ancestor.NumberU64() + i)
+		block, err := s.buildBlock(parent, s.timestamp(parent, orphan))
+		if err != nil {
+			return nil, err
+		}
+		parent = block
+	}
+	if err := s.setHead(parent); err != nil {
+		return nil, err
+	}
+	log.Info("Simulated chain reorg", "depth", depth, "number", parent.Number(), "hash", parent.Hash(), "dropped", head.Hash())
+	return parent, nil
+}
+
+// timestamp returns the timestamp to use for a child of the given parent. If the
+// child replaces an orphaned block, it's timestamped after it to ensure the two
+// are distinct even if they have the same contents.
+func (s *simulator) timestamp(parent *types.Block, orphan *types.Block) uint64 {
+	timestamp := uint64(time.Now().Unix())
+	if timestamp <= parent.Time() {
+		timestamp = parent.Time() + 1
+	}
+	if orphan != nil && timestamp <= orphan.Time() {
+		timestamp = orphan.Time() + 1
+	}
+	return timestamp
+}
+
+// buildBlock assembles a block on top of the given parent and imports it, just
+// like a consensus client would.
+func (s *simulator) buildBlock(parent *types.Block, timestamp uint64) (*types.Block, error) {
+	data, err := s.api.AssembleBlock(assembleBlockParams{ParentHash: parent.Hash(), Timestamp: timestamp})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.api.NewBlock(*data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != statusValid {
+		return nil, fmt.Errorf("block %x rejected: %s %s", data.BlockHash, resp.Status, resp.ValidationError)
+	}
+	block := s.eth.BlockChain().GetBlockByHash(data.BlockHash)
+	if block == nil {
+		return nil, fmt.Errorf("imported block %x not found", data.BlockHash)
+	}
+	return block, nil
+}
+
+// setHead makes the given block the head of the chain and finalizes the block
+// trailing it by the finality lag, if it's past the current finalized block.
+func (s *simulator) setHead(block *types.Block) error {
+	if _, err := s.api.SetHead(block.Hash()); err != nil {
+		return err
+	}
+	if block.NumberU64() < s.finalityLag {
+		return nil
+	}
+	bc := s.eth.BlockChain()
+	number := block.NumberU64() - s.finalityLag
+	if finalized := bc.CurrentFinalizedBlock(); finalized != nil && finalized.NumberU64() >= number {
+		return nil
+	}
+	_, err := s.api.FinalizeBlock(bc.GetCanonicalHash(number))
+	return err
+}
+
+// simulatorAPI offers control over the beacon simulator for testing reorg and
+// finality handling of applications.
+type simulatorAPI struct {
+	sim *simulator
+}
+
+// ProduceBlock builds a new block on top of the current head right away,
+// regardless of the slot period, and returns its hash.
+func (api *simulatorAPI) ProduceBlock() (common.Hash, error) {
+	block, err := api.sim.produceBlock()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return block.Hash(), nil
+}
+
+// Reorg replaces the given number of blocks at the head of the chain with a
+// sibling chain of the same length, returning the hash of the new head. The
+// reorg can't reach below the finalized block.
+func (api *simulatorAPI) Reorg(depth uint64) (common.Hash, error) {
+	block, err := api.sim.reorg(depth)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return block.Hash(), nil
+}
+
+// SetFinalityLag sets the number of blocks the finalized block trails the head
+// by, taking effect from the next block on. The finalized block never moves
+// backwards, so raising the lag stalls finality until the head catches up.
+func (api *simulatorAPI) SetFinalityLag(lag uint64) {
+	api.sim.lock.Lock()
+	defer api.sim.lock.Unlock()
+
+	api.sim.finalityLag = lag
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the simulator produces and finalizes blocks, and that reorgs replace
+// the head of the chain without crossing the finalized block.
+func TestSimulatorReorg(t *testing.T) {
+	genesis, blocks, _ := generateTestChainWithFork(1, 0)
+	n, ethservice := startEthService(t, genesis, blocks[1:])
+	defer n.Close()
+
+	var (
+		sim = newSimulator(ethservice, SimulatorConfig{FinalityLag: 2})
+		api = &simulatorAPI{sim}
+		bc  = ethservice.BlockChain()
+	)
+	var produced []*types.Block
+	for i := 0; i < 3; i++ {
+		block, err := sim.produceBlock()
+		if err != nil {
+			t.Fatalf("failed to produce block %d: %v", i, err)
+		}
+		if head := bc.CurrentBlock().Hash(); head != block.Hash() {
+			t.Fatalf("block %d: head mismatch: have %x, want %x", i, head, block.Hash())
+		}
+		produced = append(produced, block)
+	}
+	if finalized := bc.CurrentFinalizedBlock(); finalized.Hash() != produced[0].Hash() {
+		t.Fatalf("finalized block mismatch: have #%d, want #%d", finalized.NumberU64(), produced[0].NumberU64())
+	}
+	if _, err := api.Reorg(3); err == nil {
+		t.Fatalf("reorg below finalized block succeeded")
+	}
+	head, err := api.Reorg(2)
+	if err != nil {
+		t.Fatalf("failed to reorg: %v", err)
+	}
+	if current := bc.CurrentBlock(); current.Hash() != head || current.NumberU64() != produced[2].NumberU64() {
+		t.Fatalf("head mismatch after reorg: have #%d %x, want #%d %x", current.NumberU64(), current.Hash(), produced[2].NumberU64(), head)
+	}
+	for _, block := range produced[1:] {
+		if bc.GetCanonicalHash(block.NumberU64()) == block.Hash() {
+			t.Errorf("block #%d still canonical after reorg", block.NumberU64())
+		}
+	}
+	// Raising the finality lag stalls finality
+	api.SetFinalityLag(10)
+	if _, err := api.ProduceBlock(); err != nil {
+		t.Fatalf("failed to produce block: %v", err)
+	}
+	if finalized := bc.CurrentFinalizedBlock(); finalized.Hash() != produced[0].Hash() {
+		t.Fatalf("finalized block advanced to #%d despite lag", finalized.NumberU64())
+	}
+}
+
+// Tests that without a slot period, the simulator produces a block as soon as
+// a transaction is pending.
+func TestSimulatorPendingTrigger(t *testing.T) {
+	genesis, blocks, _ := generateTestChainWithFork(1, 0)
+	n, ethservice := startEthService(t, genesis, blocks[1:])
+	defer n.Close()
+
+	sim := newSimulator(ethservice, SimulatorConfig{})
+	sim.Start()
+	defer sim.Stop()
+
+	signer := types.LatestSigner(ethservice.BlockChain().Config())
+	tx, err := types.SignTx(types.NewTransaction(0, testAddr, big.NewInt(1000), params.TxGas, big.NewInt(2*params.InitialBaseFee), nil), signer, testKey)
+	if err != nil {
+		t.Fatalf("failed to sign tx: %v", err)
+	}
+	if err := ethservice.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add tx: %v", err)
+	}
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(50 * time.Millisecond) {
+		if ethservice.BlockChain().GetTransactionLookup(tx.Hash()) != nil {
+			return
+		}
+	}
+	t.Fatalf("transaction not included")
+}
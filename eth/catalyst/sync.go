@@ -0,0 +1,223 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// maxQueuedBlocks is the maximum number of blocks with unknown ancestors kept
+	// around while syncing. Older ones are discarded when the limit is reached.
+	maxQueuedBlocks = 64
+
+	// maxBackfillAttempts is the number of consecutive backfill attempts without
+	// progress after which the sync is suspended until the next block arrives.
+	maxBackfillAttempts = 5
+)
+
+// backfillRetryDelay is the delay before retrying a backfill which didn't make
+// any progress, doubled after every further attempt.
+var backfillRetryDelay = time.Second
+
+// blockQueue keeps track of the blocks handed to NewBlock whose ancestors are
+// not yet available locally, along with the state of the background sync.
+type blockQueue struct {
+	lock    sync.Mutex
+	blocks  map[common.Hash]*types.Block // Queued blocks, keyed by block hash
+	order   []common.Hash                // Queued block hashes in insertion order, oldest first
+	syncing bool                         // Whether a backfill is currently running
+}
+
+func newBlockQueue() *blockQueue {
+	return &blockQueue{blocks: make(map[common.Hash]*types.Block)}
+}
+
+// put adds a block to the queue, evicting the oldest one if it's full.
+func (q *blockQueue) put(block *types.Block) {
+	hash := block.Hash()
+	if _, ok := q.blocks[hash]; ok {
+		return
+	}
+	q.blocks[hash] = block
+	q.order = append(q.order, hash)
+
+	if len(q.order) > maxQueuedBlocks {
+		delete(q.blocks, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+// remove deletes a block from the queue.
+func (q *blockQueue) remove(hash common.Hash) {
+	delete(q.blocks, hash)
+	for i, queued := range q.order {
+		if queued == hash {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// delayBlock queues a block whose parent is unknown and makes sure a backfill
+// of its ancestors is running, returning the status to report for the block.
+func (api *consensusAPI) delayBlock(block *types.Block) string {
+	q := api.blocks
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	status := statusSyncing
+	if _, ok := q.blocks[block.ParentHash()]; ok {
+		status = statusAccepted
+	}
+	q.put(block)
+
+	// Restart the backfill even if the block extends a queued one, as an earlier
+	// backfill of the same chain might have been given up on
+	if !q.syncing {
+		if anchor, ok := q.nextAnchor(api.eth.BlockChain().HasBlock); ok {
+			q.syncing = true
+			go api.backfill(anchor)
+		}
+	}
+	return status
+}
+
+// backfill retrieves the ancestors of the given trusted block from the network
+// and imports them, along with all the queued blocks they unlock. If queued
+// blocks with unknown ancestors remain afterwards, those are synced next.
+//
+// Attempts not making any progress are retried with an exponential backoff, up
+// to maxBackfillAttempts times in a row.
+func (api *consensusAPI) backfill(anchor common.Hash) {
+	var (
+		attempts int
+		delay    = backfillRetryDelay
+	)
+	for {
+		if err := api.eth.Downloader().BeaconSync(anchor, api.insertBlocks); err != nil {
+			log.Warn("Failed to retrieve ancestors of queued block", "anchor", anchor, "err", err)
+		}
+		api.importQueued()
+
+		q := api.blocks
+		q.lock.Lock()
+		next, ok := q.nextAnchor(api.eth.BlockChain().HasBlock)
+		if !ok {
+			q.syncing = false
+			q.lock.Unlock()
+			return
+		}
+		if next != anchor {
+			// Progress was made, continue with the next gap right away
+			q.lock.Unlock()
+			anchor, attempts, delay = next, 0, backfillRetryDelay
+			continue
+		}
+		if attempts++; attempts >= maxBackfillAttempts {
+			// Give up for now, the next block handed in restarts the backfill
+			log.Warn("Suspended backfill of queued blocks", "anchor", anchor, "attempts", attempts)
+			q.syncing = false
+			q.lock.Unlock()
+			return
+		}
+		q.lock.Unlock()
+
+		log.Debug("Retrying backfill of queued blocks", "anchor", anchor, "attempts", attempts, "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// nextAnchor returns the parent hash of the newest queued block whose ancestors
+// are neither available locally nor queued.
+func (q *blockQueue) nextAnchor(hasBlock func(common.Hash, uint64) bool) (common.Hash, bool) {
+	var (
+		anchor common.Hash
+		number uint64
+		found  bool
+	)
+	for _, block := range q.blocks {
+		if _, ok := q.blocks[block.ParentHash()]; ok {
+			continue
+		}
+		if hasBlock(block.ParentHash(), block.NumberU64()-1) {
+			continue
+		}
+		if !found || block.NumberU64() > number {
+			anchor, number, found = block.ParentHash(), block.NumberU64(), true
+		}
+	}
+	return anchor, found
+}
+
+// importQueued imports all the queued blocks whose parent is available locally,
+// until no more progress can be made.
+func (api *consensusAPI) importQueued() {
+	q := api.blocks
+	for {
+		// Collect the blocks ready for import, without holding the lock during the
+		// import itself so that new blocks can be queued in the meantime
+		q.lock.Lock()
+		var ready []*types.Block
+		for _, hash := range q.order {
+			block := q.blocks[hash]
+			if api.badBlocks.Contains(block.ParentHash()) || api.eth.BlockChain().HasBlock(block.ParentHash(), block.NumberU64()-1) {
+				ready = append(ready, block)
+			}
+		}
+		for _, block := range ready {
+			q.remove(block.Hash())
+		}
+		q.lock.Unlock()
+
+		if len(ready) == 0 {
+			return
+		}
+		for _, block := range ready {
+			hash := block.Hash()
+			if latestValid, ok := api.badBlocks.Get(block.ParentHash()); ok {
+				log.Warn("Dropped queued descendant of invalid block", "number", block.Number(), "hash", hash)
+				api.badBlocks.Add(hash, latestValid)
+			} else if _, err := api.eth.BlockChain().InsertChainWithoutSealVerification(block); err != nil {
+				log.Warn("Failed to import queued block", "number", block.Number(), "hash", hash, "err", err)
+				if api.isInvalidBlockErr(block, err) {
+					api.invalidBlock(hash, block.ParentHash(), errCodeInvalidBlock, err)
+				}
+			} else {
+				log.Info("Imported queued block", "number", block.Number(), "hash", hash)
+			}
+		}
+	}
+}
+
+// insertBlocks imports a batch of blocks retrieved by the downloader, skipping
+// seal verification as the chain is anchored to a block handed in by the
+// consensus client.
+func (api *consensusAPI) insertBlocks(blocks types.Blocks) (int, error) {
+	for i, block := range blocks {
+		if _, err := api.eth.BlockChain().InsertChainWithoutSealVerification(block); err != nil {
+			return i, err
+		}
+	}
+	return len(blocks), nil
+}
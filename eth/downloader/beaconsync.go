@@ -0,0 +1,250 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var errBeaconAnchorMismatch = errors.New("beacon sync reached genesis without a known ancestor")
+
+// BeaconSync backfills the chain segment ending in the given block hash, which
+// was announced by an external consensus client. Headers are retrieved backwards
+// from the anchor until a locally known block is reached, after which the block
+// bodies are retrieved and the blocks are imported in order via the insert
+// callback. As the anchor is trusted, the retrieved chain is validated solely
+// by its hash linkage to the anchor.
+//
+// The method is synchronous and returns once the anchor block was imported.
+func (d *Downloader) BeaconSync(head common.Hash, insert func(types.Blocks) (int, error)) error {
+	// Make sure only one goroutine is ever allowed past this point at once
+	if !atomic.CompareAndSwapInt32(&d.synchronising, 0, 1) {
+		return errBusy
+	}
+	defer atomic.StoreInt32(&d.synchronising, 0)
+
+	// Clean any leftover deliveries from a previous sync and create the cancel
+	// channel to allow data deliveries
+	for _, ch := range []chan dataPack{d.headerCh, d.bodyCh, d.receiptCh} {
+		for empty := false; !empty; {
+			select {
+			case <-ch:
+			default:
+				empty = true
+			}
+		}
+	}
+	d.cancelLock.Lock()
+	d.cancelCh = make(chan struct{})
+	d.cancelPeer = ""
+	d.cancelLock.Unlock()
+
+	defer d.Cancel() // No matter what, we can't leave the cancel channel open
+
+	log.Info("Beacon sync started", "head", head)
+	start := time.Now()
+
+	headers, err := d.fetchBeaconHeaders(head)
+	if err != nil {
+		return err
+	}
+	for len(headers) > 0 {
+		batch := headers
+		if len(batch) > MaxBlockFetch {
+			batch = batch[:MaxBlockFetch]
+		}
+		blocks, err := d.fetchBeaconBodies(batch)
+		if err != nil {
+			return err
+		}
+		if index, err := insert(blocks); err != nil {
+			return fmt.Errorf("%w: block #%d [%x…] import failed: %v", errInvalidChain, blocks[index].NumberU64(), blocks[index].Hash().Bytes()[:4], err)
+		}
+		headers = headers[len(blocks):]
+	}
+	log.Info("Beacon sync completed", "head", head, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// fetchBeaconHeaders retrieves the headers of all the unknown ancestors of the
+// given block, including the block itself, in ascending order.
+func (d *Downloader) fetchBeaconHeaders(head common.Hash) ([]*types.Header, error) {
+	var (
+		headers []*types.Header // Retrieved headers, newest first
+		next    = head          // Hash of the next header to retrieve
+	)
+	for !d.hasBeaconAncestor(next) {
+		batch, err := d.requestBeaconHeaders(next)
+		if err != nil {
+			return nil, err
+		}
+		for _, header := range batch {
+			if d.hasBeaconAncestor(next) {
+				break
+			}
+			if header.Number.Sign() == 0 {
+				return nil, errBeaconAnchorMismatch
+			}
+			headers = append(headers, header)
+			next = header.ParentHash
+		}
+		log.Debug("Retrieved beacon sync headers", "count", len(headers), "next", next)
+	}
+	// Reverse the headers into import order
+	for i, j := 0, len(headers)-1; i < j; i, j = i+1, j-1 {
+		headers[i], headers[j] = headers[j], headers[i]
+	}
+	return headers, nil
+}
+
+// hasBeaconAncestor checks whether the block with the given hash is available
+// locally, terminating the backwards header retrieval.
+func (d *Downloader) hasBeaconAncestor(hash common.Hash) bool {
+	header := d.lightchain.GetHeaderByHash(hash)
+	return header != nil && d.blockchain.HasBlock(hash, header.Number.Uint64())
+}
+
+// requestBeaconHeaders retrieves a batch of headers in reverse order from the
+// block with the given hash, trying all peers until one delivers a chain that
+// correctly links to the requested hash.
+func (d *Downloader) requestBeaconHeaders(hash common.Hash) ([]*types.Header, error) {
+	for _, p := range d.peers.AllPeers() {
+		go p.peer.RequestHeadersByHash(hash, MaxHeaderFetch, 0, true)
+
+		headers, err := d.waitBeaconHeaders(p, hash)
+		if err == nil {
+			return headers, nil
+		}
+		if errors.Is(err, errCanceled) {
+			return nil, err
+		}
+		p.log.Debug("Beacon header retrieval failed", "hash", hash, "err", err)
+	}
+	return nil, errPeersUnavailable
+}
+
+// waitBeaconHeaders waits for a header response from the given peer, verifying
+// that it's a chain descending from the requested hash.
+func (d *Downloader) waitBeaconHeaders(p *peerConnection, hash common.Hash) ([]*types.Header, error) {
+	ttl := d.peers.rates.TargetTimeout()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCanceled
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the requested peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			headers := packet.(*headerPack).headers
+			if len(headers) == 0 {
+				return nil, errEmptyHeaderSet
+			}
+			for i, header := range headers {
+				if header.Hash() != hash {
+					return nil, fmt.Errorf("%w: header %d hash mismatch: have %x, want %x", errInvalidChain, i, header.Hash(), hash)
+				}
+				hash = header.ParentHash
+			}
+			return headers, nil
+
+		case <-timeout:
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
+
+// fetchBeaconBodies retrieves the bodies of the given headers, returning the
+// assembled blocks. Fewer blocks than headers might be returned if the peers
+// only delivered part of the requested bodies.
+func (d *Downloader) fetchBeaconBodies(headers []*types.Header) (types.Blocks, error) {
+	hashes := make([]common.Hash, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	for _, p := range d.peers.AllPeers() {
+		go p.peer.RequestBodies(hashes)
+
+		blocks, err := d.waitBeaconBodies(p, headers)
+		if err == nil {
+			return blocks, nil
+		}
+		if errors.Is(err, errCanceled) {
+			return nil, err
+		}
+		p.log.Debug("Beacon body retrieval failed", "count", len(hashes), "err", err)
+	}
+	return nil, errPeersUnavailable
+}
+
+// waitBeaconBodies waits for a body response from the given peer, verifying the
+// bodies against the requested headers.
+func (d *Downloader) waitBeaconBodies(p *peerConnection, headers []*types.Header) (types.Blocks, error) {
+	ttl := d.peers.rates.TargetTimeout()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCanceled
+
+		case packet := <-d.bodyCh:
+			// Discard anything not from the requested peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received bodies from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			bodies := packet.(*bodyPack)
+			if bodies.Items() == 0 || bodies.Items() > len(headers) {
+				return nil, fmt.Errorf("%w: returned bodies %d, requested %d", errBadPeer, bodies.Items(), len(headers))
+			}
+			blocks := make(types.Blocks, bodies.Items())
+			for i := range blocks {
+				header := headers[i]
+				if types.DeriveSha(types.Transactions(bodies.transactions[i]), trie.NewStackTrie(nil)) != header.TxHash {
+					return nil, fmt.Errorf("%w: body %d transaction root mismatch", errInvalidBody, i)
+				}
+				if types.CalcUncleHash(bodies.uncles[i]) != header.UncleHash {
+					return nil, fmt.Errorf("%w: body %d uncle hash mismatch", errInvalidBody, i)
+				}
+				blocks[i] = types.NewBlockWithHeader(header).WithBody(bodies.transactions[i], bodies.uncles[i])
+			}
+			return blocks, nil
+
+		case <-timeout:
+			return nil, errTimeout
+
+		case <-d.headerCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
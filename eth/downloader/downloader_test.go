@@ -1711,3 +1711,30 @@ func testCheckpointEnforcement(t *testing.T, protocol uint, mode SyncMode) {
 		assertOwnChain(t, tester, chain.len())
 	}
 }
+
+// Tests that a beacon sync backfills all the ancestors of an announced head,
+// skipping peers that cannot serve the trusted chain.
+func TestBeaconSync66(t *testing.T) { testBeaconSync(t, eth.ETH66) }
+
+func testBeaconSync(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(2*MaxBlockFetch + 15)
+	tester.newPeer("fork", protocol, testChainForkLightA.shorten(chain.len()))
+	tester.newPeer("peer", protocol, chain)
+
+	if err := tester.downloader.BeaconSync(chain.headBlock().Hash(), tester.InsertChain); err != nil {
+		t.Fatalf("failed to beacon sync: %v", err)
+	}
+	assertOwnChain(t, tester, chain.len())
+	if head := tester.CurrentBlock().Hash(); head != chain.headBlock().Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, chain.headBlock().Hash())
+	}
+	// Syncing to an anchor nobody knows about must fail
+	if err := tester.downloader.BeaconSync(common.Hash{0x01}, tester.InsertChain); !errors.Is(err, errPeersUnavailable) {
+		t.Fatalf("error mismatch: have %v, want %v", err, errPeersUnavailable)
+	}
+}
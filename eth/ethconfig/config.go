@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/transition"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
@@ -86,11 +87,13 @@ var Defaults = Config{
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+		TxOrder:  miner.TxOrderPrice,
 	},
-	TxPool:      core.DefaultTxPoolConfig,
-	RPCGasCap:   50000000,
-	GPO:         FullNodeGPO,
-	RPCTxFeeCap: 1, // 1 ether
+	TxPool:         core.DefaultTxPoolConfig,
+	TraceFilterCap: 1000,
+	RPCGasCap:      50000000,
+	GPO:            FullNodeGPO,
+	RPCTxFeeCap:    1, // 1 ether
 }
 
 func init() {
@@ -134,6 +137,8 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
+	ParallelExecution bool `toml:",omitempty"` // Whether to execute block transactions optimistically in parallel
+
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 
 	// Whitelist of required block number -> hash values to accept
@@ -182,6 +187,13 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables the index of the accounts touched by transaction traces
+	TraceIndex bool
+
+	// TraceFilterCap is the maximum number of blocks not covered by the trace
+	// index a trace_filter query may re-execute (0=no cap).
+	TraceFilterCap uint64
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -204,6 +216,18 @@ type Config struct {
 
 // CreateConsensusEngine creates a consensus engine for the given chain configuration.
 func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, config *ethash.Config, notify []string, noverify bool, db ethdb.Database) consensus.Engine {
+	engine := createLegacyEngine(stack, chainConfig, config, notify, noverify, db)
+
+	// If the chain transitions to catalyst, wrap the engine to switch over
+	if chainConfig.CatalystBlock != nil || chainConfig.CatalystTotalDifficulty != nil {
+		return transition.New(engine)
+	}
+	return engine
+}
+
+// createLegacyEngine creates the proof-of-authority or proof-of-work engine for
+// the given chain configuration.
+func createLegacyEngine(stack *node.Node, chainConfig *params.ChainConfig, config *ethash.Config, notify []string, noverify bool, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
@@ -61,8 +61,13 @@ type txPool interface {
 	// tx hash.
 	Get(hash common.Hash) *types.Transaction
 
-	// AddRemotes should add the given transactions to the pool.
-	AddRemotes([]*types.Transaction) []error
+	// AddRemotesFrom should add the given transactions received from the given
+	// remote peer to the pool.
+	AddRemotesFrom(string, []*types.Transaction) []error
+
+	// Private returns whether the transaction with the given hash was submitted
+	// privately, and so must never be propagated.
+	Private(hash common.Hash) bool
 
 	// Pending should return pending transactions.
 	// The slice should be modifiable by the caller.
@@ -73,6 +78,21 @@ type txPool interface {
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 }
 
+// publicTxPool is a view of a transaction pool hiding its private transactions,
+// used to serve remote peers.
+type publicTxPool struct {
+	txPool
+}
+
+// Get retrieves the transaction from local txpool with given tx hash, unless it
+// is private.
+func (p publicTxPool) Get(hash common.Hash) *types.Transaction {
+	if p.txPool.Private(hash) {
+		return nil
+	}
+	return p.txPool.Get(hash)
+}
+
 // handlerConfig is the collection of initialization parameters to create a full
 // node network handler.
 type handlerConfig struct {
@@ -228,7 +248,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return p.RequestTxs(hashes)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotesFrom, fetchTx)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
@@ -482,6 +502,10 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 	)
 	// Broadcast transactions to a batch of peers not knowing about it
 	for _, tx := range txs {
+		// Never propagate private transactions
+		if h.txpool.Private(tx.Hash()) {
+			continue
+		}
 		peers := h.peers.peersWithoutTransaction(tx.Hash())
 		// Send the tx unconditionally to a subset of our peers
 		numDirect := int(math.Sqrt(float64(len(peers))))
@@ -450,6 +450,106 @@ func testTransactionPropagation(t *testing.T, protocol uint) {
 	}
 }
 
+// Tests that private transactions are never propagated, neither broadcast to the
+// connected peers, nor announced to the joining ones, nor served on request.
+func TestPrivateTransactionPropagation65(t *testing.T) {
+	testPrivateTransactionPropagation(t, eth.ETH65)
+}
+func TestPrivateTransactionPropagation66(t *testing.T) {
+	testPrivateTransactionPropagation(t, eth.ETH66)
+}
+
+func testPrivateTransactionPropagation(t *testing.T, protocol uint) {
+	t.Parallel()
+
+	// Create a source handler to send transactions from and a number of sinks
+	// to receive them, the last of which joins after the transactions are pooled
+	source := newTestHandler()
+	defer source.close()
+
+	sinks := make([]*testHandler, 5)
+	for i := 0; i < len(sinks); i++ {
+		sinks[i] = newTestHandler()
+		defer sinks[i].close()
+
+		sinks[i].handler.acceptTxs = 1 // mark synced to accept transactions
+	}
+	connect := func(i int, sink *testHandler) {
+		sourcePipe, sinkPipe := p2p.MsgPipe()
+		t.Cleanup(func() {
+			sourcePipe.Close()
+			sinkPipe.Close()
+		})
+		sourcePeer := eth.NewPeer(protocol, p2p.NewPeerPipe(enode.ID{byte(i + 1)}, "", nil, sourcePipe), sourcePipe, source.txpool)
+		sinkPeer := eth.NewPeer(protocol, p2p.NewPeerPipe(enode.ID{0}, "", nil, sinkPipe), sinkPipe, sink.txpool)
+		t.Cleanup(func() {
+			sourcePeer.Close()
+			sinkPeer.Close()
+		})
+		go source.handler.runEthPeer(sourcePeer, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(source.handler), peer)
+		})
+		go sink.handler.runEthPeer(sinkPeer, func(peer *eth.Peer) error {
+			return eth.Handle((*ethHandler)(sink.handler), peer)
+		})
+	}
+	for i, sink := range sinks[:len(sinks)-1] {
+		connect(i, sink)
+	}
+	// Subscribe to all the transaction pools
+	txChs := make([]chan core.NewTxsEvent, len(sinks))
+	for i := 0; i < len(sinks); i++ {
+		txChs[i] = make(chan core.NewTxsEvent, 1024)
+
+		sub := sinks[i].txpool.SubscribeNewTxsEvent(txChs[i])
+		defer sub.Unsubscribe()
+	}
+	// Fill the source pool with interleaved private and public transactions
+	var public, private []*types.Transaction
+	for nonce := 0; nonce < 128; nonce++ {
+		tx := types.NewTransaction(uint64(nonce), common.Address{}, big.NewInt(0), 100000, big.NewInt(0), nil)
+		tx, _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+
+		if nonce%2 == 0 {
+			private = append(private, tx)
+		} else {
+			public = append(public, tx)
+		}
+	}
+	source.txpool.AddPrivates(private)
+	source.txpool.AddRemotes(public)
+
+	// Connect the late sink and ensure all the sinks get only the public ones
+	time.Sleep(250 * time.Millisecond) // Wait until tx events get out of the system (can't use events, tx broadcaster races with peer join)
+	connect(len(sinks)-1, sinks[len(sinks)-1])
+
+	for i := range sinks {
+		for arrived := 0; arrived < len(public); {
+			select {
+			case event := <-txChs[i]:
+				arrived += len(event.Txs)
+			case <-time.NewTimer(time.Second).C:
+				t.Fatalf("sink %d: transaction propagation timed out: have %d, want %d", i, arrived, len(public))
+			}
+		}
+	}
+	time.Sleep(100 * time.Millisecond) // Give any leaked private transaction the chance to arrive
+	for i, sink := range sinks {
+		for _, tx := range private {
+			if sink.txpool.Has(tx.Hash()) {
+				t.Errorf("sink %d: private transaction %x propagated", i, tx.Hash())
+			}
+		}
+	}
+	// Ensure private transactions are not served even if requested directly
+	if tx := (*ethHandler)(source.handler).TxPool().Get(private[0].Hash()); tx != nil {
+		t.Errorf("private transaction served to peers")
+	}
+	if tx := (*ethHandler)(source.handler).TxPool().Get(public[0].Hash()); tx == nil {
+		t.Errorf("public transaction not served to peers")
+	}
+}
+
 // Tests that post eth protocol handshake, clients perform a mutual checkpoint
 // challenge to validate each other's chains. Hash mismatches, or missing ones
 // during a fast sync should lead to the peer getting dropped.
@@ -46,7 +46,8 @@ var (
 // Its goal is to get around setting up a valid statedb for the balance and nonce
 // checks.
 type testTxPool struct {
-	pool map[common.Hash]*types.Transaction // Hash map of collected transactions
+	pool    map[common.Hash]*types.Transaction // Hash map of collected transactions
+	private map[common.Hash]struct{}           // Set of privately submitted transactions
 
 	txFeed event.Feed   // Notification feed to allow waiting for inclusion
 	lock   sync.RWMutex // Protects the transaction pool
@@ -55,7 +56,8 @@ type testTxPool struct {
 // newTestTxPool creates a mock transaction pool.
 func newTestTxPool() *testTxPool {
 	return &testTxPool{
-		pool: make(map[common.Hash]*types.Transaction),
+		pool:    make(map[common.Hash]*types.Transaction),
+		private: make(map[common.Hash]struct{}),
 	}
 }
 
@@ -90,6 +92,33 @@ func (p *testTxPool) AddRemotes(txs []*types.Transaction) []error {
 	return make([]error, len(txs))
 }
 
+// AddRemotesFrom appends a batch of transactions received from a remote peer to
+// the pool, and notifies any listeners if the addition channel is non nil
+func (p *testTxPool) AddRemotesFrom(peer string, txs []*types.Transaction) []error {
+	return p.AddRemotes(txs)
+}
+
+// AddPrivates appends a batch of private transactions to the pool, and notifies
+// any listeners if the addition channel is non nil
+func (p *testTxPool) AddPrivates(txs []*types.Transaction) {
+	p.lock.Lock()
+	for _, tx := range txs {
+		p.private[tx.Hash()] = struct{}{}
+	}
+	p.lock.Unlock()
+
+	p.AddRemotes(txs)
+}
+
+// Private returns whether a transaction was submitted privately.
+func (p *testTxPool) Private(hash common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	_, ok := p.private[hash]
+	return ok
+}
+
 // Pending returns all the transactions known to the pool
 func (p *testTxPool) Pending(enforceTips bool) (map[common.Address]types.Transactions, error) {
 	p.lock.RLock()
@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
@@ -64,6 +65,7 @@ type Backend interface {
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
 	GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error)
 	RPCGasCap() uint64
+	TraceFilterCap() uint64
 	ChainConfig() *params.ChainConfig
 	Engine() consensus.Engine
 	ChainDb() ethdb.Database
@@ -753,6 +755,9 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 		if err := config.StateOverrides.Apply(statedb); err != nil {
 			return nil, err
 		}
+		// Flush the overrides out of the state journal, making them the base
+		// state of the call instead of part of its modifications
+		statedb.Finalise(false)
 	}
 	// Execute the trace
 	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
@@ -792,8 +797,11 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = New(*config.Tracer, txctx); err != nil {
+		// Construct the tracer to execute with, preferring the native Go version
+		// of the requested tracer if there is one over the JavaScript code
+		if t, ok := native.New(*config.Tracer); ok {
+			tracer = t
+		} else if tracer, err = New(*config.Tracer, txctx); err != nil {
 			return nil, err
 		}
 		// Handle timeouts and RPC cancellations
@@ -801,7 +809,7 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 		go func() {
 			<-deadlineCtx.Done()
 			if deadlineCtx.Err() == context.DeadlineExceeded {
-				tracer.(*Tracer).Stop(errors.New("execution timeout"))
+				tracer.(native.Tracer).Stop(errors.New("execution timeout"))
 			}
 		}()
 		defer cancel()
@@ -838,7 +846,7 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *Tracer:
+	case native.Tracer:
 		return tracer.GetResult()
 
 	default:
@@ -855,6 +863,11 @@ func APIs(backend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewAPI(backend),
 			Public:    false,
+		}, {
+			Namespace: "trace",
+			Version:   "1.0",
+			Service:   NewTraceAPI(backend),
+			Public:    false,
 		},
 	}
 }
@@ -40,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -56,6 +57,7 @@ type testBackend struct {
 	engine      consensus.Engine
 	chaindb     ethdb.Database
 	chain       *core.BlockChain
+	filterCap   uint64
 }
 
 func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
@@ -126,6 +128,10 @@ func (b *testBackend) RPCGasCap() uint64 {
 	return 25000000
 }
 
+func (b *testBackend) TraceFilterCap() uint64 {
+	return b.filterCap
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chainConfig
 }
@@ -138,6 +144,10 @@ func (b *testBackend) ChainDb() ethdb.Database {
 	return b.chaindb
 }
 
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
 func (b *testBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool) (*state.StateDB, error) {
 	statedb, err := b.chain.StateAt(block.Root())
 	if err != nil {
@@ -591,6 +601,86 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+func TestStateDiffTracer(t *testing.T) {
+	t.Parallel()
+
+	type diffAccount struct {
+		Balance *hexutil.Big                `json:"balance"`
+		Nonce   *uint64                     `json:"nonce"`
+		Code    hexutil.Bytes               `json:"code"`
+		Storage map[common.Hash]common.Hash `json:"storage"`
+	}
+	type stateDiff struct {
+		Pre  map[common.Address]*diffAccount `json:"pre"`
+		Post map[common.Address]*diffAccount `json:"post"`
+	}
+	decode := func(res interface{}) *stateDiff {
+		diff := new(stateDiff)
+		if err := json.Unmarshal(res.(json.RawMessage), diff); err != nil {
+			t.Fatalf("failed to decode state diff: %v", err)
+		}
+		return diff
+	}
+	backend, accounts := newParityTestBackend(t, 1)
+	api := NewAPI(backend)
+	tracer := "stateDiffTracer"
+
+	// Trace the contract call, storing into the caller and creating the callee
+	block := backend.chain.GetBlockByNumber(1)
+	res, err := api.TraceTransaction(context.Background(), block.Transactions()[1].Hash(), &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	diff := decode(res)
+
+	pre, post := diff.Pre[parityCaller], diff.Post[parityCaller]
+	if pre == nil || pre.Balance.ToInt().Cmp(big.NewInt(params.Ether)) != 0 || !bytes.Equal(pre.Code, parityCallerCode) || pre.Storage[common.Hash{}] != (common.Hash{}) {
+		t.Errorf("caller pre state mismatch: %+v", pre)
+	}
+	if post == nil || post.Balance.ToInt().Cmp(big.NewInt(params.Ether-1)) != 0 || post.Nonce != nil || post.Code != nil || post.Storage[common.Hash{}] != common.BigToHash(common.Big1) {
+		t.Errorf("caller post state mismatch: %+v", post)
+	}
+	if pre := diff.Pre[parityCallee]; pre != nil {
+		t.Errorf("created callee in pre state: %+v", pre)
+	}
+	if post := diff.Post[parityCallee]; post == nil || post.Balance.ToInt().Int64() != 1 {
+		t.Errorf("callee post state mismatch: %+v", post)
+	}
+	if pre, post := diff.Pre[accounts[0].addr], diff.Post[accounts[0].addr]; pre == nil || post == nil || *pre.Nonce != 1 || *post.Nonce != 2 {
+		t.Errorf("sender state mismatch: pre %+v, post %+v", pre, post)
+	}
+	// Tracing the entire block should yield the same result for the transaction
+	results, err := api.TraceBlockByNumber(context.Background(), rpc.BlockNumber(1), &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), 2)
+	}
+	if have, want := results[1].Result, res; !bytes.Equal(have.(json.RawMessage), want.(json.RawMessage)) {
+		t.Errorf("block trace mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Overridden state should be the base of the traced call
+	genesis := backend.chain.GetBlockByNumber(0)
+	call := ethapi.TransactionArgs{From: &accounts[0].addr, To: &parityCaller, GasPrice: (*hexutil.Big)(genesis.BaseFee())}
+	res, err = api.TraceCall(context.Background(), call, rpc.BlockNumberOrHash{BlockNumber: new(rpc.BlockNumber)}, &TraceCallConfig{
+		Tracer: &tracer,
+		StateOverrides: &ethapi.StateOverride{
+			parityCaller: ethapi.OverrideAccount{StateDiff: newStates([]common.Hash{{}}, []common.Hash{{0x05}})},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	diff = decode(res)
+	if pre := diff.Pre[parityCaller]; pre == nil || pre.Storage[common.Hash{}] != (common.Hash{0x05}) {
+		t.Errorf("overridden pre state mismatch: %+v", pre)
+	}
+	if post := diff.Post[parityCaller]; post == nil || post.Storage[common.Hash{}] != common.BigToHash(common.Big1) {
+		t.Errorf("overridden post state mismatch: %+v", post)
+	}
+}
+
 type Account struct {
 	key  *ecdsa.PrivateKey
 	addr common.Address
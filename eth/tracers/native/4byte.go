@@ -0,0 +1,127 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func init() {
+	register("4byteTracer", newFourByteTracer)
+}
+
+// fourByteTracer is a native port of the JavaScript 4byteTracer, which searches
+// for 4byte-identifiers, and collects them for post-processing. It collects the
+// methods identifiers along with the size of the supplied data, so a reversed
+// signature can be matched against the size of the data.
+//
+// Example:
+//
+//	> debug.traceTransaction( "0x214e597e35da083692f5386141e69f47e973b2c56e7a8073b1ea08fd7571e9de", {tracer: "4byteTracer"})
+//	{
+//	  0x27dc297e-128: 1,
+//	  0x38cc4831-0: 2,
+//	  0x524f3889-96: 1,
+//	  0xadf59f99-288: 1,
+//	  0xc281d19e-0: 1
+//	}
+type fourByteTracer struct {
+	interrupter
+
+	ids         map[string]int   // Aggregated 4byte ids with their occurrence counts
+	order       []string         // Aggregated 4byte ids in order of first occurrence
+	input       []byte           // Input data of the outer call
+	precompiles []common.Address // Precompiles active at the traced block
+}
+
+func newFourByteTracer() Tracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+// store saves the given identifier and datasize.
+func (t *fourByteTracer) store(id []byte, size uint64) {
+	key := hexutil.Encode(id) + "-" + strconv.FormatUint(size, 10)
+	if _, ok := t.ids[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.ids[key]++
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.input = common.CopyBytes(input)
+	t.precompiles = vm.ActivePrecompiles(env.ChainConfig().Rules(env.Context.BlockNumber))
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	// Skip any opcodes that are not internal calls, retrieving the stack index
+	// of the first param after 'value', i.e. meminstart
+	var ct int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		// gas, addr, val, memin, meminsz, memout, memoutsz
+		ct = 3
+	case vm.DELEGATECALL, vm.STATICCALL:
+		// gas, addr, memin, meminsz, memout, memoutsz
+		ct = 2
+	default:
+		return
+	}
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	stack := scope.Stack
+	if isPrecompiled(t.precompiles, common.Address(peek(stack, 1).Bytes20())) {
+		return
+	}
+	// Gather internal call details
+	if inSz := peek(stack, ct+1).Uint64(); inSz >= 4 {
+		inOff := peek(stack, ct).Uint64()
+		t.store(memorySlice(scope.Memory, inOff, inOff+4), inSz-4)
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+}
+
+// GetResult returns the collected 4byte ids with their occurrence counts.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	// Save the outer calldata also
+	if len(t.input) >= 4 {
+		t.store(t.input[:4], uint64(len(t.input)-4))
+	}
+	return marshalObject(t.order, func(i int) interface{} {
+		return t.ids[t.order[i]]
+	})
+}
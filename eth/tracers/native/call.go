@@ -0,0 +1,291 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func init() {
+	register("callTracer", newCallTracer)
+}
+
+// callFrame is a single call reported by the call tracer. The field order is
+// the one of the JavaScript tracer's output.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   *hexutil.Bytes  `json:"input,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Time    string          `json:"time,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn   uint64 // Gas available at the call site
+	gasCost uint64 // Gas charged by the call opcode
+	outOff  uint64 // Memory offset receiving the call output
+	outLen  uint64 // Memory size receiving the call output
+}
+
+// callTracer is a native port of the JavaScript callTracer, which extracts and
+// reports all the internal calls made by a transaction.
+type callTracer struct {
+	interrupter
+
+	callstack   []*callFrame     // Current recursive call stack of the EVM execution
+	descended   bool             // Whether we've just descended into an inner call
+	precompiles []common.Address // Precompiles active at the traced block
+
+	// Transaction context gathered throughout execution
+	typ     string
+	from    common.Address
+	to      common.Address
+	input   []byte
+	gas     uint64
+	value   *big.Int
+	output  []byte
+	gasUsed uint64
+	time    string
+	err     string
+}
+
+func newCallTracer() Tracer {
+	return &callTracer{callstack: []*callFrame{{}}}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.typ = "CALL"
+	if create {
+		t.typ = "CREATE"
+	}
+	t.from, t.to = from, to
+	t.input = common.CopyBytes(input)
+	t.gas = gas
+	t.value = new(big.Int).Set(value)
+	t.precompiles = vm.ActivePrecompiles(env.ChainConfig().Rules(env.Context.BlockNumber))
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	// Capture any errors immediately
+	if err != nil {
+		t.fault(err)
+		return
+	}
+	stack := scope.Stack
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		// If a new contract is being created, add to the call stack
+		inOff := peek(stack, 1).Uint64()
+		input := hexutil.Bytes(memorySlice(scope.Memory, inOff, inOff+peek(stack, 2).Uint64()))
+		t.callstack = append(t.callstack, &callFrame{
+			Type:    op.String(),
+			From:    scope.Contract.Address(),
+			Input:   &input,
+			Value:   (*hexutil.Big)(peek(stack, 0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return
+
+	case vm.SELFDESTRUCT:
+		// If a contract is being self destructed, gather that as a subcall too
+		to := common.Address(peek(stack, 0).Bytes20())
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, &callFrame{
+			Type:  op.String(),
+			From:  scope.Contract.Address(),
+			To:    &to,
+			Value: (*hexutil.Big)(env.StateDB.GetBalance(scope.Contract.Address())),
+		})
+		return
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		// If a new method invocation is being done, add to the call stack. Skip
+		// any pre-compile invocations, those are just fancy opcodes.
+		to := common.Address(peek(stack, 1).Bytes20())
+		if isPrecompiled(t.precompiles, to) {
+			return
+		}
+		off := 1
+		if op == vm.DELEGATECALL || op == vm.STATICCALL {
+			off = 0
+		}
+		inOff := peek(stack, 2+off).Uint64()
+		input := hexutil.Bytes(memorySlice(scope.Memory, inOff, inOff+peek(stack, 3+off).Uint64()))
+		call := &callFrame{
+			Type:    op.String(),
+			From:    scope.Contract.Address(),
+			To:      &to,
+			Input:   &input,
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  peek(stack, 4+off).Uint64(),
+			outLen:  peek(stack, 5+off).Uint64(),
+		}
+		if op == vm.CALL || op == vm.CALLCODE {
+			call.Value = (*hexutil.Big)(peek(stack, 2).ToBig())
+		}
+		t.callstack = append(t.callstack, call)
+		t.descended = true
+		return
+	}
+	// If we've just descended into an inner call, retrieve it's true allowance. We
+	// need to extract if from within the call as there may be funky gas dynamics
+	// with regard to requested and actually given gas (2300 stipend, 63/64 rule).
+	// Calls made to plain accounts have no steps, their gas is not reported.
+	if t.descended {
+		if depth >= len(t.callstack) {
+			allowance := hexutil.Uint64(gas)
+			t.callstack[len(t.callstack)-1].Gas = &allowance
+		}
+		t.descended = false
+	}
+	// If an existing call is returning, pop off the call stack
+	if op == vm.REVERT {
+		t.callstack[len(t.callstack)-1].Error = "execution reverted"
+		return
+	}
+	if depth != len(t.callstack)-1 {
+		return
+	}
+	// Pop off the last call and get the execution results
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	ret := peek(stack, 0)
+	if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+		// If the call was a CREATE, retrieve the contract address and output code
+		gasUsed := hexutil.Uint64(call.gasIn - call.gasCost - gas)
+		call.GasUsed = &gasUsed
+
+		if !ret.IsZero() {
+			to := common.Address(ret.Bytes20())
+			code := hexutil.Bytes(env.StateDB.GetCode(to))
+			call.To, call.Output = &to, &code
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	} else {
+		// If the call was a contract call, retrieve the gas usage and output
+		if call.Gas != nil {
+			gasUsed := hexutil.Uint64(call.gasIn - call.gasCost + uint64(*call.Gas) - gas)
+			call.GasUsed = &gasUsed
+		}
+		if !ret.IsZero() {
+			output := hexutil.Bytes(memorySlice(scope.Memory, call.outOff, call.outOff+call.outLen))
+			call.Output = &output
+		} else if call.Error == "" {
+			call.Error = "internal failure"
+		}
+	}
+	// Inject the call into the previous one
+	parent := t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, call)
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	t.fault(err)
+}
+
+// fault flattens the call failing with the given error into its parent.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	// Pop off the just failed call, consuming all available gas
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	call.Error = err.Error()
+	if call.Gas != nil {
+		gasUsed := *call.Gas
+		call.GasUsed = &gasUsed
+	}
+	// Flatten the failed call into its parent, or leave it in the stack if it
+	// was the last call
+	if len(t.callstack) > 0 {
+		parent := t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	t.callstack = append(t.callstack, call)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	t.output = common.CopyBytes(output)
+	t.gasUsed = gasUsed
+	t.time = d.String()
+	if err != nil {
+		t.err = err.Error()
+	}
+}
+
+// GetResult returns the top level call with all the internal calls nested in it.
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	var (
+		gas     = hexutil.Uint64(t.gas)
+		gasUsed = hexutil.Uint64(t.gasUsed)
+		input   = hexutil.Bytes(t.input)
+		output  = hexutil.Bytes(t.output)
+	)
+	result := &callFrame{
+		Type:    t.typ,
+		From:    t.from,
+		To:      &t.to,
+		Value:   (*hexutil.Big)(t.value),
+		Gas:     &gas,
+		GasUsed: &gasUsed,
+		Input:   &input,
+		Output:  &output,
+		Time:    t.time,
+		Calls:   t.callstack[0].Calls,
+	}
+	if t.callstack[0].Error != "" {
+		result.Error = t.callstack[0].Error
+	} else if t.err != "" {
+		result.Error = t.err
+	}
+	if result.Error != "" && (result.Error != "execution reverted" || len(output) == 0) {
+		result.Output = nil
+	}
+	return marshal(result)
+}
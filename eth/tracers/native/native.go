@@ -0,0 +1,142 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package native is a collection of transaction tracers written in Go. Most of
+// them are drop-in replacements of the JavaScript tracers with the same name,
+// producing the exact same output, but without the overhead of the JavaScript VM.
+// The rest rely on node internals unavailable to the JavaScript tracers.
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// Tracer is a native transaction tracer. It collects data while the EVM executes
+// a transaction and returns it as JSON once done.
+type Tracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or the reason of
+	// the interruption if the tracer was stopped.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates the tracing, discarding the rest of the execution.
+	Stop(err error)
+}
+
+// ctors contains the constructors of all the native tracers by name.
+var ctors = make(map[string]func() Tracer)
+
+// register makes a native tracer available by name.
+func register(name string, ctor func() Tracer) {
+	ctors[name] = ctor
+}
+
+// New creates a native tracer by name, returning false if there's none with the
+// given name.
+func New(name string) (Tracer, bool) {
+	ctor, ok := ctors[name]
+	if !ok {
+		return nil, false
+	}
+	return ctor(), true
+}
+
+// interrupter implements the interruption of the native tracers.
+type interrupter struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (i *interrupter) Stop(err error) {
+	i.reason = err
+	atomic.StoreUint32(&i.interrupt, 1)
+}
+
+// interrupted reports whether the tracer was stopped.
+func (i *interrupter) interrupted() bool {
+	return atomic.LoadUint32(&i.interrupt) > 0
+}
+
+// peek returns the n-th item from the top of the stack, or zero if the stack is
+// not deep enough, the same as the JavaScript stack accessor does.
+func peek(stack *vm.Stack, n int) *uint256.Int {
+	if len(stack.Data()) <= n || n < 0 {
+		return new(uint256.Int)
+	}
+	return stack.Back(n)
+}
+
+// isPrecompiled reports whether the address is one of the given precompiles.
+func isPrecompiled(precompiles []common.Address, addr common.Address) bool {
+	for _, p := range precompiles {
+		if p == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// memorySlice returns a copy of the memory between the given offsets, or an
+// empty slice if they are out of bounds, the same as the JavaScript memory
+// accessor does.
+func memorySlice(memory *vm.Memory, begin, end uint64) []byte {
+	if end <= begin || uint64(memory.Len()) < end {
+		return []byte{}
+	}
+	return memory.GetCopy(int64(begin), int64(end-begin))
+}
+
+// marshal encodes the given value into JSON like the JavaScript tracers do,
+// without escaping HTML characters.
+func marshal(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// marshalObject encodes a JSON object with the given keys in order, unlike Go
+// maps whose keys are sorted. This matches the insertion ordered objects of the
+// JavaScript tracers.
+func marshalObject(keys []string, value func(i int) interface{}) (json.RawMessage, error) {
+	buf := []byte{'{'}
+	for i, key := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		k, err := marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshal(value(i))
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, k...), ':'), v...)
+	}
+	return append(buf, '}'), nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func init() {
+	register("prestateTracer", newPrestateTracer)
+}
+
+// prestateAccount is the state of an account before the traced transaction.
+type prestateAccount struct {
+	Balance *hexutil.Big    `json:"balance"`
+	Nonce   uint64          `json:"nonce"`
+	Code    hexutil.Bytes   `json:"code"`
+	Storage json.RawMessage `json:"storage"`
+
+	keys  []common.Hash               // Accessed storage slots in access order
+	slots map[common.Hash]common.Hash // Original values of the accessed slots
+}
+
+// prestateTracer is a native port of the JavaScript prestateTracer, which outputs
+// sufficient information to create a local execution of the transaction from a
+// custom assembled genesis block.
+type prestateTracer struct {
+	interrupter
+
+	db       vm.StateDB
+	accounts map[common.Address]*prestateAccount // Accounts accessed during execution
+	order    []common.Address                    // Accessed accounts in access order
+
+	// Transaction context gathered throughout execution
+	create       bool
+	from         common.Address
+	to           common.Address
+	value        *big.Int
+	gasPrice     *big.Int
+	gasUsed      uint64
+	intrinsicGas uint64
+}
+
+func newPrestateTracer() Tracer {
+	return &prestateTracer{accounts: make(map[common.Address]*prestateAccount)}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.db = env.StateDB
+	t.create = create
+	t.from, t.to = from, to
+	t.value = new(big.Int).Set(value)
+	t.gasPrice = env.TxContext.GasPrice
+
+	// Compute intrinsic gas the same way the JavaScript tracer does
+	var (
+		isHomestead = env.ChainConfig().IsHomestead(env.Context.BlockNumber)
+		isIstanbul  = env.ChainConfig().IsIstanbul(env.Context.BlockNumber)
+	)
+	t.intrinsicGas, _ = core.IntrinsicGas(input, nil, create, isHomestead, isIstanbul)
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	// Add the current account if we just started tracing. Balance will potentially
+	// be wrong here, since this will include the value sent along with the message.
+	// We fix that in GetResult.
+	if len(t.order) == 0 {
+		t.lookupAccount(scope.Contract.Address())
+	}
+	// Whenever new state is accessed, add it to the prestate
+	stack := scope.Stack
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.Address(peek(stack, 0).Bytes20()))
+
+	case vm.CREATE:
+		from := scope.Contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, t.db.GetNonce(from)))
+
+	case vm.CREATE2:
+		// stack: salt, size, offset, endowment
+		offset := peek(stack, 1).Uint64()
+		code := memorySlice(scope.Memory, offset, offset+peek(stack, 2).Uint64())
+		salt := common.Hash(peek(stack, 3).Bytes32())
+		t.lookupAccount(crypto.CreateAddress2(scope.Contract.Address(), salt, crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.Address(peek(stack, 1).Bytes20()))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(scope.Contract.Address(), common.Hash(peek(stack, 0).Bytes32()))
+	}
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.accounts[addr]; ok {
+		return
+	}
+	t.accounts[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(t.db.GetBalance(addr)),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    t.db.GetCode(addr),
+		slots:   make(map[common.Hash]common.Hash),
+	}
+	t.order = append(t.order, addr)
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	account := t.accounts[addr]
+	if _, ok := account.slots[key]; ok {
+		return
+	}
+	account.slots[key] = t.db.GetState(addr, key)
+	account.keys = append(account.keys, key)
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	t.gasUsed = gasUsed
+}
+
+// GetResult returns the assembled allocations (prestate).
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin
+	t.lookupAccount(t.to)
+	t.lookupAccount(t.from)
+
+	var (
+		from    = t.accounts[t.from]
+		to      = t.accounts[t.to]
+		fromBal = new(big.Int).Set(from.Balance.ToInt())
+		toBal   = new(big.Int).Set(to.Balance.ToInt())
+	)
+	to.Balance = (*hexutil.Big)(toBal.Sub(toBal, t.value))
+	from.Balance = (*hexutil.Big)(fromBal.Add(fromBal.Add(fromBal, t.value), t.fees()))
+
+	// Decrement the caller's nonce, and remove empty create targets. We can blindly
+	// delete the contract prestate, as any existing state would have caused the
+	// transaction to be rejected as invalid in the first place.
+	from.Nonce--
+
+	var order []common.Address
+	for _, addr := range t.order {
+		if t.create && addr == t.to {
+			continue
+		}
+		order = append(order, addr)
+	}
+	for _, addr := range order {
+		account := t.accounts[addr]
+		storage, err := marshalObject(hashKeys(account.keys), func(i int) interface{} {
+			return account.slots[account.keys[i]]
+		})
+		if err != nil {
+			return nil, err
+		}
+		account.Storage = storage
+	}
+	keys := make([]string, len(order))
+	for i, addr := range order {
+		keys[i] = hexutil.Encode(addr[:])
+	}
+	return marshalObject(keys, func(i int) interface{} {
+		return t.accounts[order[i]]
+	})
+}
+
+// fees returns the gas fees paid by the sender. The JavaScript tracer computes
+// them with floating point numbers, which is replicated here to produce the
+// exact same balances.
+func (t *prestateTracer) fees() *big.Int {
+	price, _ := new(big.Float).SetInt(t.gasPrice).Float64()
+	fees := float64(t.gasUsed+t.intrinsicGas) * price
+
+	result, _ := new(big.Int).SetString(strconv.FormatFloat(fees, 'f', -1, 64), 10)
+	return result
+}
+
+// hashKeys converts storage slots into JSON object keys.
+func hashKeys(hashes []common.Hash) []string {
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = hash.Hex()
+	}
+	return keys
+}
@@ -0,0 +1,242 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func init() {
+	register("stateDiffTracer", newStateDiffTracer)
+}
+
+// diffAccount is the state of an account either before or after the traced
+// transaction. Unchanged fields are omitted from the post state.
+type diffAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *uint64                     `json:"nonce,omitempty"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// stateDiff is the result of the state diff tracer. The pre state contains all
+// the accounts touched by the transaction which existed before it, the post
+// state the changed fields of the accounts modified by it. Accounts deleted by
+// the transaction are missing from the post state, whereas the ones created by
+// it are missing from the pre state.
+type stateDiff struct {
+	Pre  map[common.Address]*diffAccount `json:"pre"`
+	Post map[common.Address]*diffAccount `json:"post"`
+}
+
+// stateDiffTracer collects the pre and post state of all the accounts touched
+// by a transaction. Accessed accounts and storage slots are gathered through the
+// tracing hooks, whereas their original values are reconstructed from the state
+// journal once the transaction is done.
+//
+// The tracer relies on the state journal containing only the modifications of
+// the traced transaction, i.e. the state needs to be finalised beforehand.
+type stateDiffTracer struct {
+	interrupter
+
+	env      *vm.EVM
+	accounts map[common.Address]map[common.Hash]struct{} // Accessed accounts and storage slots
+}
+
+func newStateDiffTracer() Tracer {
+	return &stateDiffTracer{accounts: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *stateDiffTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+
+	t.touchAccount(from)
+	t.touchAccount(to)
+	t.touchAccount(env.Context.Coinbase)
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *stateDiffTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if t.interrupted() {
+		return
+	}
+	stack := scope.Stack
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.EXTCODEHASH, vm.BALANCE, vm.SELFDESTRUCT:
+		t.touchAccount(common.Address(peek(stack, 0).Bytes20()))
+
+	case vm.CREATE:
+		from := scope.Contract.Address()
+		t.touchAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+
+	case vm.CREATE2:
+		// stack: salt, size, offset, endowment
+		offset := peek(stack, 1).Uint64()
+		code := memorySlice(scope.Memory, offset, offset+peek(stack, 2).Uint64())
+		salt := common.Hash(peek(stack, 3).Bytes32())
+		t.touchAccount(crypto.CreateAddress2(scope.Contract.Address(), salt, crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.touchAccount(common.Address(peek(stack, 1).Bytes20()))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.touchStorage(scope.Contract.Address(), common.Hash(peek(stack, 0).Bytes32()))
+	}
+}
+
+// touchAccount marks the given account as accessed.
+func (t *stateDiffTracer) touchAccount(addr common.Address) {
+	if _, ok := t.accounts[addr]; !ok {
+		t.accounts[addr] = make(map[common.Hash]struct{})
+	}
+}
+
+// touchStorage marks the given storage slot of an account as accessed.
+func (t *stateDiffTracer) touchStorage(addr common.Address, key common.Hash) {
+	t.touchAccount(addr)
+	t.accounts[addr][key] = struct{}{}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (t *stateDiffTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *stateDiffTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+}
+
+// GetResult returns the pre and post state of the touched accounts. It needs to
+// be called after the transaction is applied, but before the state is finalised.
+func (t *stateDiffTracer) GetResult() (json.RawMessage, error) {
+	if t.interrupted() {
+		return nil, t.reason
+	}
+	if t.env == nil {
+		return nil, errors.New("no transaction traced")
+	}
+	db, ok := t.env.StateDB.(*state.StateDB)
+	if !ok {
+		return nil, errors.New("state journal unavailable")
+	}
+	var (
+		diff = &stateDiff{
+			Pre:  make(map[common.Address]*diffAccount),
+			Post: make(map[common.Address]*diffAccount),
+		}
+		eip158   = t.env.ChainConfig().IsEIP158(t.env.Context.BlockNumber)
+		modified = db.JournalAccounts()
+	)
+	// Modified accounts are touched even if the hooks did not catch them, e.g.
+	// the gas refund of the sender or the fees of the coinbase
+	for addr, orig := range modified {
+		t.touchAccount(addr)
+		for key := range orig.Storage {
+			t.accounts[addr][key] = struct{}{}
+		}
+	}
+	for addr, slots := range t.accounts {
+		orig := modified[addr]
+		if orig == nil {
+			orig = &state.JournalAccount{}
+		}
+		// Assemble the post state first, which is filled with the original
+		// values in place of the changed ones to get the pre state
+		post := &diffAccount{
+			Balance: (*hexutil.Big)(db.GetBalance(addr)),
+			Nonce:   new(uint64),
+			Code:    db.GetCode(addr),
+			Storage: make(map[common.Hash]common.Hash),
+		}
+		*post.Nonce = db.GetNonce(addr)
+		for key := range slots {
+			post.Storage[key] = db.GetState(addr, key)
+		}
+		// Add the pre state of the account if it existed before
+		if !orig.Created && (modified[addr] != nil || db.Exist(addr)) {
+			pre := &diffAccount{
+				Balance: post.Balance,
+				Nonce:   post.Nonce,
+				Code:    post.Code,
+				Storage: make(map[common.Hash]common.Hash),
+			}
+			if orig.Balance != nil {
+				pre.Balance = (*hexutil.Big)(orig.Balance)
+			}
+			if orig.Nonce != nil {
+				pre.Nonce = orig.Nonce
+			}
+			if orig.Code != nil {
+				pre.Code = *orig.Code
+			}
+			for key, val := range post.Storage {
+				if prev, ok := orig.Storage[key]; ok {
+					val = prev
+				}
+				pre.Storage[key] = val
+			}
+			diff.Pre[addr] = pre
+		}
+		// Add the changed fields of the account unless it's about to be deleted
+		if modified[addr] == nil || db.HasSuicided(addr) || (eip158 && db.Empty(addr)) {
+			continue
+		}
+		if pre := diff.Pre[addr]; pre != nil {
+			if pre.Balance.ToInt().Cmp(post.Balance.ToInt()) == 0 {
+				post.Balance = nil
+			}
+			if *pre.Nonce == *post.Nonce {
+				post.Nonce = nil
+			}
+			if bytes.Equal(pre.Code, post.Code) {
+				post.Code = nil
+			}
+			for key, val := range post.Storage {
+				if pre.Storage[key] == val {
+					delete(post.Storage, key)
+				}
+			}
+		} else {
+			// Fields of newly created accounts are only changed if they're set
+			if post.Balance.ToInt().Sign() == 0 {
+				post.Balance = nil
+			}
+			if *post.Nonce == 0 {
+				post.Nonce = nil
+			}
+			for key, val := range post.Storage {
+				if val == (common.Hash{}) {
+					delete(post.Storage, key)
+				}
+			}
+		}
+		if post.Balance != nil || post.Nonce != nil || post.Code != nil || len(post.Storage) > 0 {
+			diff.Post[addr] = post
+		}
+	}
+	return json.Marshal(diff)
+}
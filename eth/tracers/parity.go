@@ -0,0 +1,538 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// callFrame is a single call of the callTracer output, which the Parity style
+// traces are converted from.
+type callFrame struct {
+	Type    string         `json:"type"`
+	From    common.Address `json:"from"`
+	To      common.Address `json:"to"`
+	Value   *hexutil.Big   `json:"value"`
+	Gas     hexutil.Uint64 `json:"gas"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Input   hexutil.Bytes  `json:"input"`
+	Output  hexutil.Bytes  `json:"output"`
+	Error   string         `json:"error"`
+	Calls   []*callFrame   `json:"calls"`
+}
+
+// parityTrace is a single call, contract creation or self destruct within a
+// transaction, in the format of Parity's trace module.
+type parityTrace struct {
+	Action              interface{}  `json:"action"`
+	BlockHash           *common.Hash `json:"blockHash,omitempty"`
+	BlockNumber         *uint64      `json:"blockNumber,omitempty"`
+	Error               string       `json:"error,omitempty"`
+	Result              interface{}  `json:"result"`
+	Subtraces           int          `json:"subtraces"`
+	TraceAddress        []int        `json:"traceAddress"`
+	TransactionHash     *common.Hash `json:"transactionHash,omitempty"`
+	TransactionPosition *uint64      `json:"transactionPosition,omitempty"`
+	Type                string       `json:"type"`
+
+	from *common.Address // Account initiating the action, used for filtering
+	to   *common.Address // Account targeted by the action, used for filtering
+}
+
+// parityCallAction is the action of a message call trace.
+type parityCallAction struct {
+	CallType string         `json:"callType"`
+	From     common.Address `json:"from"`
+	Gas      hexutil.Uint64 `json:"gas"`
+	Input    hexutil.Bytes  `json:"input"`
+	To       common.Address `json:"to"`
+	Value    *hexutil.Big   `json:"value"`
+}
+
+// parityCallResult is the result of a successful message call trace.
+type parityCallResult struct {
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+	Output  hexutil.Bytes  `json:"output"`
+}
+
+// parityCreateAction is the action of a contract creation trace.
+type parityCreateAction struct {
+	From  common.Address `json:"from"`
+	Gas   hexutil.Uint64 `json:"gas"`
+	Init  hexutil.Bytes  `json:"init"`
+	Value *hexutil.Big   `json:"value"`
+}
+
+// parityCreateResult is the result of a successful contract creation trace.
+type parityCreateResult struct {
+	Address common.Address `json:"address"`
+	Code    hexutil.Bytes  `json:"code"`
+	GasUsed hexutil.Uint64 `json:"gasUsed"`
+}
+
+// paritySuicideAction is the action of a self destruct trace.
+type paritySuicideAction struct {
+	Address       common.Address `json:"address"`
+	Balance       *hexutil.Big   `json:"balance"`
+	RefundAddress common.Address `json:"refundAddress"`
+}
+
+// flattenCallFrame converts a call frame along with all its inner calls into
+// a list of Parity style traces, in depth first order.
+func flattenCallFrame(frame *callFrame, address []int, traces []*parityTrace) []*parityTrace {
+	trace := &parityTrace{
+		Subtraces:    len(frame.Calls),
+		TraceAddress: append([]int{}, address...),
+	}
+	value := frame.Value
+	if value == nil {
+		value = new(hexutil.Big)
+	}
+	from, to := frame.From, frame.To
+	switch frame.Type {
+	case "CREATE", "CREATE2":
+		trace.Type = "create"
+		trace.Action = &parityCreateAction{
+			From:  from,
+			Gas:   frame.Gas,
+			Init:  frame.Input,
+			Value: value,
+		}
+		trace.from = &from
+		if frame.Error == "" {
+			trace.Result = &parityCreateResult{
+				Address: to,
+				Code:    frame.Output,
+				GasUsed: frame.GasUsed,
+			}
+			trace.to = &to
+		}
+	case "SELFDESTRUCT":
+		trace.Type = "suicide"
+		trace.Action = &paritySuicideAction{
+			Address:       from,
+			Balance:       value,
+			RefundAddress: to,
+		}
+		trace.from, trace.to = &from, &to
+
+	default:
+		trace.Type = "call"
+		trace.Action = &parityCallAction{
+			CallType: strings.ToLower(frame.Type),
+			From:     from,
+			Gas:      frame.Gas,
+			Input:    frame.Input,
+			To:       to,
+			Value:    value,
+		}
+		trace.from, trace.to = &from, &to
+		if frame.Error == "" {
+			output := frame.Output
+			if output == nil {
+				output = hexutil.Bytes{}
+			}
+			trace.Result = &parityCallResult{
+				GasUsed: frame.GasUsed,
+				Output:  output,
+			}
+		}
+	}
+	if frame.Error != "" {
+		trace.Error = parityError(frame.Error)
+	}
+	traces = append(traces, trace)
+	for i, call := range frame.Calls {
+		traces = flattenCallFrame(call, append(address, i), traces)
+	}
+	return traces
+}
+
+// parityError converts an EVM error message into its Parity counterpart.
+func parityError(err string) string {
+	switch {
+	case err == vm.ErrExecutionReverted.Error():
+		return "Reverted"
+	case err == vm.ErrOutOfGas.Error(), err == vm.ErrCodeStoreOutOfGas.Error():
+		return "Out of gas"
+	case err == vm.ErrInvalidJump.Error():
+		return "Bad jump destination"
+	case strings.HasPrefix(err, "invalid opcode"):
+		return "Bad instruction"
+	case strings.HasPrefix(err, "stack underflow"):
+		return "Stack underflow"
+	case strings.HasPrefix(err, "stack limit reached"):
+		return "Out of stack"
+	}
+	return err
+}
+
+// vmTrace is the full trace of the code executed in a single call frame, in the
+// format of Parity's vmTrace.
+type vmTrace struct {
+	Code hexutil.Bytes  `json:"code"`
+	Ops  []*vmOperation `json:"ops"`
+}
+
+// vmOperation is a single executed instruction of a vmTrace.
+type vmOperation struct {
+	Cost uint64      `json:"cost"`
+	Ex   *vmExecuted `json:"ex"`
+	Pc   uint64      `json:"pc"`
+	Sub  *vmTrace    `json:"sub"`
+
+	op     vm.OpCode  // Opcode of the instruction
+	gas    uint64     // Gas available before the instruction
+	memOff uint64     // Offset of the memory written by the instruction
+	memLen uint64     // Size of the memory written by the instruction
+	store  *vmStorage // Storage slot written by the instruction
+}
+
+// vmExecuted is the effect of a successfully executed instruction.
+type vmExecuted struct {
+	Mem   *vmMemory      `json:"mem"`
+	Push  []*hexutil.Big `json:"push"`
+	Store *vmStorage     `json:"store"`
+	Used  uint64         `json:"used"`
+}
+
+// vmMemory is a memory region written by an instruction.
+type vmMemory struct {
+	Data hexutil.Bytes `json:"data"`
+	Off  uint64        `json:"off"`
+}
+
+// vmStorage is a storage slot written by an instruction.
+type vmStorage struct {
+	Key *hexutil.Big `json:"key"`
+	Val *hexutil.Big `json:"val"`
+}
+
+// vmFrame is a call frame being traced by the vmTracer.
+type vmFrame struct {
+	trace   *vmTrace
+	pending *vmOperation // Last instruction, whose effects are not yet known
+}
+
+// vmTracer is a vm.Tracer assembling a Parity style vmTrace. The effects of an
+// instruction are only observable when the next one at the same depth starts,
+// so each call frame keeps its last instruction pending until then.
+type vmTracer struct {
+	root   *vmTrace
+	frames []*vmFrame
+}
+
+func (t *vmTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	code := input
+	if !create {
+		code = env.StateDB.GetCode(to)
+	}
+	t.root = &vmTrace{Code: common.CopyBytes(code), Ops: []*vmOperation{}}
+	t.frames = []*vmFrame{{trace: t.root}}
+}
+
+func (t *vmTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Open a new frame if we've just descended into an inner call
+	for len(t.frames) < depth {
+		sub := &vmTrace{Code: common.CopyBytes(scope.Contract.Code), Ops: []*vmOperation{}}
+		if parent := t.frames[len(t.frames)-1]; parent.pending != nil {
+			parent.pending.Sub = sub
+		}
+		t.frames = append(t.frames, &vmFrame{trace: sub})
+	}
+	// Close any inner frames if we've just returned from them
+	for len(t.frames) > depth {
+		t.frames[len(t.frames)-1].finish()
+		t.frames = t.frames[:len(t.frames)-1]
+	}
+	frame := t.frames[len(t.frames)-1]
+	if frame.pending != nil {
+		frame.pending.complete(scope, gas)
+		frame.pending = nil
+	}
+	operation := &vmOperation{Cost: cost, Pc: pc, op: op, gas: gas}
+	frame.trace.Ops = append(frame.trace.Ops, operation)
+	if err != nil {
+		return
+	}
+	stack := scope.Stack
+	switch op {
+	case vm.MSTORE, vm.MLOAD:
+		operation.memOff, operation.memLen = stackPeek(stack, 0).Uint64(), 32
+	case vm.MSTORE8:
+		operation.memOff, operation.memLen = stackPeek(stack, 0).Uint64(), 1
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY:
+		operation.memOff, operation.memLen = stackPeek(stack, 0).Uint64(), stackPeek(stack, 2).Uint64()
+	case vm.EXTCODECOPY:
+		operation.memOff, operation.memLen = stackPeek(stack, 1).Uint64(), stackPeek(stack, 3).Uint64()
+	case vm.CALL, vm.CALLCODE:
+		operation.memOff, operation.memLen = stackPeek(stack, 5).Uint64(), stackPeek(stack, 6).Uint64()
+	case vm.DELEGATECALL, vm.STATICCALL:
+		operation.memOff, operation.memLen = stackPeek(stack, 4).Uint64(), stackPeek(stack, 5).Uint64()
+	case vm.SSTORE:
+		operation.store = &vmStorage{
+			Key: (*hexutil.Big)(stackPeek(stack, 0).ToBig()),
+			Val: (*hexutil.Big)(stackPeek(stack, 1).ToBig()),
+		}
+	}
+	frame.pending = operation
+}
+
+func (t *vmTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	// The failed instruction has no effects to report
+	if depth <= len(t.frames) {
+		t.frames[depth-1].pending = nil
+	}
+}
+
+func (t *vmTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	for i := len(t.frames) - 1; i >= 0; i-- {
+		t.frames[i].finish()
+	}
+	t.frames = nil
+}
+
+// finish completes the last instruction of a frame which just terminated. Any
+// such instruction halts the execution, so it has no effects besides its cost.
+func (f *vmFrame) finish() {
+	if f.pending != nil {
+		f.pending.Ex = &vmExecuted{Push: []*hexutil.Big{}, Used: f.pending.gas - f.pending.Cost}
+		f.pending = nil
+	}
+}
+
+// complete fills in the effects of an instruction from the state of the frame
+// after its execution.
+func (op *vmOperation) complete(scope *vm.ScopeContext, gas uint64) {
+	op.Ex = &vmExecuted{Push: []*hexutil.Big{}, Store: op.store, Used: gas}
+
+	stack := scope.Stack.Data()
+	if pushes := stackPushes(op.op); pushes <= len(stack) {
+		for _, item := range stack[len(stack)-pushes:] {
+			op.Ex.Push = append(op.Ex.Push, (*hexutil.Big)(item.ToBig()))
+		}
+	}
+	if op.memLen > 0 && op.memOff+op.memLen <= uint64(scope.Memory.Len()) {
+		op.Ex.Mem = &vmMemory{
+			Data: scope.Memory.GetCopy(int64(op.memOff), int64(op.memLen)),
+			Off:  op.memOff,
+		}
+	}
+}
+
+// stackPushes returns the number of stack items reported as pushed by an opcode.
+// Like Parity, DUP and SWAP report all the items they touched.
+func stackPushes(op vm.OpCode) int {
+	switch {
+	case op >= vm.PUSH1 && op <= vm.PUSH32:
+		return 1
+	case op >= vm.DUP1 && op <= vm.DUP16:
+		return int(op-vm.DUP1) + 2
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	case op >= vm.LOG0 && op <= vm.LOG4:
+		return 0
+	}
+	switch op {
+	case vm.STOP, vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY, vm.POP,
+		vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.JUMP, vm.JUMPI, vm.JUMPDEST, vm.RETURN, vm.REVERT,
+		vm.SELFDESTRUCT:
+		return 0
+	}
+	return 1
+}
+
+// stackPeek returns the n-th item from the top of the stack, or zero if the
+// stack is not deep enough.
+func stackPeek(stack *vm.Stack, n int) *uint256.Int {
+	if len(stack.Data()) <= n {
+		return new(uint256.Int)
+	}
+	return stack.Back(n)
+}
+
+// accountDiff is the change of a single account caused by a transaction, in the
+// format of Parity's stateDiff. Each field is either "=" if unchanged, or an
+// object keyed by "+" (created), "-" (deleted) or "*" (modified).
+type accountDiff struct {
+	Balance interface{}                 `json:"balance"`
+	Code    interface{}                 `json:"code"`
+	Nonce   interface{}                 `json:"nonce"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// stateDiffTracer is a vm.Tracer collecting the accounts and storage slots that
+// may be modified by a transaction, to diff their values before and after it.
+type stateDiffTracer struct {
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+func newStateDiffTracer() *stateDiffTracer {
+	return &stateDiffTracer{accounts: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+// touch marks an account as potentially modified.
+func (t *stateDiffTracer) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := t.accounts[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.accounts[addr] = slots
+	}
+	return slots
+}
+
+func (t *stateDiffTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+	t.touch(env.Context.Coinbase)
+}
+
+func (t *stateDiffTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil {
+		return
+	}
+	stack := scope.Stack
+	switch op {
+	case vm.SSTORE:
+		t.touch(scope.Contract.Address())[common.Hash(stackPeek(stack, 0).Bytes32())] = struct{}{}
+
+	case vm.CALL, vm.CALLCODE:
+		t.touch(common.Address(stackPeek(stack, 1).Bytes20()))
+
+	case vm.CREATE:
+		from := scope.Contract.Address()
+		t.touch(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+
+	case vm.CREATE2:
+		offset, size := stackPeek(stack, 1).Uint64(), stackPeek(stack, 2).Uint64()
+		if offset+size <= uint64(scope.Memory.Len()) {
+			code := scope.Memory.GetPtr(int64(offset), int64(size))
+			salt := common.Hash(stackPeek(stack, 3).Bytes32())
+			t.touch(crypto.CreateAddress2(scope.Contract.Address(), salt, crypto.Keccak256(code)))
+		}
+	case vm.SELFDESTRUCT:
+		t.touch(scope.Contract.Address())
+		t.touch(common.Address(stackPeek(stack, 0).Bytes20()))
+	}
+}
+
+func (t *stateDiffTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *stateDiffTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+}
+
+// diff compares the touched accounts between the states before and after the
+// transaction, returning the ones that changed.
+func (t *stateDiffTracer) diff(pre, post *state.StateDB) map[common.Address]*accountDiff {
+	diffs := make(map[common.Address]*accountDiff)
+	for addr, slots := range t.accounts {
+		var (
+			existed = pre.Exist(addr)
+			exists  = post.Exist(addr)
+			diff    = &accountDiff{Storage: make(map[common.Hash]interface{})}
+		)
+		switch {
+		case !existed && !exists:
+			continue
+
+		case !existed:
+			diff.Balance = map[string]interface{}{"+": (*hexutil.Big)(post.GetBalance(addr))}
+			diff.Code = map[string]interface{}{"+": hexutil.Bytes(post.GetCode(addr))}
+			diff.Nonce = map[string]interface{}{"+": hexutil.Uint64(post.GetNonce(addr))}
+			for slot := range slots {
+				if value := post.GetState(addr, slot); value != (common.Hash{}) {
+					diff.Storage[slot] = map[string]interface{}{"+": value}
+				}
+			}
+		case !exists:
+			diff.Balance = map[string]interface{}{"-": (*hexutil.Big)(pre.GetBalance(addr))}
+			diff.Code = map[string]interface{}{"-": hexutil.Bytes(pre.GetCode(addr))}
+			diff.Nonce = map[string]interface{}{"-": hexutil.Uint64(pre.GetNonce(addr))}
+			for slot := range slots {
+				if value := pre.GetState(addr, slot); value != (common.Hash{}) {
+					diff.Storage[slot] = map[string]interface{}{"-": value}
+				}
+			}
+		default:
+			changed := false
+			compare := func(from, to interface{}, equal bool) interface{} {
+				if equal {
+					return "="
+				}
+				changed = true
+				return map[string]interface{}{"*": map[string]interface{}{"from": from, "to": to}}
+			}
+			preBalance, postBalance := pre.GetBalance(addr), post.GetBalance(addr)
+			diff.Balance = compare((*hexutil.Big)(preBalance), (*hexutil.Big)(postBalance), preBalance.Cmp(postBalance) == 0)
+
+			preCode, postCode := pre.GetCode(addr), post.GetCode(addr)
+			diff.Code = compare(hexutil.Bytes(preCode), hexutil.Bytes(postCode), pre.GetCodeHash(addr) == post.GetCodeHash(addr))
+
+			preNonce, postNonce := pre.GetNonce(addr), post.GetNonce(addr)
+			diff.Nonce = compare(hexutil.Uint64(preNonce), hexutil.Uint64(postNonce), preNonce == postNonce)
+
+			for slot := range slots {
+				if from, to := pre.GetState(addr, slot), post.GetState(addr, slot); from != to {
+					diff.Storage[slot] = compare(from, to, false)
+				}
+			}
+			if !changed {
+				continue
+			}
+		}
+		diffs[addr] = diff
+	}
+	return diffs
+}
+
+// multiTracer is a vm.Tracer dispatching all events to multiple tracers, so a
+// single execution can produce different traces.
+type multiTracer []vm.Tracer
+
+func (t multiTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t {
+		tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (t multiTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	for _, tracer := range t {
+		tracer.CaptureState(env, pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (t multiTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	for _, tracer := range t {
+		tracer.CaptureFault(env, pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+func (t multiTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) {
+	for _, tracer := range t {
+		tracer.CaptureEnd(output, gasUsed, d, err)
+	}
+}
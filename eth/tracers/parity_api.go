@@ -0,0 +1,475 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TraceAPI is the collection of Parity compatible tracing APIs exposed over the
+// trace namespace.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the Parity compatible tracing
+// methods of the Ethereum service.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// replayConfig selects the kinds of traces to produce when replaying transactions.
+type replayConfig struct {
+	trace     bool
+	vmTrace   bool
+	stateDiff bool
+}
+
+// replayResult is the outcome of replaying a single transaction.
+type replayResult struct {
+	Output          hexutil.Bytes                   `json:"output"`
+	StateDiff       map[common.Address]*accountDiff `json:"stateDiff"`
+	Trace           []*parityTrace                  `json:"trace"`
+	TransactionHash *common.Hash                    `json:"transactionHash,omitempty"`
+	VmTrace         *vmTrace                        `json:"vmTrace"`
+}
+
+// TraceFilterArgs are the criteria of a trace_filter query.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// Block returns the traces of all the transactions within the given block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*parityTrace, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Transaction returns the traces of the given transaction.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*parityTrace, error) {
+	tx, blockHash, blockNumber, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %#x not found", hash)
+	}
+	// It shouldn't happen in practice.
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	block, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	msg, vmctx, statedb, err := api.api.backend.StateAtTransaction(ctx, block, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	txctx := &Context{
+		BlockHash: blockHash,
+		TxIndex:   int(index),
+		TxHash:    hash,
+	}
+	res, err := api.replayTx(msg, txctx, vmctx, statedb, &replayConfig{trace: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, trace := range res.Trace {
+		annotateTrace(trace, blockHash, blockNumber, hash, index)
+	}
+	return res.Trace, nil
+}
+
+// ReplayBlockTransactions replays all the transactions within the given block,
+// returning the requested kinds of traces for each: "trace", "vmTrace" and/or
+// "stateDiff".
+func (api *TraceAPI) ReplayBlockTransactions(ctx context.Context, number rpc.BlockNumber, traceTypes []string) ([]*replayResult, error) {
+	config := new(replayConfig)
+	for _, typ := range traceTypes {
+		switch typ {
+		case "trace":
+			config.trace = true
+		case "vmTrace":
+			config.vmTrace = true
+		case "stateDiff":
+			config.stateDiff = true
+		default:
+			return nil, fmt.Errorf("unknown trace type %q", typ)
+		}
+	}
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.replayBlock(ctx, block, config)
+}
+
+// Filter returns the traces of the given block range matching the given from
+// and to addresses. If the trace index is enabled, only the blocks touching the
+// requested addresses are re-executed within the indexed range.
+//
+// The number of blocks not covered by the index, which need to be re-executed
+// regardless of the addresses, is capped by the backend's TraceFilterCap.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*parityTrace, error) {
+	from, err := api.resolveBlockNumber(ctx, args.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.resolveBlockNumber(ctx, args.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	if from == 0 {
+		from = 1 // genesis is not traceable
+	}
+	var (
+		fromAddresses = make(map[common.Address]bool)
+		toAddresses   = make(map[common.Address]bool)
+		skip          uint64
+		traces        = []*parityTrace{}
+	)
+	for _, addr := range args.FromAddress {
+		fromAddresses[addr] = true
+	}
+	for _, addr := range args.ToAddress {
+		toAddresses[addr] = true
+	}
+	if args.After != nil {
+		skip = *args.After
+	}
+	// full reports whether enough traces were gathered to stop the search
+	full := func() bool {
+		return args.Count != nil && uint64(len(traces)) >= *args.Count
+	}
+	// visit traces a single block and gathers the matching traces
+	visit := func(number uint64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		block, err := api.api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return err
+		}
+		blockTraces, err := api.blockTraces(ctx, block)
+		if err != nil {
+			return err
+		}
+		for _, trace := range blockTraces {
+			if len(fromAddresses) > 0 && (trace.from == nil || !fromAddresses[*trace.from]) {
+				continue
+			}
+			if len(toAddresses) > 0 && (trace.to == nil || !toAddresses[*trace.to]) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if full() {
+				break
+			}
+			traces = append(traces, trace)
+		}
+		return nil
+	}
+	// If the range is covered by the trace index, only visit the blocks touching
+	// the requested addresses there, re-executing the rest of the range
+	start, end := to+1, to
+	if len(fromAddresses)+len(toAddresses) > 0 {
+		if tail, head, ok := readTraceIndexRange(api.api.backend.ChainDb()); ok && tail <= to && head >= from {
+			start, end = tail, head
+			if start < from {
+				start = from
+			}
+			if end > to {
+				end = to
+			}
+		}
+	}
+	var (
+		indexed   []uint64
+		unindexed = (start - from) + (to - end)
+	)
+	if start <= end {
+		var gaps int
+		indexed, gaps = api.indexedBlocks(fromAddresses, toAddresses, start, end)
+		unindexed += uint64(gaps)
+	}
+	if limit := api.api.backend.TraceFilterCap(); limit > 0 && unindexed > limit {
+		return nil, fmt.Errorf("query requires re-executing %d unindexed blocks, exceeding the cap of %d", unindexed, limit)
+	}
+	for number := from; number < start; number++ {
+		if err := visit(number); err != nil {
+			return nil, err
+		}
+		if full() {
+			return traces, nil
+		}
+	}
+	for _, number := range indexed {
+		if err := visit(number); err != nil {
+			return nil, err
+		}
+		if full() {
+			return traces, nil
+		}
+	}
+	for number := end + 1; number <= to; number++ {
+		if err := visit(number); err != nil {
+			return nil, err
+		}
+		if full() {
+			return traces, nil
+		}
+	}
+	return traces, nil
+}
+
+// resolveBlockNumber converts an optional RPC block number into a concrete one,
+// defaulting to the latest block.
+func (api *TraceAPI) resolveBlockNumber(ctx context.Context, number *rpc.BlockNumber) (uint64, error) {
+	if number != nil && *number >= 0 {
+		return uint64(*number), nil
+	}
+	n := rpc.LatestBlockNumber
+	if number != nil {
+		n = *number
+	}
+	header, err := api.api.backend.HeaderByNumber(ctx, n)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block #%d not found", n)
+	}
+	return header.Number.Uint64(), nil
+}
+
+// indexedBlocks returns the numbers of the canonical blocks within the given
+// range which the trace index reports as touching any of the addresses, along
+// with the ones it has gaps for. The number of the latter is returned too.
+func (api *TraceAPI) indexedBlocks(fromAddresses, toAddresses map[common.Address]bool, from, to uint64) ([]uint64, int) {
+	var (
+		db      = api.api.backend.ChainDb()
+		numbers = make(map[uint64]struct{})
+		gaps    int
+	)
+	lookup := func(addresses map[common.Address]bool) {
+		for addr := range addresses {
+			for _, entry := range rawdb.ReadTraceIndexEntries(db, addr, from, to) {
+				if rawdb.ReadCanonicalHash(db, entry.BlockNumber) == entry.BlockHash {
+					numbers[entry.BlockNumber] = struct{}{}
+				}
+			}
+		}
+	}
+	lookup(fromAddresses)
+	lookup(toAddresses)
+
+	for number, hash := range rawdb.ReadTraceIndexGaps(db, from, to) {
+		if rawdb.ReadCanonicalHash(db, number) == hash {
+			numbers[number] = struct{}{}
+			gaps++
+		}
+	}
+
+	sorted := make([]uint64, 0, len(numbers))
+	for number := range numbers {
+		sorted = append(sorted, number)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted, gaps
+}
+
+// blockTraces returns the traces of all the transactions within a block.
+func (api *TraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]*parityTrace, error) {
+	results, err := api.replayBlock(ctx, block, &replayConfig{trace: true})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		hash   = block.Hash()
+		number = block.NumberU64()
+		traces = []*parityTrace{}
+	)
+	for i, res := range results {
+		for _, trace := range res.Trace {
+			annotateTrace(trace, hash, number, *res.TransactionHash, uint64(i))
+		}
+		traces = append(traces, res.Trace...)
+	}
+	return traces, nil
+}
+
+// replayBlock replays all the transactions within a block on top of its parent
+// state, producing the requested kinds of traces.
+func (api *TraceAPI) replayBlock(ctx context.Context, block *types.Block, config *replayConfig) ([]*replayResult, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	statedb, err := api.api.backend.StateAtBlock(ctx, parent, defaultTraceReexec, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer   = types.MakeSigner(api.api.backend.ChainConfig(), block.Number())
+		blockCtx = core.NewEVMBlockContext(block.Header(), api.api.chainContext(ctx), nil)
+		results  = make([]*replayResult, 0, len(block.Transactions()))
+	)
+	for i, tx := range block.Transactions() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
+		txctx := &Context{
+			BlockHash: block.Hash(),
+			TxIndex:   i,
+			TxHash:    tx.Hash(),
+		}
+		res, err := api.replayTx(msg, txctx, blockCtx, statedb, config)
+		if err != nil {
+			return nil, err
+		}
+		res.TransactionHash = &txctx.TxHash
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// replayTx executes a message on top of the given state, producing the requested
+// kinds of traces. The state is finalised afterwards, so subsequent transactions
+// can be replayed on top.
+func (api *TraceAPI) replayTx(message core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *replayConfig) (*replayResult, error) {
+	var (
+		tracers    multiTracer
+		callTracer native.Tracer
+		opTracer   *vmTracer
+		diffTracer *stateDiffTracer
+		prestate   *state.StateDB
+	)
+	if config.trace {
+		callTracer, _ = native.New("callTracer")
+		tracers = append(tracers, callTracer)
+	}
+	if config.vmTrace {
+		opTracer = new(vmTracer)
+		tracers = append(tracers, opTracer)
+	}
+	if config.stateDiff {
+		diffTracer = newStateDiffTracer()
+		prestate = statedb.Copy()
+		tracers = append(tracers, diffTracer)
+	}
+	vmenv := vm.NewEVM(vmctx, core.NewEVMTxContext(message), statedb, api.api.backend.ChainConfig(), vm.Config{Debug: len(tracers) > 0, Tracer: tracers})
+
+	// Call Prepare to clear out the statedb access list
+	statedb.Prepare(txctx.TxHash, txctx.TxIndex)
+
+	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %w", err)
+	}
+	statedb.Finalise(vmenv.ChainConfig().IsEIP158(vmctx.BlockNumber))
+
+	res := &replayResult{Output: result.ReturnData}
+	if res.Output == nil {
+		res.Output = hexutil.Bytes{}
+	}
+	if callTracer != nil {
+		blob, err := callTracer.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		frame := new(callFrame)
+		if err := json.Unmarshal(blob, frame); err != nil {
+			return nil, err
+		}
+		res.Trace = flattenCallFrame(frame, nil, nil)
+	}
+	if opTracer != nil {
+		res.VmTrace = opTracer.root
+	}
+	if diffTracer != nil {
+		res.StateDiff = diffTracer.diff(prestate, statedb)
+	}
+	return res, nil
+}
+
+// annotateTrace sets the block and transaction a trace belongs to.
+func annotateTrace(trace *parityTrace, blockHash common.Hash, blockNumber uint64, txHash common.Hash, txIndex uint64) {
+	trace.BlockHash = &blockHash
+	trace.BlockNumber = &blockNumber
+	trace.TransactionHash = &txHash
+	trace.TransactionPosition = &txIndex
+}
+
+// readTraceIndexRange returns the range of canonical blocks covered by the trace
+// index. If the head of the index was reorged out, the range ends at its latest
+// canonical ancestor.
+func readTraceIndexRange(db ethdb.Reader) (uint64, uint64, bool) {
+	tail := rawdb.ReadTraceIndexTail(db)
+	if tail == nil {
+		return 0, 0, false
+	}
+	hash := rawdb.ReadTraceIndexHead(db)
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		return 0, 0, false
+	}
+	header := rawdb.ReadHeader(db, hash, *number)
+	for header != nil && rawdb.ReadCanonicalHash(db, header.Number.Uint64()) != header.Hash() {
+		if header.Number.Uint64() < *tail {
+			return 0, 0, false
+		}
+		header = rawdb.ReadHeader(db, header.ParentHash, header.Number.Uint64()-1)
+	}
+	if header == nil {
+		return 0, 0, false
+	}
+	return *tail, header.Number.Uint64(), true
+}
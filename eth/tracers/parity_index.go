@@ -0,0 +1,213 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// IndexerBackend is the backend required by the trace indexer, notifying it of
+// new chain heads on top of the tracing functionality.
+type IndexerBackend interface {
+	Backend
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// Indexer is a background service tracing the blocks as they are imported and
+// recording which transaction traces touch which addresses. This allows the
+// trace_filter queries to only re-execute the blocks involving the addresses
+// they filter for.
+//
+// The index starts at the chain head at the time it's first enabled. Entries of
+// reorged blocks are not removed, but they are filtered out by block hash. Blocks
+// which fail to be traced are recorded as gaps, re-executed by every query.
+type Indexer struct {
+	backend IndexerBackend
+	api     *TraceAPI
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewIndexer creates a trace indexer on top of the given backend.
+func NewIndexer(backend IndexerBackend) *Indexer {
+	return &Indexer{
+		backend: backend,
+		api:     NewTraceAPI(backend),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting the background indexing.
+func (idx *Indexer) Start() error {
+	idx.wg.Add(1)
+	go idx.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the background indexing.
+func (idx *Indexer) Stop() error {
+	close(idx.quit)
+	idx.wg.Wait()
+	return nil
+}
+
+// loop indexes the new blocks whenever the chain head changes. Indexing runs in
+// its own goroutine so the chain head feed is never blocked by it.
+func (idx *Indexer) loop() {
+	defer idx.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 10)
+	sub := idx.backend.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	var (
+		done    chan struct{} // Non-nil if an indexing run is in progress
+		pending bool          // Whether the head changed since the last run started
+	)
+	run := func() {
+		done = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			idx.index()
+		}(done)
+	}
+	run()
+
+	for {
+		select {
+		case <-heads:
+			if done != nil {
+				pending = true
+				continue
+			}
+			run()
+
+		case <-done:
+			done = nil
+			if pending {
+				pending = false
+				run()
+			}
+
+		case <-sub.Err():
+			if done != nil {
+				<-done
+			}
+			return
+
+		case <-idx.quit:
+			if done != nil {
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// index traces and indexes all the canonical blocks between the head of the
+// index and the head of the chain.
+func (idx *Indexer) index() {
+	var (
+		ctx = context.Background()
+		db  = idx.backend.ChainDb()
+	)
+	head, err := idx.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return
+	}
+	_, number, ok := readTraceIndexRange(db)
+	if !ok {
+		// The index is either missing or was reorged out entirely, start anew
+		// from the current chain head
+		log.Info("Starting trace indexing", "number", head.Number.Uint64()+1)
+		idx.reset(head.Number.Uint64(), head.Hash())
+		return
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for n := number + 1; n <= head.Number.Uint64(); n++ {
+		select {
+		case <-idx.quit:
+			return
+		default:
+		}
+		block, err := idx.backend.BlockByNumber(ctx, rpc.BlockNumber(n))
+		if err != nil || block == nil {
+			return // Chain reorged meanwhile, retry on the next head
+		}
+		batch := db.NewBatch()
+
+		traces, err := idx.api.blockTraces(ctx, block)
+		if err != nil {
+			// The state of the block is most probably unavailable, record the gap so
+			// queries don't rely on the index for it and continue with the next block
+			log.Warn("Failed to trace block for indexing", "number", n, "hash", block.Hash(), "err", err)
+			rawdb.WriteTraceIndexGap(batch, n, block.Hash())
+		}
+		var (
+			txIndex    = -1
+			traceIndex uint32
+		)
+		for _, trace := range traces {
+			if int(*trace.TransactionPosition) != txIndex {
+				txIndex, traceIndex = int(*trace.TransactionPosition), 0
+			}
+			entry := rawdb.TraceIndexEntry{
+				BlockNumber: n,
+				BlockHash:   block.Hash(),
+				TxIndex:     uint32(txIndex),
+				TraceIndex:  traceIndex,
+			}
+			for _, addr := range []*common.Address{trace.from, trace.to} {
+				if addr != nil {
+					rawdb.WriteTraceIndexEntry(batch, *addr, entry)
+				}
+			}
+			traceIndex++
+		}
+		rawdb.WriteTraceIndexHead(batch, block.Hash())
+		if err := batch.Write(); err != nil {
+			log.Crit("Failed to write trace index", "err", err)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing transaction traces", "number", n, "head", head.Number, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+}
+
+// reset restarts the index right after the given block.
+func (idx *Indexer) reset(number uint64, hash common.Hash) {
+	batch := idx.backend.ChainDb().NewBatch()
+	rawdb.WriteTraceIndexTail(batch, number+1)
+	rawdb.WriteTraceIndexHead(batch, hash)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to reset trace index", "err", err)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// parityCaller is a contract storing 1 into slot 0 and sending 1 wei to the
+	// parityCallee account.
+	parityCaller     = common.HexToAddress("0xaaaa")
+	parityCallee     = common.HexToAddress("0xbbbb")
+	parityCallerCode = common.FromHex("600160005560006000600060006001" + "61bbbb" + "5af15000")
+)
+
+// newParityTestBackend creates a chain where each block contains a transfer to
+// accounts[1] and, in the odd blocks, a call to the parityCaller contract.
+func newParityTestBackend(t *testing.T, n int) (*testBackend, Accounts) {
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		parityCaller:     {Balance: big.NewInt(params.Ether), Code: parityCallerCode},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, n, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(accounts[0].addr), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+		if i%2 == 0 {
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(accounts[0].addr), parityCaller, big.NewInt(0), 100000, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	return backend, accounts
+}
+
+func TestTraceBlockParity(t *testing.T) {
+	t.Parallel()
+
+	backend, accounts := newParityTestBackend(t, 1)
+	api := NewTraceAPI(backend)
+
+	block := backend.chain.GetBlockByNumber(1)
+	traces, err := api.Block(context.Background(), rpc.BlockNumber(1))
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(traces), 3)
+	}
+	// Check the plain transfer
+	transfer := traces[0]
+	if transfer.Type != "call" || transfer.Subtraces != 0 || len(transfer.TraceAddress) != 0 {
+		t.Errorf("transfer trace mismatch: %+v", transfer)
+	}
+	if action := transfer.Action.(*parityCallAction); action.From != accounts[0].addr || action.To != accounts[1].addr || action.Value.ToInt().Int64() != 1000 || action.CallType != "call" {
+		t.Errorf("transfer action mismatch: %+v", action)
+	}
+	if *transfer.BlockHash != block.Hash() || *transfer.BlockNumber != 1 || *transfer.TransactionHash != block.Transactions()[0].Hash() || *transfer.TransactionPosition != 0 {
+		t.Errorf("transfer position mismatch: %+v", transfer)
+	}
+	// Check the contract call along with its inner call
+	outer, inner := traces[1], traces[2]
+	if outer.Subtraces != 1 || len(outer.TraceAddress) != 0 || *outer.TransactionPosition != 1 {
+		t.Errorf("outer trace mismatch: %+v", outer)
+	}
+	if action := outer.Action.(*parityCallAction); action.From != accounts[0].addr || action.To != parityCaller {
+		t.Errorf("outer action mismatch: %+v", action)
+	}
+	if outer.Result.(*parityCallResult).GasUsed == 0 {
+		t.Errorf("outer call reported no gas used")
+	}
+	if inner.Subtraces != 0 || !reflect.DeepEqual(inner.TraceAddress, []int{0}) || *inner.TransactionPosition != 1 {
+		t.Errorf("inner trace mismatch: %+v", inner)
+	}
+	if action := inner.Action.(*parityCallAction); action.From != parityCaller || action.To != parityCallee || action.Value.ToInt().Int64() != 1 {
+		t.Errorf("inner action mismatch: %+v", action)
+	}
+	// Tracing the transaction individually should yield the same traces
+	txTraces, err := api.Transaction(context.Background(), block.Transactions()[1].Hash())
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	have, _ := json.Marshal(txTraces)
+	want, _ := json.Marshal(traces[1:])
+	if string(have) != string(want) {
+		t.Errorf("transaction traces mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+func TestReplayBlockTransactions(t *testing.T) {
+	t.Parallel()
+
+	backend, accounts := newParityTestBackend(t, 1)
+	api := NewTraceAPI(backend)
+
+	if _, err := api.ReplayBlockTransactions(context.Background(), rpc.BlockNumber(1), []string{"bogus"}); err == nil {
+		t.Fatalf("unknown trace type accepted")
+	}
+	results, err := api.ReplayBlockTransactions(context.Background(), rpc.BlockNumber(1), []string{"vmTrace", "stateDiff"})
+	if err != nil {
+		t.Fatalf("failed to replay block: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), 2)
+	}
+	call := results[1]
+	if call.Trace != nil {
+		t.Errorf("unrequested trace returned")
+	}
+	// Check the executed instructions of the contract call
+	ops := call.VmTrace.Ops
+	if len(ops) != 13 {
+		t.Fatalf("vmTrace op count mismatch: have %d, want %d", len(ops), 13)
+	}
+	if push := ops[0].Ex.Push; len(push) != 1 || push[0].ToInt().Int64() != 1 {
+		t.Errorf("PUSH1 effects mismatch: %+v", ops[0].Ex)
+	}
+	if store := ops[2].Ex.Store; store == nil || store.Key.ToInt().Sign() != 0 || store.Val.ToInt().Int64() != 1 {
+		t.Errorf("SSTORE effects mismatch: %+v", ops[2].Ex)
+	}
+	if ops[3].Ex.Used >= ops[2].Ex.Used {
+		t.Errorf("gas usage not decreasing: %d -> %d", ops[2].Ex.Used, ops[3].Ex.Used)
+	}
+	if ex := ops[10].Ex; ops[10].Sub != nil || len(ex.Push) != 1 || ex.Push[0].ToInt().Int64() != 1 {
+		t.Errorf("CALL effects mismatch: %+v", ex)
+	}
+	// Check the state changes of the contract call
+	diff := call.StateDiff
+	if len(diff) != 3 {
+		t.Errorf("state diff account count mismatch: have %d, want %d", len(diff), 3)
+	}
+	caller := diff[parityCaller]
+	if caller == nil || caller.Code != "=" || caller.Nonce != "=" || len(caller.Storage) != 1 {
+		t.Fatalf("caller diff mismatch: %+v", caller)
+	}
+	want := map[string]interface{}{"*": map[string]interface{}{"from": common.Hash{}, "to": common.BigToHash(common.Big1)}}
+	if have := caller.Storage[common.Hash{}]; !reflect.DeepEqual(have, want) {
+		t.Errorf("caller storage diff mismatch: have %v, want %v", have, want)
+	}
+	want = map[string]interface{}{"+": (*hexutil.Big)(big.NewInt(1))}
+	if callee := diff[parityCallee]; callee == nil || !reflect.DeepEqual(callee.Balance, want) {
+		t.Errorf("callee diff mismatch: %+v", callee)
+	}
+	if sender := diff[accounts[0].addr]; sender == nil || sender.Balance == "=" || sender.Nonce == "=" {
+		t.Errorf("sender diff mismatch: %+v", sender)
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	backend, accounts := newParityTestBackend(t, 6)
+	api := NewTraceAPI(backend)
+
+	filter := func(args TraceFilterArgs) []*parityTrace {
+		traces, err := api.Filter(context.Background(), args)
+		if err != nil {
+			t.Fatalf("failed to filter traces: %v", err)
+		}
+		return traces
+	}
+	var (
+		first = rpc.EarliestBlockNumber
+		from  = rpc.BlockNumber(2)
+		to    = rpc.BlockNumber(5)
+		count = uint64(1)
+		after = uint64(1)
+	)
+	tests := []struct {
+		args   TraceFilterArgs
+		blocks []uint64
+	}{
+		{TraceFilterArgs{FromBlock: &first, ToAddress: []common.Address{parityCallee}}, []uint64{1, 3, 5}},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{parityCallee}}, []uint64{3, 5}},
+		{TraceFilterArgs{FromBlock: &first, FromAddress: []common.Address{accounts[0].addr}, ToAddress: []common.Address{parityCaller}}, []uint64{1, 3, 5}},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to}, []uint64{2, 3, 3, 3, 4, 5, 5, 5}},
+		{TraceFilterArgs{FromBlock: &first, ToAddress: []common.Address{parityCallee}, After: &after, Count: &count}, []uint64{3}},
+	}
+	check := func(indexed bool) {
+		for i, tt := range tests {
+			traces := filter(tt.args)
+			blocks := make([]uint64, len(traces))
+			for j, trace := range traces {
+				blocks[j] = *trace.BlockNumber
+			}
+			if !reflect.DeepEqual(blocks, tt.blocks) {
+				t.Errorf("test %d (indexed %v): traced blocks mismatch: have %v, want %v", i, indexed, blocks, tt.blocks)
+			}
+		}
+	}
+	check(false)
+
+	// Index the chain from the second block, failing to trace the third one, and
+	// ensure results are identical
+	db := backend.ChainDb()
+	rawdb.WriteTraceIndexTail(db, 2)
+	rawdb.WriteTraceIndexHead(db, backend.chain.GetBlockByNumber(1).Hash())
+
+	NewIndexer(&missingStateBackend{testBackend: backend, missing: 2}).index()
+	if head := rawdb.ReadTraceIndexHead(db); head != backend.chain.CurrentBlock().Hash() {
+		t.Fatalf("trace index head mismatch: have %x, want %x", head, backend.chain.CurrentBlock().Hash())
+	}
+	if tail := rawdb.ReadTraceIndexTail(db); tail == nil || *tail != 2 {
+		t.Fatalf("trace index tail mismatch: have %v, want %d", tail, 2)
+	}
+	var numbers []uint64
+	for _, entry := range rawdb.ReadTraceIndexEntries(db, parityCallee, 0, 100) {
+		numbers = append(numbers, entry.BlockNumber)
+	}
+	if !reflect.DeepEqual(numbers, []uint64{5}) {
+		t.Fatalf("indexed blocks mismatch: have %v, want %v", numbers, []uint64{5})
+	}
+	if have, want := rawdb.ReadTraceIndexGaps(db, 0, 100), map[uint64]common.Hash{3: backend.chain.GetBlockByNumber(3).Hash()}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("index gaps mismatch: have %v, want %v", have, want)
+	}
+	check(true)
+
+	// Cap the number of unindexed blocks, counting the ones before the tail of the
+	// index and its gaps when filtering by address
+	backend.filterCap = 4
+	check(true)
+
+	backend.filterCap = 1
+	if _, err := api.Filter(context.Background(), tests[0].args); err == nil {
+		t.Errorf("query above the unindexed block cap succeeded")
+	}
+	if _, err := api.Filter(context.Background(), tests[1].args); err != nil {
+		t.Errorf("query within the unindexed block cap failed: %v", err)
+	}
+	if _, err := api.Filter(context.Background(), tests[3].args); err == nil {
+		t.Errorf("unindexed query above the cap succeeded")
+	}
+}
+
+// missingStateBackend is a test backend without the state of a specific block.
+type missingStateBackend struct {
+	*testBackend
+	missing uint64 // Number of the block whose state is unavailable
+}
+
+func (b *missingStateBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool) (*state.StateDB, error) {
+	if block.NumberU64() == b.missing {
+		return nil, errStateNotFound
+	}
+	return b.testBackend.StateAtBlock(ctx, block, reexec, base, checkLive)
+}
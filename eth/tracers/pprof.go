@@ -0,0 +1,188 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Field numbers of the pprof profile protocol buffer messages, as defined in
+// https://github.com/google/pprof/blob/master/proto/profile.proto.
+const (
+	pprofProfileSampleType  = 1
+	pprofProfileSample      = 2
+	pprofProfileLocation    = 4
+	pprofProfileFunction    = 5
+	pprofProfileStringTable = 6
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocation = 1
+	pprofSampleValue    = 2
+
+	pprofLocationID   = 1
+	pprofLocationLine = 4
+
+	pprofLineFunction = 1
+
+	pprofFunctionID   = 1
+	pprofFunctionName = 2
+)
+
+// pprofBuffer is a minimal protocol buffer encoder for the pprof format.
+type pprofBuffer struct {
+	data []byte
+}
+
+// varint appends a varint encoded field.
+func (b *pprofBuffer) varint(field int, value uint64) {
+	b.data = appendVarint(appendVarint(b.data, uint64(field)<<3), value)
+}
+
+// packed appends a packed repeated varint field.
+func (b *pprofBuffer) packed(field int, values []uint64) {
+	var buf []byte
+	for _, value := range values {
+		buf = appendVarint(buf, value)
+	}
+	b.bytes(field, buf)
+}
+
+// bytes appends a length delimited field.
+func (b *pprofBuffer) bytes(field int, value []byte) {
+	b.data = appendVarint(b.data, uint64(field)<<3|2)
+	b.data = append(appendVarint(b.data, uint64(len(value))), value...)
+}
+
+// appendVarint appends the varint encoding of the value to the buffer.
+func appendVarint(buf []byte, value uint64) []byte {
+	var enc [binary.MaxVarintLen64]byte
+	return append(buf, enc[:binary.PutUvarint(enc[:], value)]...)
+}
+
+// pprofProfile assembles a pprof profile with interned strings and functions.
+type pprofProfile struct {
+	buf       pprofBuffer
+	strings   map[string]uint64
+	functions map[string]uint64
+	table     []string
+}
+
+// str interns a string into the string table, returning its index.
+func (p *pprofProfile) str(s string) uint64 {
+	if id, ok := p.strings[s]; ok {
+		return id
+	}
+	id := uint64(len(p.table))
+	p.strings[s] = id
+	p.table = append(p.table, s)
+	return id
+}
+
+// location interns a function along with its sole location, returning the
+// location id.
+func (p *pprofProfile) location(name string) uint64 {
+	if id, ok := p.functions[name]; ok {
+		return id
+	}
+	id := uint64(len(p.functions) + 1)
+	p.functions[name] = id
+
+	var fn pprofBuffer
+	fn.varint(pprofFunctionID, id)
+	fn.varint(pprofFunctionName, p.str(name))
+	p.buf.bytes(pprofProfileFunction, fn.data)
+
+	var line, loc pprofBuffer
+	line.varint(pprofLineFunction, id)
+	loc.varint(pprofLocationID, id)
+	loc.bytes(pprofLocationLine, line.data)
+	p.buf.bytes(pprofProfileLocation, loc.data)
+
+	return id
+}
+
+// sample appends a sample with the given call stack, from the root to the leaf.
+func (p *pprofProfile) sample(stack []string, stat *ProfileStat) {
+	locs := make([]uint64, len(stack))
+	for i, name := range stack {
+		locs[len(stack)-1-i] = p.location(name)
+	}
+	var sample pprofBuffer
+	sample.packed(pprofSampleLocation, locs)
+	sample.packed(pprofSampleValue, []uint64{stat.Count, stat.Gas, uint64(stat.Time)})
+	p.buf.bytes(pprofProfileSample, sample.data)
+}
+
+// WritePprof writes the collected stats as a gzipped pprof profile. Each sample
+// is an opcode or precompile on top of the contract code executing it, with the
+// execution count, gas usage and execution time as values.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	prof := &pprofProfile{
+		strings:   map[string]uint64{"": 0},
+		functions: make(map[string]uint64),
+		table:     []string{""},
+	}
+	for _, typ := range [][2]string{{"executions", "count"}, {"gas", "gas"}, {"time", "nanoseconds"}} {
+		var vt pprofBuffer
+		vt.varint(pprofValueTypeType, prof.str(typ[0]))
+		vt.varint(pprofValueTypeUnit, prof.str(typ[1]))
+		prof.buf.bytes(pprofProfileSampleType, vt.data)
+	}
+	// Emit the samples in a deterministic order
+	type sample struct {
+		stack []string
+		stat  *ProfileStat
+	}
+	var samples []sample
+	for key, stat := range p.opcodes {
+		samples = append(samples, sample{[]string{"contract " + key.code.Hex(), key.op.String()}, stat})
+	}
+	for key, stat := range p.precompiles {
+		stack := []string{"precompile " + key.addr.Hex()}
+		if key.caller != (common.Hash{}) {
+			stack = append([]string{"contract " + key.caller.Hex()}, stack...)
+		}
+		samples = append(samples, sample{stack, stat})
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].stack, samples[j].stack
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for _, s := range samples {
+		prof.sample(s.stack, s.stat)
+	}
+	for _, s := range prof.table {
+		prof.buf.bytes(pprofProfileStringTable, []byte(s))
+	}
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(prof.buf.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
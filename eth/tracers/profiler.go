@@ -0,0 +1,393 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ProfileStat is the aggregated cost of an opcode, precompile or contract.
+type ProfileStat struct {
+	Count uint64        `json:"count"` // Number of executions or invocations
+	Gas   uint64        `json:"gas"`   // Total gas consumed
+	Time  time.Duration `json:"time"`  // Total execution time in nanoseconds
+}
+
+// add accumulates another stat into this one.
+func (s *ProfileStat) add(other *ProfileStat) {
+	s.Count += other.Count
+	s.Gas += other.Gas
+	s.Time += other.Time
+}
+
+// ProfileResult is the aggregated profile of the executed transactions.
+type ProfileResult struct {
+	Transactions uint64                          `json:"transactions"`
+	Opcodes      map[string]*ProfileStat         `json:"opcodes"`
+	Precompiles  map[common.Address]*ProfileStat `json:"precompiles"`
+	Contracts    map[common.Hash]*ProfileStat    `json:"contracts"`
+}
+
+// opcodeKey identifies an opcode executed by a specific contract code.
+type opcodeKey struct {
+	code common.Hash
+	op   vm.OpCode
+}
+
+// precompileKey identifies a precompile invoked by a specific contract code.
+// Precompiles invoked directly by transactions have an empty caller.
+type precompileKey struct {
+	caller common.Hash
+	addr   common.Address
+}
+
+// profileStep is the last executed step, whose execution time is only known
+// once the next one starts.
+type profileStep struct {
+	key   opcodeKey
+	depth int
+	start time.Time
+}
+
+// profileCall is a call instruction whose gas cost is only known once the
+// callee starts executing or the call returns.
+type profileCall struct {
+	key        opcodeKey
+	depth      int
+	gas        uint64                 // Gas available before the call
+	cost       uint64                 // Gas cost including the gas handed to the callee
+	precompile vm.PrecompiledContract // Invoked precompile, nil if none
+	addr       common.Address         // Address of the invoked precompile
+	input      []byte                 // Input of the invoked precompile
+}
+
+// Profiler is a tracer aggregating the gas usage, execution time and invocation
+// count of the executed opcodes, precompiles and contract codes. It can be used
+// to trace any number of transactions, accumulating their costs.
+//
+// The gas of call instructions excludes the gas handed to the callee, which is
+// accounted to the executed code or precompile instead. Similarly, the time of
+// precompile executions is accounted to them instead of the call instruction.
+type Profiler struct {
+	opcodes     map[opcodeKey]*ProfileStat
+	precompiles map[precompileKey]*ProfileStat
+	invocations map[common.Hash]uint64
+	txs         uint64
+
+	// Transaction context gathered throughout execution
+	active     map[common.Address]vm.PrecompiledContract // Precompiles active in the traced block
+	precompile *precompileKey                            // Precompile invoked directly by the transaction
+	last       *profileStep
+	pending    *profileCall
+}
+
+// NewProfiler creates a new profiling tracer.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		opcodes:     make(map[opcodeKey]*ProfileStat),
+		precompiles: make(map[precompileKey]*ProfileStat),
+		invocations: make(map[common.Hash]uint64),
+	}
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (p *Profiler) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	p.precompile, p.last, p.pending = nil, nil, nil
+	p.txs++
+
+	p.active = vm.ActivePrecompiledContracts(env.ChainConfig().Rules(env.Context.BlockNumber))
+	if _, ok := p.active[to]; ok && !create {
+		p.precompile = &precompileKey{addr: to}
+	}
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (p *Profiler) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	var (
+		now     = time.Now()
+		key     = opcodeKey{code: scope.Contract.CodeHash, op: op}
+		invoked = p.last == nil || depth > p.last.depth
+	)
+
+	// Account the time of the previous step and the gas of a pending call
+	if call := p.pending; call != nil {
+		p.pending = nil
+
+		switch {
+		case depth > call.depth:
+			// The callee started executing, subtract the gas it received
+			p.stat(call.key).Gas += sub(call.cost, gas)
+
+		case call.precompile != nil:
+			// The precompile executed in between the two steps
+			used := call.precompile.RequiredGas(call.input)
+			if consumed := sub(call.gas, gas); used > consumed {
+				used = consumed
+			}
+			stat := p.precompileStat(precompileKey{caller: call.key.code, addr: call.addr})
+			stat.Count++
+			stat.Gas += used
+			stat.Time += now.Sub(p.last.start)
+			p.stat(call.key).Gas += sub(sub(call.gas, gas), used)
+			p.last = nil
+
+		default:
+			// No code was executed, only the call itself consumed gas
+			p.stat(call.key).Gas += sub(call.gas, gas)
+		}
+	}
+	if p.last != nil {
+		p.stat(p.last.key).Time += now.Sub(p.last.start)
+	}
+	if invoked {
+		p.invocations[key.code]++
+	}
+	stat := p.stat(key)
+	stat.Count++
+
+	switch op {
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		if err != nil {
+			stat.Gas += cost
+			break
+		}
+		call := &profileCall{key: key, depth: depth, gas: gas, cost: cost}
+		addr := common.Address(scope.Stack.Back(1).Bytes20())
+		if contract, ok := p.active[addr]; ok {
+			// stack: gas, address, [value], argsOffset, argsLength, ...
+			args := 2
+			if op == vm.CALL || op == vm.CALLCODE {
+				args = 3
+			}
+			offset, size := scope.Stack.Back(args).Uint64(), scope.Stack.Back(args+1).Uint64()
+			call.precompile, call.addr, call.input = contract, addr, scope.Memory.GetCopy(int64(offset), int64(size))
+		}
+		p.pending = call
+
+	default:
+		stat.Gas += cost
+	}
+	p.last = &profileStep{key: key, depth: depth, start: time.Now()}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault.
+func (p *Profiler) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (p *Profiler) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) {
+	if p.precompile != nil {
+		stat := p.precompileStat(*p.precompile)
+		stat.Count++
+		stat.Gas += gasUsed
+		stat.Time += t
+	}
+	if p.last != nil {
+		p.stat(p.last.key).Time += time.Since(p.last.start)
+	}
+	p.precompile, p.last, p.pending = nil, nil, nil
+}
+
+// stat retrieves the stats of an opcode, creating them if necessary.
+func (p *Profiler) stat(key opcodeKey) *ProfileStat {
+	stat := p.opcodes[key]
+	if stat == nil {
+		stat = new(ProfileStat)
+		p.opcodes[key] = stat
+	}
+	return stat
+}
+
+// precompileStat retrieves the stats of a precompile, creating them if necessary.
+func (p *Profiler) precompileStat(key precompileKey) *ProfileStat {
+	stat := p.precompiles[key]
+	if stat == nil {
+		stat = new(ProfileStat)
+		p.precompiles[key] = stat
+	}
+	return stat
+}
+
+// Result aggregates the collected stats per opcode, per precompile and per
+// contract code hash.
+func (p *Profiler) Result() *ProfileResult {
+	res := &ProfileResult{
+		Transactions: p.txs,
+		Opcodes:      make(map[string]*ProfileStat),
+		Precompiles:  make(map[common.Address]*ProfileStat),
+		Contracts:    make(map[common.Hash]*ProfileStat),
+	}
+	for key, stat := range p.opcodes {
+		if res.Opcodes[key.op.String()] == nil {
+			res.Opcodes[key.op.String()] = new(ProfileStat)
+		}
+		res.Opcodes[key.op.String()].add(stat)
+
+		if res.Contracts[key.code] == nil {
+			res.Contracts[key.code] = &ProfileStat{Count: p.invocations[key.code]}
+		}
+		res.Contracts[key.code].Gas += stat.Gas
+		res.Contracts[key.code].Time += stat.Time
+	}
+	for key, stat := range p.precompiles {
+		if res.Precompiles[key.addr] == nil {
+			res.Precompiles[key.addr] = new(ProfileStat)
+		}
+		res.Precompiles[key.addr].add(stat)
+	}
+	return res
+}
+
+// sub subtracts b from a, flooring the result at zero.
+func sub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
+
+// ProfileConfig holds extra parameters to the block profiling functions.
+type ProfileConfig struct {
+	Reexec *uint64
+	Pprof  bool // Whether to include a gzipped pprof profile in the result
+}
+
+// BlockProfileResult is the profile of the transactions of a range of blocks.
+type BlockProfileResult struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	*ProfileResult
+	Pprof hexutil.Bytes `json:"pprof,omitempty"`
+}
+
+// ProfileBlocks re-executes the transactions of the blocks between start and end
+// (both inclusive) with the profiling tracer, returning the aggregated gas usage,
+// execution time and execution count per opcode, precompile and contract code.
+func (api *API) ProfileBlocks(ctx context.Context, start, end rpc.BlockNumber, config *ProfileConfig) (*BlockProfileResult, error) {
+	from, err := api.blockByNumber(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.blockByNumber(ctx, end)
+	if err != nil {
+		return nil, err
+	}
+	if from.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end, start)
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	block, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(from.NumberU64()-1), from.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	// Prepare the statedb for profiling. Don't use the live database for
+	// tracing to avoid persisting state junks into the database.
+	statedb, err := api.backend.StateAtBlock(ctx, block, reexec, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		profiler = NewProfiler()
+		parent   common.Hash
+		logged   = time.Now()
+		begin    = time.Now()
+	)
+	for number := from.NumberU64(); number <= to.NumberU64(); number++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			logged = time.Now()
+			log.Info("Profiling chain segment", "start", from.NumberU64(), "end", to.NumberU64(), "current", number, "elapsed", time.Since(begin))
+		}
+		if block, err = api.blockByNumber(ctx, rpc.BlockNumber(number)); err != nil {
+			return nil, err
+		}
+		// Profile the transactions on a copy of the parent state, and regenerate
+		// the state of the block without tracing for the next iteration
+		if err := api.profileBlock(ctx, block, statedb.Copy(), profiler); err != nil {
+			return nil, err
+		}
+		if statedb, err = api.backend.StateAtBlock(ctx, block, reexec, statedb, false); err != nil {
+			return nil, err
+		}
+		if statedb.Database().TrieDB() != nil {
+			// Hold the reference for profiling, the parent state is not needed any more
+			statedb.Database().TrieDB().Reference(block.Root(), common.Hash{})
+			if parent != (common.Hash{}) {
+				statedb.Database().TrieDB().Dereference(parent)
+			}
+		}
+		parent = block.Root()
+	}
+	if parent != (common.Hash{}) && statedb.Database().TrieDB() != nil {
+		statedb.Database().TrieDB().Dereference(parent)
+	}
+	res := &BlockProfileResult{
+		From:          from.NumberU64(),
+		To:            to.NumberU64(),
+		ProfileResult: profiler.Result(),
+	}
+	if config != nil && config.Pprof {
+		var buf bytes.Buffer
+		if err := profiler.WritePprof(&buf); err != nil {
+			return nil, err
+		}
+		res.Pprof = buf.Bytes()
+	}
+	return res, nil
+}
+
+// profileBlock executes the transactions of the block on top of the given state
+// with the profiling tracer.
+func (api *API) profileBlock(ctx context.Context, block *types.Block, statedb *state.StateDB, profiler *Profiler) error {
+	var (
+		header  = block.Header()
+		gp      = new(core.GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+		config  = vm.Config{Debug: true, Tracer: profiler}
+	)
+	for i, tx := range block.Transactions() {
+		statedb.Prepare(tx.Hash(), i)
+		if _, err := core.ApplyTransaction(api.backend.ChainConfig(), api.chainContext(ctx), nil, gp, statedb, header, tx, usedGas, config); err != nil {
+			return fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestProfileBlocks(t *testing.T) {
+	t.Parallel()
+
+	var (
+		accounts = newAccounts(1)
+		sha256   = common.BytesToAddress([]byte{2})
+
+		// hasher is a contract calling the sha256 precompile with empty input
+		hasher     = common.HexToAddress("0xcccc")
+		hasherCode = common.FromHex("600060006000600060006002" + "5af100")
+	)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		parityCaller:     {Balance: big.NewInt(params.Ether), Code: parityCallerCode},
+		hasher:           {Balance: new(big.Int), Code: hasherCode},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		for _, to := range []common.Address{hasher, sha256, parityCaller} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(accounts[0].addr), to, big.NewInt(0), 100000, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	api := NewAPI(backend)
+
+	if _, err := api.ProfileBlocks(context.Background(), rpc.BlockNumber(2), rpc.BlockNumber(1), nil); err == nil {
+		t.Fatalf("reversed block range accepted")
+	}
+	res, err := api.ProfileBlocks(context.Background(), rpc.BlockNumber(1), rpc.BlockNumber(2), &ProfileConfig{Pprof: true})
+	if err != nil {
+		t.Fatalf("failed to profile blocks: %v", err)
+	}
+	if res.From != 1 || res.To != 2 || res.Transactions != 6 {
+		t.Errorf("profiled range mismatch: from %d, to %d, transactions %d", res.From, res.To, res.Transactions)
+	}
+	// Check the opcode stats, the contracts push 13 single bytes per block
+	if push := res.Opcodes["PUSH1"]; push == nil || push.Count != 26 || push.Gas != 26*3 {
+		t.Errorf("PUSH1 stats mismatch: %+v", push)
+	}
+	// The warm precompile calls cost 100 gas, whereas the value transfers cost
+	// 2600 for the cold access and 9000 for the value, minus the 2300 gas stipend
+	// returned, plus 25000 for creating the callee in the first block
+	if call := res.Opcodes["CALL"]; call == nil || call.Count != 4 || call.Gas != 2*100+2*9300+25000 {
+		t.Errorf("CALL stats mismatch: %+v", call)
+	}
+	if sstore := res.Opcodes["SSTORE"]; sstore == nil || sstore.Count != 2 {
+		t.Errorf("SSTORE stats mismatch: %+v", sstore)
+	}
+	// Check the precompile stats, called both directly and by the contract
+	if stat := res.Precompiles[sha256]; stat == nil || stat.Count != 4 || stat.Gas != 4*params.Sha256BaseGas {
+		t.Errorf("sha256 stats mismatch: %+v", stat)
+	}
+	// Check the contract stats, excluding the gas of the called precompile
+	if stat := res.Contracts[crypto.Keccak256Hash(hasherCode)]; stat == nil || stat.Count != 2 || stat.Gas != 2*(6*3+2+100) {
+		t.Errorf("hasher stats mismatch: %+v", stat)
+	}
+	if stat := res.Contracts[crypto.Keccak256Hash(parityCallerCode)]; stat == nil || stat.Count != 2 {
+		t.Errorf("caller stats mismatch: %+v", stat)
+	}
+	// Check that the pprof profile is produced
+	gz, err := gzip.NewReader(bytes.NewReader(res.Pprof))
+	if err != nil {
+		t.Fatalf("failed to open pprof profile: %v", err)
+	}
+	if blob, err := ioutil.ReadAll(gz); err != nil || !bytes.Contains(blob, []byte("precompile "+sha256.Hex())) {
+		t.Errorf("pprof profile mismatch: %v", err)
+	}
+}
@@ -17,6 +17,7 @@
 package tracers
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/json"
@@ -24,6 +25,7 @@ import (
 	"math/big"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -35,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/tests"
@@ -283,6 +286,93 @@ func TestCallTracer(t *testing.T) {
 	}
 }
 
+// Tests that the native tracers produce the exact same output as their JavaScript
+// counterparts, byte for byte, apart from the execution time.
+func TestNativeTracers(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	timeRe := regexp.MustCompile(`,"time":"[^"]*"`)
+
+	for _, name := range []string{"callTracer", "prestateTracer", "4byteTracer"} {
+		for _, file := range files {
+			if !strings.HasPrefix(file.Name(), "call_tracer_") {
+				continue
+			}
+			name, file := name, file // capture range variables
+			t.Run(name+"/"+camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
+				t.Parallel()
+
+				blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
+				if err != nil {
+					t.Fatalf("failed to read testcase: %v", err)
+				}
+				test := new(callTracerTest)
+				if err := json.Unmarshal(blob, test); err != nil {
+					t.Fatalf("failed to parse testcase: %v", err)
+				}
+				jsTracer, err := New(name, new(Context))
+				if err != nil {
+					t.Fatalf("failed to create JavaScript tracer: %v", err)
+				}
+				want, err := runTracerTest(test, jsTracer)
+				if err != nil {
+					t.Fatalf("JavaScript tracer failed: %v", err)
+				}
+				nativeTracer, ok := native.New(name)
+				if !ok {
+					t.Fatalf("native tracer not found")
+				}
+				have, err := runTracerTest(test, nativeTracer)
+				if err != nil {
+					t.Fatalf("native tracer failed: %v", err)
+				}
+				have, want = timeRe.ReplaceAll(have, nil), timeRe.ReplaceAll(want, nil)
+				if !bytes.Equal(have, want) {
+					t.Fatalf("trace mismatch:\nhave %s\nwant %s", have, want)
+				}
+			})
+		}
+	}
+}
+
+// runTracerTest executes the transaction of a call tracer test on top of its
+// prestate with the given tracer, returning the tracer's result.
+func runTracerTest(test *callTracerTest, tracer native.Tracer) (json.RawMessage, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+	txContext := vm.TxContext{
+		Origin:   origin,
+		GasPrice: tx.GasPrice(),
+	}
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+	}
+	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+	evm := vm.NewEVM(context, txContext, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+
+	msg, err := tx.AsMessage(signer, nil)
+	if err != nil {
+		return nil, err
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	if _, err = st.TransitionDb(); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
 // jsonEqual is similar to reflect.DeepEqual, but does a 'bounce' via json prior to
 // comparison
 func jsonEqual(x, y interface{}) bool {
@@ -49,6 +49,16 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	return NewClient(c), nil
 }
 
+// DialContextWithAuth connects a client to an authenticated HTTP or websocket
+// endpoint, signing every request with a JWT derived from the shared secret.
+func DialContextWithAuth(ctx context.Context, rawurl string, secret []byte) (*Client, error) {
+	c, err := rpc.DialContextWithAuth(ctx, rawurl, rpc.NewJWTAuth(secret))
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
 // NewClient creates a client that uses the given RPC client.
 func NewClient(c *rpc.Client) *Client {
 	return &Client{c}
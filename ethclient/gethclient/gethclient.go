@@ -19,6 +19,7 @@ package gethclient
 
 import (
 	"context"
+	"encoding/json"
 	"math/big"
 	"runtime"
 	"runtime/debug"
@@ -146,6 +147,67 @@ func (ec *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockN
 	return hex, err
 }
 
+// BlockOverrides specifies the block context fields to override when executing
+// calls with CallMany.
+type BlockOverrides struct {
+	Number   *big.Int
+	Time     *uint64
+	Coinbase *common.Address
+	BaseFee  *big.Int
+}
+
+// CallResult is the outcome of a single message executed by CallMany.
+type CallResult struct {
+	ReturnData   []byte
+	GasUsed      uint64
+	Logs         []*types.Log
+	Error        string
+	RevertReason string
+	Trace        json.RawMessage
+}
+
+// CallMany executes the given messages in order on top of the given block, each
+// of them seeing the state changes of the preceding ones. The state overrides,
+// block overrides and tracer are optional, the latter being the name of a native
+// tracer to run on each of the messages.
+func (ec *Client) CallMany(ctx context.Context, msgs []ethereum.CallMsg, blockNumber *big.Int, overrides *map[common.Address]OverrideAccount, blockOverrides *BlockOverrides, tracer string) ([]*CallResult, error) {
+	type callResult struct {
+		ReturnData   hexutil.Bytes   `json:"returnData"`
+		GasUsed      hexutil.Uint64  `json:"gasUsed"`
+		Logs         []*types.Log    `json:"logs"`
+		Error        string          `json:"error,omitempty"`
+		RevertReason string          `json:"revertReason,omitempty"`
+		Trace        json.RawMessage `json:"trace,omitempty"`
+	}
+	args := make([]interface{}, len(msgs))
+	for i, msg := range msgs {
+		args[i] = toCallArg(msg)
+	}
+	config := map[string]interface{}{
+		"stateOverrides": toOverrideMap(overrides),
+		"blockOverrides": toBlockOverrides(blockOverrides),
+	}
+	if tracer != "" {
+		config["tracer"] = tracer
+	}
+	var results []callResult
+	if err := ec.c.CallContext(ctx, &results, "eth_callMany", args, toBlockNumArg(blockNumber), config); err != nil {
+		return nil, err
+	}
+	calls := make([]*CallResult, len(results))
+	for i, res := range results {
+		calls[i] = &CallResult{
+			ReturnData:   res.ReturnData,
+			GasUsed:      uint64(res.GasUsed),
+			Logs:         res.Logs,
+			Error:        res.Error,
+			RevertReason: res.RevertReason,
+			Trace:        res.Trace,
+		}
+	}
+	return calls, nil
+}
+
 // GCStats retrieves the current garbage collection stats from a geth node.
 func (ec *Client) GCStats(ctx context.Context) (*debug.GCStats, error) {
 	var result debug.GCStats
@@ -179,6 +241,39 @@ func (ec *Client) SubscribePendingTransactions(ctx context.Context, ch chan<- co
 	return ec.c.EthSubscribe(ctx, ch, "newPendingTransactions")
 }
 
+// TxLifecycleEvent is a state change of a transaction within the pool of the node.
+type TxLifecycleEvent struct {
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Event       string          `json:"event"`
+	Time        hexutil.Uint64  `json:"time"`
+	Reason      string          `json:"reason,omitempty"`
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+}
+
+// SubscribeTxLifecycle subscribes to the state changes of the transactions in the
+// pool of the node. If senders or hashes are given, only the matching transactions
+// are reported.
+func (ec *Client) SubscribeTxLifecycle(ctx context.Context, senders []common.Address, hashes []common.Hash, ch chan<- *TxLifecycleEvent) (*rpc.ClientSubscription, error) {
+	filter := map[string]interface{}{
+		"senders": senders,
+		"hashes":  hashes,
+	}
+	return ec.c.Subscribe(ctx, "txpool", ch, "lifecycle", filter)
+}
+
+// SendPrivateTransaction injects a signed transaction into the pending pool of the
+// node as a private transaction, which is never propagated to the network.
+func (ec *Client) SendPrivateTransaction(ctx context.Context, tx *types.Transaction) error {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ec.c.CallContext(ctx, nil, "eth_sendPrivateRawTransaction", hexutil.Encode(data))
+}
+
 func toBlockNumArg(number *big.Int) string {
 	if number == nil {
 		return "latest"
@@ -233,3 +328,23 @@ func toOverrideMap(overrides *map[common.Address]OverrideAccount) interface{} {
 	}
 	return &result
 }
+
+func toBlockOverrides(overrides *BlockOverrides) interface{} {
+	if overrides == nil {
+		return nil
+	}
+	arg := make(map[string]interface{})
+	if overrides.Number != nil {
+		arg["number"] = (*hexutil.Big)(overrides.Number)
+	}
+	if overrides.Time != nil {
+		arg["time"] = hexutil.Uint64(*overrides.Time)
+	}
+	if overrides.Coinbase != nil {
+		arg["coinbase"] = overrides.Coinbase
+	}
+	if overrides.BaseFee != nil {
+		arg["baseFee"] = (*hexutil.Big)(overrides.BaseFee)
+	}
+	return arg
+}
@@ -20,7 +20,9 @@ import (
 	"bytes"
 	"context"
 	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -121,9 +123,18 @@ func TestGethClient(t *testing.T) {
 		"TestSubscribePendingTxs": {
 			func(t *testing.T) { testSubscribePendingTransactions(t, client) },
 		},
+		"TestSendPrivateTx": {
+			func(t *testing.T) { testSendPrivateTransaction(t, client) },
+		},
+		"TestSubscribeTxLifecycle": {
+			func(t *testing.T) { testSubscribeTxLifecycle(t, client) },
+		},
 		"TestCallContract": {
 			func(t *testing.T) { testCallContract(t, client) },
 		},
+		"TestCallMany": {
+			func(t *testing.T) { testCallMany(t, client) },
+		},
 	}
 	t.Parallel()
 	for name, tt := range tests {
@@ -280,6 +291,99 @@ func testSubscribePendingTransactions(t *testing.T, client *rpc.Client) {
 	}
 }
 
+func testSendPrivateTransaction(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	ethcl := ethclient.NewClient(client)
+
+	chainID, err := ethcl.ChainID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Create a transaction not conflicting with the other tests
+	tx := types.NewTransaction(1, common.Address{2}, big.NewInt(1), 22000, big.NewInt(1), nil)
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ec.SendPrivateTransaction(context.Background(), signedTx); err != nil {
+		t.Fatal(err)
+	}
+	// Check that the transaction is pooled and flagged private
+	var content map[string]map[string]map[string]struct {
+		Hash    common.Hash `json:"hash"`
+		Private bool        `json:"private"`
+	}
+	if err := client.Call(&content, "txpool_content"); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, txs := range content {
+		if pooled, ok := txs[testAddr.Hex()]["1"]; ok && pooled.Hash == signedTx.Hash() {
+			found = true
+			if !pooled.Private {
+				t.Fatalf("pooled transaction not flagged private")
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("private transaction not pooled")
+	}
+}
+
+func testSubscribeTxLifecycle(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	ethcl := ethclient.NewClient(client)
+
+	chainID, err := ethcl.ChainID(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Create two transactions not conflicting with the other tests
+	signer := types.LatestSignerForChainID(chainID)
+	ignored, err := types.SignTx(types.NewTransaction(2, common.Address{3}, big.NewInt(1), 22000, big.NewInt(1), nil), signer, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched, err := types.SignTx(types.NewTransaction(3, common.Address{3}, big.NewInt(1), 22000, big.NewInt(1), nil), signer, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Subscribe to the lifecycle of the second one and send both
+	ch := make(chan *TxLifecycleEvent, 16)
+	sub, err := ec.SubscribeTxLifecycle(context.Background(), nil, []common.Hash{watched.Hash()}, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, tx := range []*types.Transaction{ignored, watched} {
+		if err := ethcl.SendTransaction(context.Background(), tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case ev := <-ch:
+		if ev.Hash != watched.Hash() || ev.From != testAddr || ev.Event != "added" {
+			t.Fatalf("event mismatch: have %s %x from %x, want added %x from %x", ev.Event, ev.Hash, ev.From, watched.Hash(), testAddr)
+		}
+	case err := <-sub.Err():
+		t.Fatalf("subscription failed: %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("lifecycle event not delivered")
+	}
+	// Check that the lifecycle of the transaction is queryable too
+	var status struct {
+		Status string              `json:"status"`
+		Events []*TxLifecycleEvent `json:"events"`
+	}
+	if err := client.Call(&status, "txpool_status", watched.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Events) == 0 || status.Events[0].Event != "added" || status.Status == "unknown" {
+		t.Fatalf("lifecycle mismatch: status %s, events %d", status.Status, len(status.Events))
+	}
+}
+
 func testCallContract(t *testing.T, client *rpc.Client) {
 	ec := New(client)
 	msg := ethereum.CallMsg{
@@ -303,3 +407,100 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func testCallMany(t *testing.T, client *rpc.Client) {
+	ec := New(client)
+	var (
+		counter  = common.HexToAddress("0x1111")
+		env      = common.HexToAddress("0x2222")
+		reverter = common.HexToAddress("0x3333")
+		coinbase = common.HexToAddress("0x4444")
+		time     = uint64(1234)
+	)
+	// The counter increments slot 0, logs and returns the new value. The env
+	// contract returns the coinbase, timestamp and number, while the reverter
+	// reverts with the reason "boom".
+	overrides := map[common.Address]OverrideAccount{
+		counter: {Code: common.FromHex("600054600101806000558060005260206000a060206000f3")},
+		env:     {Code: common.FromHex("41600052426020524360405260606000f3")},
+		reverter: {Code: common.FromHex("6064600c60003960646000fd" +
+			"08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000004" +
+			"626f6f6d00000000000000000000000000000000000000000000000000000000")},
+	}
+	blockOverrides := &BlockOverrides{
+		Number:   big.NewInt(100),
+		Time:     &time,
+		Coinbase: &coinbase,
+	}
+	msgs := []ethereum.CallMsg{
+		{From: testAddr, To: &counter, Gas: 100000},
+		{From: testAddr, To: &counter, Gas: 100000},
+		{From: testAddr, To: &env, Gas: 100000},
+		{From: testAddr, To: &reverter, Gas: 100000},
+	}
+	results, err := ec.CallMany(context.Background(), msgs, big.NewInt(0), &overrides, blockOverrides, "callTracer")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != len(msgs) {
+		t.Fatalf("result count mismatch: have %d, want %d", len(results), len(msgs))
+	}
+	// The second call must see the state changes of the first one
+	for i, want := range []int64{1, 2} {
+		res := results[i]
+		if have := new(big.Int).SetBytes(res.ReturnData); have.Int64() != want {
+			t.Errorf("call %d: counter mismatch: have %v, want %d", i, have, want)
+		}
+		if len(res.Logs) != 1 || res.Logs[0].Address != counter || new(big.Int).SetBytes(res.Logs[0].Data).Int64() != want {
+			t.Errorf("call %d: logs mismatch: %v", i, res.Logs)
+		}
+		if res.GasUsed == 0 || res.Error != "" {
+			t.Errorf("call %d: unexpected result: %+v", i, res)
+		}
+	}
+	// The block context must be overridden
+	want := append(common.LeftPadBytes(coinbase.Bytes(), 32), common.LeftPadBytes([]byte{0x04, 0xd2}, 32)...)
+	want = append(want, common.LeftPadBytes([]byte{100}, 32)...)
+	if !bytes.Equal(results[2].ReturnData, want) {
+		t.Errorf("block context mismatch: have %x, want %x", results[2].ReturnData, want)
+	}
+	// The revert must be reported along with its reason
+	if res := results[3]; res.Error != "execution reverted: boom" || res.RevertReason != "boom" || len(res.Logs) != 0 {
+		t.Errorf("revert mismatch: %+v", res)
+	}
+	for i, res := range results {
+		if !bytes.Contains(res.Trace, []byte(`"type":"CALL"`)) {
+			t.Errorf("call %d: trace missing: %s", i, res.Trace)
+		}
+	}
+	// Unknown tracers must be rejected
+	if _, err := ec.CallMany(context.Background(), msgs, big.NewInt(0), nil, nil, "bogusTracer"); err == nil {
+		t.Errorf("unknown tracer accepted")
+	}
+	// Calls failing pre-execution checks must not charge the bought gas
+	var (
+		payer   = common.HexToAddress("0x5555")
+		balance = common.HexToAddress("0x6666")
+		funds   = big.NewInt(1e18)
+	)
+	overrides = map[common.Address]OverrideAccount{
+		payer:   {Balance: funds},
+		balance: {Code: common.FromHex("73" + common.Bytes2Hex(payer.Bytes()) + "3160005260206000f3")},
+	}
+	msgs = []ethereum.CallMsg{
+		{From: payer, To: &counter, Gas: params.TxGas - 1, GasPrice: big.NewInt(params.GWei)},
+		{From: testAddr, To: &balance, Gas: 100000},
+	}
+	results, err = ec.CallMany(context.Background(), msgs, big.NewInt(0), &overrides, nil, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(results[0].Error, core.ErrIntrinsicGas.Error()) {
+		t.Errorf("intrinsic gas error mismatch: %+v", results[0])
+	}
+	if have := new(big.Int).SetBytes(results[1].ReturnData); have.Cmp(funds) != 0 {
+		t.Errorf("balance mismatch after failed call: have %v, want %v", have, funds)
+	}
+}
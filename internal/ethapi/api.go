@@ -18,6 +18,7 @@ package ethapi
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -41,6 +42,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/native"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
@@ -161,7 +163,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+			dump[fmt.Sprintf("%d", tx.Nonce())] = s.newRPCPoolTransaction(tx, curHeader)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -169,7 +171,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+			dump[fmt.Sprintf("%d", tx.Nonce())] = s.newRPCPoolTransaction(tx, curHeader)
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -185,22 +187,35 @@ func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string
 	// Build the pending transactions
 	dump := make(map[string]*RPCTransaction, len(pending))
 	for _, tx := range pending {
-		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+		dump[fmt.Sprintf("%d", tx.Nonce())] = s.newRPCPoolTransaction(tx, curHeader)
 	}
 	content["pending"] = dump
 
 	// Build the queued transactions
 	dump = make(map[string]*RPCTransaction, len(queue))
 	for _, tx := range queue {
-		dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, curHeader, s.b.ChainConfig())
+		dump[fmt.Sprintf("%d", tx.Nonce())] = s.newRPCPoolTransaction(tx, curHeader)
 	}
 	content["queued"] = dump
 
 	return content
 }
 
-// Status returns the number of pending and queued transaction in the pool.
-func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
+// newRPCPoolTransaction returns a pooled transaction that will serialize to the
+// RPC representation, flagged if private.
+func (s *PublicTxPoolAPI) newRPCPoolTransaction(tx *types.Transaction, current *types.Header) *RPCTransaction {
+	result := newRPCPendingTransaction(tx, current, s.b.ChainConfig())
+	result.Private = s.b.TxPoolPrivate(tx.Hash())
+	return result
+}
+
+// Status returns the number of pending and queued transaction in the pool. If
+// a transaction hash is given, its recent lifecycle within the pool is returned
+// instead.
+func (s *PublicTxPoolAPI) Status(hash *common.Hash) interface{} {
+	if hash != nil {
+		return newRPCTxLifecycle(s.b.TxPoolLifecycle(*hash))
+	}
 	pending, queue := s.b.Stats()
 	return map[string]hexutil.Uint{
 		"pending": hexutil.Uint(pending),
@@ -208,6 +223,139 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// RPCTxLifecycle is the recent lifecycle of a transaction within the pool.
+type RPCTxLifecycle struct {
+	Status string                 `json:"status"`
+	Events []*RPCTxLifecycleEvent `json:"events"`
+}
+
+// newRPCTxLifecycle assembles the lifecycle of a transaction from its recent
+// events, deriving its current status from the last one.
+func newRPCTxLifecycle(events []core.TxLifecycleEvent) *RPCTxLifecycle {
+	result := &RPCTxLifecycle{
+		Status: "unknown",
+		Events: make([]*RPCTxLifecycleEvent, len(events)),
+	}
+	for i, ev := range events {
+		result.Events[i] = newRPCTxLifecycleEvent(ev)
+	}
+	if len(events) > 0 {
+		switch kind := events[len(events)-1].Kind; kind {
+		case core.TxLifecycleAdded, core.TxLifecycleDemoted:
+			result.Status = "queued"
+		case core.TxLifecyclePromoted:
+			result.Status = "pending"
+		default:
+			result.Status = kind.String()
+		}
+	}
+	return result
+}
+
+// RPCTxLifecycleEvent is a state change of a transaction within the pool.
+type RPCTxLifecycleEvent struct {
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Event       string          `json:"event"`
+	Time        hexutil.Uint64  `json:"time"`
+	Reason      string          `json:"reason,omitempty"`
+	ReplacedBy  *common.Hash    `json:"replacedBy,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+}
+
+// newRPCTxLifecycleEvent returns a lifecycle event that will serialize to the
+// RPC representation, with the time in milliseconds since the unix epoch.
+func newRPCTxLifecycleEvent(ev core.TxLifecycleEvent) *RPCTxLifecycleEvent {
+	result := &RPCTxLifecycleEvent{
+		Hash:   ev.Hash,
+		From:   ev.From,
+		Nonce:  hexutil.Uint64(ev.Nonce),
+		Event:  ev.Kind.String(),
+		Time:   hexutil.Uint64(ev.Time.UnixNano() / int64(time.Millisecond)),
+		Reason: string(ev.Reason),
+	}
+	switch ev.Kind {
+	case core.TxLifecycleReplaced:
+		by := ev.By
+		result.ReplacedBy = &by
+	case core.TxLifecycleIncluded:
+		number := hexutil.Uint64(ev.Block)
+		result.BlockNumber = &number
+	}
+	return result
+}
+
+// TxLifecycleFilter selects the transactions whose lifecycle events are streamed
+// to a subscription. Empty criteria match all transactions.
+type TxLifecycleFilter struct {
+	Senders []common.Address `json:"senders"`
+	Hashes  []common.Hash    `json:"hashes"`
+}
+
+// matches checks whether a lifecycle event passes the filter. An event matches a
+// hash if it concerns the transaction or its replacement.
+func (f *TxLifecycleFilter) matches(ev core.TxLifecycleEvent) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Senders) > 0 {
+		var found bool
+		for _, sender := range f.Senders {
+			if sender == ev.From {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Hashes) > 0 {
+		var found bool
+		for _, hash := range f.Hashes {
+			if hash == ev.Hash || (ev.Kind == core.TxLifecycleReplaced && hash == ev.By) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Lifecycle creates a subscription that is triggered on every state change of a
+// transaction within the pool, optionally filtered by sender or hash.
+func (s *PublicTxPoolAPI) Lifecycle(ctx context.Context, filter *TxLifecycleFilter) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan core.TxLifecycleEvent, 128)
+		sub := s.b.SubscribeTxLifecycleEvent(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case ev := <-events:
+				if filter.matches(ev) {
+					notifier.Notify(rpcSub.ID, newRPCTxLifecycleEvent(ev))
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -219,10 +367,16 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 
 	// Define a formatter to flatten a transaction into a string
 	var format = func(tx *types.Transaction) string {
+		var flat string
 		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+			flat = fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+		} else {
+			flat = fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
 		}
-		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+		if s.b.TxPoolPrivate(tx.Hash()) {
+			flat += " (private)"
+		}
+		return flat
 	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
@@ -984,6 +1138,183 @@ func (s *PublicBlockChainAPI) Call(ctx context.Context, args TransactionArgs, bl
 	return result.Return(), result.Err
 }
 
+// BlockOverrides is a set of header fields to override when executing calls on
+// top of a block.
+type BlockOverrides struct {
+	Number   *hexutil.Big    `json:"number"`
+	Time     *hexutil.Uint64 `json:"time"`
+	Coinbase *common.Address `json:"coinbase"`
+	BaseFee  *hexutil.Big    `json:"baseFee"`
+}
+
+// Apply overrides the fields of the given header, returning the modified copy.
+func (diff *BlockOverrides) Apply(header *types.Header) *types.Header {
+	if diff == nil {
+		return header
+	}
+	header = types.CopyHeader(header)
+	if diff.Number != nil {
+		header.Number = (*big.Int)(diff.Number)
+	}
+	if diff.Time != nil {
+		header.Time = uint64(*diff.Time)
+	}
+	if diff.Coinbase != nil {
+		header.Coinbase = *diff.Coinbase
+	}
+	if diff.BaseFee != nil {
+		header.BaseFee = (*big.Int)(diff.BaseFee)
+	}
+	return header
+}
+
+// CallManyConfig is the collection of optional parameters of eth_callMany.
+type CallManyConfig struct {
+	StateOverrides *StateOverride  `json:"stateOverrides"`
+	BlockOverrides *BlockOverrides `json:"blockOverrides"`
+	Tracer         *string         `json:"tracer"` // Name of a native tracer to run on each call
+}
+
+// CallManyResult is the outcome of a single call executed by eth_callMany.
+type CallManyResult struct {
+	ReturnData   hexutil.Bytes   `json:"returnData"`
+	GasUsed      hexutil.Uint64  `json:"gasUsed"`
+	Logs         []*types.Log    `json:"logs"`
+	Error        string          `json:"error,omitempty"`
+	RevertReason string          `json:"revertReason,omitempty"`
+	Trace        json.RawMessage `json:"trace,omitempty"`
+}
+
+// DoCallMany executes the given calls in order on top of the state of the given
+// block, each call seeing the state changes of the preceding ones. Calls failing
+// pre-execution checks are reported in their result and leave the state intact.
+func DoCallMany(ctx context.Context, b Backend, calls []TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *CallManyConfig, timeout time.Duration, globalGasCap uint64) ([]*CallManyResult, error) {
+	defer func(start time.Time) { log.Debug("Executing EVM calls finished", "runtime", time.Since(start)) }(time.Now())
+
+	if config == nil {
+		config = new(CallManyConfig)
+	}
+	// Ensure the requested tracer exists before doing any work
+	if config.Tracer != nil {
+		if _, ok := native.New(*config.Tracer); !ok {
+			return nil, fmt.Errorf("unknown tracer %q", *config.Tracer)
+		}
+	}
+	state, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	if err := config.StateOverrides.Apply(state); err != nil {
+		return nil, err
+	}
+	state.Finalise(false)
+	header = config.BlockOverrides.Apply(header)
+
+	// Setup context so it may be cancelled the calls have completed
+	// or, in case of unmetered gas, setup a context with a timeout.
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	results := make([]*CallManyResult, 0, len(calls))
+	for i, args := range calls {
+		msg, err := args.ToMessage(globalGasCap, header.BaseFee)
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %v", i, err)
+		}
+		vmConfig := &vm.Config{NoBaseFee: true}
+
+		var tracer native.Tracer
+		if config.Tracer != nil {
+			tracer, _ = native.New(*config.Tracer)
+			vmConfig.Debug, vmConfig.Tracer = true, tracer
+		}
+		evm, vmError, err := b.GetEVM(ctx, msg, state, header, vmConfig)
+		if err != nil {
+			return nil, err
+		}
+		// The consensus engine may derive the beneficiary from the header seal,
+		// so enforce the overridden coinbase directly on the block context.
+		if config.BlockOverrides != nil && config.BlockOverrides.Coinbase != nil {
+			evm.Context.Coinbase = *config.BlockOverrides.Coinbase
+		}
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				evm.Cancel()
+				if tracer != nil {
+					tracer.Stop(errors.New("execution timeout"))
+				}
+			case <-done:
+			}
+		}()
+		// Execute the message, tagging its logs with a per-call hash so they
+		// can be told apart from the ones of the other calls.
+		txHash := common.BigToHash(big.NewInt(int64(i)))
+		state.Prepare(txHash, i)
+
+		snap := state.Snapshot()
+		gp := new(core.GasPool).AddGas(math.MaxUint64)
+		result, err := core.ApplyMessage(evm, msg, gp)
+		close(done)
+
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		res := &CallManyResult{Logs: []*types.Log{}}
+		if err != nil {
+			// The gas may already be bought before the checks fail, undo it
+			state.RevertToSnapshot(snap)
+			res.Error = fmt.Sprintf("err: %v (supplied gas %d)", err, msg.Gas())
+			results = append(results, res)
+			continue
+		}
+		res.ReturnData = result.ReturnData
+		res.GasUsed = hexutil.Uint64(result.UsedGas)
+		for _, log := range state.GetLogs(txHash, common.Hash{}) {
+			log.TxHash = common.Hash{}
+			res.Logs = append(res.Logs, log)
+		}
+		if result.Err != nil {
+			res.Error = result.Err.Error()
+			if len(result.Revert()) > 0 {
+				res.Error = newRevertError(result).Error()
+				if reason, err := abi.UnpackRevert(result.Revert()); err == nil {
+					res.RevertReason = reason
+				}
+			}
+		}
+		if tracer != nil {
+			if res.Trace, err = tracer.GetResult(); err != nil {
+				return nil, fmt.Errorf("call %d: %v", i, err)
+			}
+		}
+		state.Finalise(b.ChainConfig().IsEIP158(header.Number))
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// CallMany executes the given calls in order on top of the state of the given
+// block, each call seeing the effects of the preceding ones. This allows to
+// preview multi step flows, e.g. a token approval followed by a swap.
+//
+// Additionally, the caller can specify state and block context overrides, as
+// well as a native tracer to run on each of the calls.
+//
+// Note, this function doesn't make any changes in the state/blockchain.
+func (s *PublicBlockChainAPI) CallMany(ctx context.Context, calls []TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *CallManyConfig) ([]*CallManyResult, error) {
+	return DoCallMany(ctx, s.b, calls, blockNrOrHash, config, 5*time.Second, s.b.RPCGasCap())
+}
+
 func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, gasCap uint64) (hexutil.Uint64, error) {
 	// Binary search the gas requirement, as it may be higher than the amount used
 	var (
@@ -1283,6 +1614,7 @@ type RPCTransaction struct {
 	V                *hexutil.Big      `json:"v"`
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
+	Private          bool              `json:"private,omitempty"` // Only set for private transactions in the pool
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -1679,6 +2011,13 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SubmitTransaction is a helper function that submits tx to txPool and logs a message.
 func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	return submitTransaction(ctx, b, tx, false)
+}
+
+// submitTransaction is a helper function that submits tx to txPool, either as a
+// regular transaction or as a private one never propagated to the network, and
+// logs a message.
+func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, private bool) (common.Hash, error) {
 	// If the transaction fee cap is already specified, ensure the
 	// fee of the given transaction is _reasonable_.
 	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
@@ -1688,7 +2027,11 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 		// Ensure only eip155 signed transactions are submitted if EIP155Required is set.
 		return common.Hash{}, errors.New("only replay-protected (EIP-155) transactions allowed over RPC")
 	}
-	if err := b.SendTx(ctx, tx); err != nil {
+	if private {
+		if err := b.SendPrivateTx(ctx, tx); err != nil {
+			return common.Hash{}, err
+		}
+	} else if err := b.SendTx(ctx, tx); err != nil {
 		return common.Hash{}, err
 	}
 	// Print a log with full tx details for manual investigations and interventions
@@ -1698,11 +2041,15 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 		return common.Hash{}, err
 	}
 
+	logger := log.Root()
+	if private {
+		logger = logger.New("private", true)
+	}
 	if tx.To() == nil {
 		addr := crypto.CreateAddress(from, tx.Nonce())
-		log.Info("Submitted contract creation", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "contract", addr.Hex(), "value", tx.Value())
+		logger.Info("Submitted contract creation", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "contract", addr.Hex(), "value", tx.Value())
 	} else {
-		log.Info("Submitted transaction", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "recipient", tx.To(), "value", tx.Value())
+		logger.Info("Submitted transaction", "hash", tx.Hash().Hex(), "from", from, "nonce", tx.Nonce(), "recipient", tx.To(), "value", tx.Value())
 	}
 	return tx.Hash(), nil
 }
@@ -1766,6 +2113,18 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, input
 	return SubmitTransaction(ctx, s.b, tx)
 }
 
+// SendPrivateRawTransaction will add the signed transaction to the transaction pool
+// as a private transaction. It is only included in the blocks built by the local
+// node and never propagated to the network, being dropped if not included within
+// the configured number of blocks.
+func (s *PublicTransactionPoolAPI) SendPrivateRawTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(ctx, s.b, tx, true)
+}
+
 // Sign calculates an ECDSA signature for:
 // keccack256("\x19Ethereum Signed Message:\n" + len(message) + message).
 //
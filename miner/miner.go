@@ -51,6 +51,7 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+	TxOrder    string         `toml:",omitempty"` // Order of the transactions in built blocks (price, global-price, fifo or local-first)
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -199,6 +200,11 @@ func (miner *Miner) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
 	return miner.worker.pendingBlockAndReceipts()
 }
 
+// TxOrderer returns the strategy ordering the transactions of the built blocks.
+func (miner *Miner) TxOrderer() TxOrderer {
+	return miner.worker.orderer
+}
+
 func (miner *Miner) SetEtherbase(addr common.Address) {
 	miner.coinbase = addr
 	miner.worker.setEtherbase(addr)
@@ -0,0 +1,230 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"container/heap"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Names of the built-in transaction orderers.
+const (
+	TxOrderPrice       = "price"        // Price-and-nonce order, local transactions first
+	TxOrderGlobalPrice = "global-price" // Price-and-nonce order, regardless of origin
+	TxOrderFIFO        = "fifo"         // Arrival order, regardless of price and origin
+	TxOrderLocalFirst  = "local-first"  // Local transactions in arrival order, then remote ones by price
+)
+
+// TxSet is a set of transactions that can be retrieved in a nonce-honouring
+// order, while supporting the removal of all the remaining transactions of an
+// account.
+type TxSet interface {
+	// Peek returns the next transaction to commit, or nil if none are left.
+	Peek() *types.Transaction
+
+	// Shift replaces the next transaction with the following one from the same
+	// account.
+	Shift()
+
+	// Pop removes the next transaction, *not* replacing it with the following
+	// one from the same account.
+	Pop()
+}
+
+// TxOrderer decides in which order the pending transactions of the pool are
+// committed into the blocks being built.
+type TxOrderer interface {
+	// Order arranges the pending transactions, grouped by sender and sorted by
+	// nonce, into sets which are committed one after the other.
+	//
+	// Note, the pending map is reowned so the caller should not interact any
+	// more with it after providing it to the orderer.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address, baseFee *big.Int) []TxSet
+}
+
+// NewTxOrderer creates the built-in transaction orderer with the given name. An
+// empty name selects the default price-and-nonce orderer.
+func NewTxOrderer(name string) (TxOrderer, error) {
+	switch name {
+	case "", TxOrderPrice:
+		return priceOrderer{}, nil
+	case TxOrderGlobalPrice:
+		return globalPriceOrderer{}, nil
+	case TxOrderFIFO:
+		return fifoOrderer{}, nil
+	case TxOrderLocalFirst:
+		return localFirstOrderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction order %q", name)
+	}
+}
+
+// priceOrderer commits the transactions of the local accounts first, followed
+// by the remote ones, both in a profit-maximizing price-and-nonce order.
+type priceOrderer struct{}
+
+func (priceOrderer) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address, baseFee *big.Int) []TxSet {
+	var sets []TxSet
+
+	localTxs, remoteTxs := splitLocals(pending, locals)
+	if len(localTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(signer, localTxs, baseFee))
+	}
+	if len(remoteTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(signer, remoteTxs, baseFee))
+	}
+	return sets
+}
+
+// globalPriceOrderer commits all transactions in a single profit-maximizing
+// price-and-nonce order, without giving the local ones priority.
+type globalPriceOrderer struct{}
+
+func (globalPriceOrderer) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address, baseFee *big.Int) []TxSet {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []TxSet{types.NewTransactionsByPriceAndNonce(signer, pending, baseFee)}
+}
+
+// fifoOrderer commits all transactions in the order they arrived, regardless of
+// their price and origin, so that no sender can jump the queue by overpaying.
+type fifoOrderer struct{}
+
+func (fifoOrderer) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address, baseFee *big.Int) []TxSet {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []TxSet{newTransactionsByArrivalAndNonce(signer, pending, baseFee)}
+}
+
+// localFirstOrderer commits the transactions of the local accounts in the order
+// they were submitted, followed by the remote ones in price-and-nonce order.
+type localFirstOrderer struct{}
+
+func (localFirstOrderer) Order(signer types.Signer, pending map[common.Address]types.Transactions, locals []common.Address, baseFee *big.Int) []TxSet {
+	var sets []TxSet
+
+	localTxs, remoteTxs := splitLocals(pending, locals)
+	if len(localTxs) > 0 {
+		sets = append(sets, newTransactionsByArrivalAndNonce(signer, localTxs, baseFee))
+	}
+	if len(remoteTxs) > 0 {
+		sets = append(sets, types.NewTransactionsByPriceAndNonce(signer, remoteTxs, baseFee))
+	}
+	return sets
+}
+
+// splitLocals moves the transactions of the local accounts out of the pending
+// ones, returning the local and the remaining remote transactions.
+func splitLocals(pending map[common.Address]types.Transactions, locals []common.Address) (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
+	for _, account := range locals {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	return localTxs, remoteTxs
+}
+
+// txsByArrival implements a heap of transactions ordered by the time they were
+// first seen locally.
+type txsByArrival []*types.Transaction
+
+func (s txsByArrival) Len() int           { return len(s) }
+func (s txsByArrival) Less(i, j int) bool { return s[i].Time().Before(s[j].Time()) }
+func (s txsByArrival) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByArrival) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txsByArrival) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByArrivalAndNonce represents a set of transactions that can return
+// transactions in arrival order, while honouring the nonce order of accounts.
+type transactionsByArrivalAndNonce struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads   txsByArrival                          // Next transaction for each unique account (arrival heap)
+	signer  types.Signer                          // Signer for the set of transactions
+	baseFee *big.Int                              // Current base fee
+}
+
+// newTransactionsByArrivalAndNonce creates a transaction set that can retrieve
+// arrival sorted transactions in a nonce-honouring way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// it after providing it to the constructor.
+func newTransactionsByArrivalAndNonce(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) *transactionsByArrivalAndNonce {
+	heads := make(txsByArrival, 0, len(txs))
+	for from, accTxs := range txs {
+		acc, _ := types.Sender(signer, accTxs[0])
+		// Remove transaction if sender doesn't match from, or if it can't pay the base fee.
+		if _, err := accTxs[0].EffectiveGasTip(baseFee); acc != from || err != nil {
+			delete(txs, from)
+			continue
+		}
+		heads = append(heads, accTxs[0])
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+
+	return &transactionsByArrivalAndNonce{
+		txs:     txs,
+		heads:   heads,
+		signer:  signer,
+		baseFee: baseFee,
+	}
+}
+
+// Peek returns the next transaction by arrival.
+func (t *transactionsByArrivalAndNonce) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+// Shift replaces the current earliest head with the next one from the same account.
+func (t *transactionsByArrivalAndNonce) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0])
+	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
+		if _, err := txs[0].EffectiveGasTip(t.baseFee); err == nil {
+			t.heads[0], t.txs[acc] = txs[0], txs[1:]
+			heap.Fix(&t.heads, 0)
+			return
+		}
+	}
+	heap.Pop(&t.heads)
+}
+
+// Pop removes the earliest transaction, *not* replacing it with the next one
+// from the same account.
+func (t *transactionsByArrivalAndNonce) Pop() {
+	heap.Pop(&t.heads)
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// orderingTx creates a signed transaction with the given price, first seen at
+// the given offset from a fixed time.
+func orderingTx(t *testing.T, signer types.Signer, key *ecdsa.PrivateKey, nonce uint64, price int64, arrival int) *types.Transaction {
+	tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(price), nil), signer, key)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	tx.SetTime(time.Unix(1000000000, 0).Add(time.Duration(arrival) * time.Second))
+	return tx
+}
+
+// Tests that the built-in transaction orderers commit the pending transactions
+// in their respective orders.
+func TestTxOrderers(t *testing.T) {
+	signer := types.LatestSignerForChainID(common.Big1)
+
+	var (
+		keys  = make([]*ecdsa.PrivateKey, 4)
+		addrs = make([]common.Address, 4)
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	var (
+		a0 = orderingTx(t, signer, keys[0], 0, 1, 2)
+		a1 = orderingTx(t, signer, keys[0], 1, 1, 0) // Seen before a0, yet nonce ordered after
+		b0 = orderingTx(t, signer, keys[1], 0, 5, 1)
+		c0 = orderingTx(t, signer, keys[2], 0, 3, 3)
+		d0 = orderingTx(t, signer, keys[3], 0, 4, 4)
+	)
+	tests := []struct {
+		order  string
+		locals []common.Address
+		want   []*types.Transaction
+	}{
+		// Price orderer commits locals first, each group by price
+		{TxOrderPrice, nil, []*types.Transaction{b0, d0, c0, a0, a1}},
+		{TxOrderPrice, []common.Address{addrs[0], addrs[2]}, []*types.Transaction{c0, a0, a1, b0, d0}},
+		{"", []common.Address{addrs[0], addrs[2]}, []*types.Transaction{c0, a0, a1, b0, d0}},
+
+		// Global price orderer commits by price, regardless of locality
+		{TxOrderGlobalPrice, []common.Address{addrs[0], addrs[2]}, []*types.Transaction{b0, d0, c0, a0, a1}},
+
+		// FIFO orderer commits by arrival, regardless of price and locality
+		{TxOrderFIFO, nil, []*types.Transaction{b0, a0, a1, c0, d0}},
+		{TxOrderFIFO, []common.Address{addrs[2]}, []*types.Transaction{b0, a0, a1, c0, d0}},
+
+		// Local-first orderer commits locals by arrival, then remotes by price
+		{TxOrderLocalFirst, []common.Address{addrs[0], addrs[2]}, []*types.Transaction{a0, a1, c0, b0, d0}},
+		{TxOrderLocalFirst, nil, []*types.Transaction{b0, d0, c0, a0, a1}},
+	}
+	for i, tt := range tests {
+		orderer, err := NewTxOrderer(tt.order)
+		if err != nil {
+			t.Fatalf("test %d: failed to create %q orderer: %v", i, tt.order, err)
+		}
+		pending := map[common.Address]types.Transactions{
+			addrs[0]: {a0, a1},
+			addrs[1]: {b0},
+			addrs[2]: {c0},
+			addrs[3]: {d0},
+		}
+		var have []*types.Transaction
+		for _, set := range orderer.Order(signer, pending, tt.locals, nil) {
+			for tx := set.Peek(); tx != nil; tx = set.Peek() {
+				have = append(have, tx)
+				set.Shift()
+			}
+		}
+		if len(have) != len(tt.want) {
+			t.Fatalf("test %d: transaction count mismatch: have %d, want %d", i, len(have), len(tt.want))
+		}
+		for j := range have {
+			if have[j] != tt.want[j] {
+				t.Errorf("test %d: transaction %d mismatch: have nonce %d price %v, want nonce %d price %v",
+					i, j, have[j].Nonce(), have[j].GasPrice(), tt.want[j].Nonce(), tt.want[j].GasPrice())
+			}
+		}
+	}
+	if _, err := NewTxOrderer("random"); err == nil {
+		t.Errorf("unknown orderer created")
+	}
+}
+
+// Tests that the arrival ordered transaction set skips the accounts unable to
+// pay the base fee, and removes all transactions of an account on pop.
+func TestTransactionsByArrivalAndNonce(t *testing.T) {
+	signer := types.LatestSignerForChainID(common.Big1)
+
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+	key3, _ := crypto.GenerateKey()
+
+	var (
+		cheap = orderingTx(t, signer, key1, 0, 1, 0)
+		rich0 = orderingTx(t, signer, key2, 0, 10, 1)
+		rich1 = orderingTx(t, signer, key2, 1, 10, 2)
+		other = orderingTx(t, signer, key3, 0, 10, 3)
+	)
+	set := newTransactionsByArrivalAndNonce(signer, map[common.Address]types.Transactions{
+		crypto.PubkeyToAddress(key1.PublicKey): {cheap},
+		crypto.PubkeyToAddress(key2.PublicKey): {rich0, rich1},
+		crypto.PubkeyToAddress(key3.PublicKey): {other},
+	}, big.NewInt(5))
+
+	if tx := set.Peek(); tx != rich0 {
+		t.Fatalf("first transaction mismatch: have %v, want %v", tx.Hash(), rich0.Hash())
+	}
+	set.Pop()
+	if tx := set.Peek(); tx != other {
+		t.Fatalf("second transaction mismatch: have %v, want %v", tx.Hash(), other.Hash())
+	}
+	set.Shift()
+	if tx := set.Peek(); tx != nil {
+		t.Fatalf("unexpected transaction: %v", tx.Hash())
+	}
+}
@@ -128,6 +128,7 @@ type worker struct {
 	engine      consensus.Engine
 	eth         Backend
 	chain       *core.BlockChain
+	orderer     TxOrderer
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -189,7 +190,13 @@ type worker struct {
 }
 
 func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus.Engine, eth Backend, mux *event.TypeMux, isLocalBlock func(*types.Block) bool, init bool) *worker {
+	orderer, err := NewTxOrderer(config.TxOrder)
+	if err != nil {
+		log.Error("Ignoring invalid transaction order", "order", config.TxOrder, "err", err)
+		orderer, _ = NewTxOrderer("")
+	}
 	worker := &worker{
+		orderer:            orderer,
 		config:             config,
 		chainConfig:        chainConfig,
 		engine:             engine,
@@ -514,9 +521,10 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs, w.current.header.BaseFee)
 				tcount := w.current.tcount
-				w.commitTransactions(txset, coinbase, nil)
+				for _, txset := range w.orderer.Order(w.current.signer, txs, w.eth.TxPool().Locals(), w.current.header.BaseFee) {
+					w.commitTransactions(txset, coinbase, nil)
+				}
 				// Only update the snapshot if any new transactons were added
 				// to the pending block
 				if tcount != w.current.tcount {
@@ -763,7 +771,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs TxSet, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -989,22 +997,8 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
-	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.eth.TxPool().Locals() {
-		if txs := remoteTxs[account]; len(txs) > 0 {
-			delete(remoteTxs, account)
-			localTxs[account] = txs
-		}
-	}
-	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs, header.BaseFee)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
-		}
-	}
-	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs, header.BaseFee)
+	// Commit the pending transactions in the configured order
+	for _, txs := range w.orderer.Order(w.current.signer, pending, w.eth.TxPool().Locals(), header.BaseFee) {
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -45,6 +45,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the authenticated RPC secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -171,6 +172,24 @@ type Config struct {
 	// private APIs to untrusted users is a major security risk.
 	WSExposeAll bool `toml:",omitempty"`
 
+	// AuthAddr is the host interface on which to start the authenticated RPC
+	// server, serving the APIs flagged as authenticated. If this field is empty,
+	// authenticated APIs are only available over IPC and in-process.
+	AuthAddr string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated RPC
+	// server, serving both HTTP and websocket requests.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthVirtualHosts is the list of virtual hostnames which are allowed on
+	// incoming requests to the authenticated RPC server.
+	AuthVirtualHosts []string `toml:",omitempty"`
+
+	// JWTSecret is the path to the hex encoded shared secret used to authenticate
+	// requests to the authenticated RPC server. If empty, a secret is generated
+	// and stored in the data directory.
+	JWTSecret string `toml:",omitempty"`
+
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
 	// clients. Please be aware that CORS is a browser enforced security, it's fully
 	// useless for custom HTTP clients.
@@ -0,0 +1,190 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	jwtSecretLength = 32               // Length of the shared secret in bytes
+	jwtClockSkew    = 60 * time.Second // Maximum allowed distance between the token issue time and now
+)
+
+var (
+	errMissingToken    = errors.New("missing token")
+	errMalformedToken  = errors.New("malformed token")
+	errInvalidAlg      = errors.New("invalid signing algorithm")
+	errInvalidSig      = errors.New("signature invalid")
+	errMissingIssuedAt = errors.New("missing issued-at")
+	errStaleToken      = errors.New("stale token")
+	errFutureToken     = errors.New("token issued in the future")
+)
+
+// jwtHandler is a handler which authenticates incoming requests using HS256
+// signed JSON Web Tokens passed as bearer tokens.
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+func newJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, next: next}
+}
+
+// ServeHTTP implements http.Handler, only forwarding authenticated requests.
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var token string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if err := verifyJWT(h.secret, token, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// verifyJWT checks that the token is signed with the given secret using HS256
+// and that it was issued close enough to the given time.
+func verifyJWT(secret []byte, token string, now time.Time) error {
+	if token == "" {
+		return errMissingToken
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errMalformedToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return errInvalidAlg
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errMalformedToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errInvalidSig
+	}
+	var claims struct {
+		IssuedAt *int64 `json:"iat"`
+	}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	if claims.IssuedAt == nil {
+		return errMissingIssuedAt
+	}
+	issued := time.Unix(*claims.IssuedAt, 0)
+	if issued.Before(now.Add(-jwtClockSkew)) {
+		return errStaleToken
+	}
+	if issued.After(now.Add(jwtClockSkew)) {
+		return errFutureToken
+	}
+	return nil
+}
+
+// decodeJWTSegment decodes a base64url encoded JSON segment of a token.
+func decodeJWTSegment(segment string, v interface{}) error {
+	blob, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errMalformedToken
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return errMalformedToken
+	}
+	return nil
+}
+
+// ReadJWTSecret loads a hex encoded shared secret for the authenticated RPC
+// server from the given file.
+func ReadJWTSecret(path string) ([]byte, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hexutil.Decode(strings.TrimSpace(string(blob)))
+	if err != nil {
+		// Allow secrets without the 0x prefix too
+		secret, err = hexutil.Decode("0x" + strings.TrimSpace(string(blob)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt secret in %s: %v", path, err)
+	}
+	if len(secret) != jwtSecretLength {
+		return nil, fmt.Errorf("invalid jwt secret length in %s: have %d, want %d", path, len(secret), jwtSecretLength)
+	}
+	return secret, nil
+}
+
+// obtainJWTSecret loads the configured shared secret of the authenticated RPC
+// server. If none is configured, a secret is loaded from or generated into the
+// data directory.
+func (c *Config) obtainJWTSecret() ([]byte, error) {
+	if c.JWTSecret != "" {
+		return ReadJWTSecret(c.JWTSecret)
+	}
+	path := c.ResolvePath(datadirJWTSecret)
+	if path != "" {
+		if secret, err := ReadJWTSecret(path); err == nil {
+			return secret, nil
+		}
+	}
+	// No persistent secret found, generate a new one
+	secret := make([]byte, jwtSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if c.DataDir == "" {
+		log.Warn("Generated ephemeral JWT secret, authenticated RPC will be unusable for external clients")
+		return secret, nil
+	}
+	instanceDir := filepath.Join(c.DataDir, c.name())
+	if err := os.MkdirAll(instanceDir, 0700); err != nil {
+		return nil, err
+	}
+	path = filepath.Join(instanceDir, datadirJWTSecret)
+	if err := ioutil.WriteFile(path, []byte(common.Bytes2Hex(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", path)
+	return secret, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// signTestJWT creates an HS256 token with the given raw header and claims.
+func signTestJWT(secret []byte, header, claims string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Tests that tokens created by the rpc package are accepted by the verifier
+// and that invalid tokens are rejected with the proper error.
+func TestVerifyJWT(t *testing.T) {
+	header := http.Header{}
+	if err := rpc.NewJWTAuth(testJWTSecret)(header); err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	valid := strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+
+	var (
+		now     = time.Now()
+		hs256   = `{"alg":"HS256","typ":"JWT"}`
+		issued  = func(t time.Time) string { return `{"iat":` + strconv.FormatInt(t.Unix(), 10) + `}` }
+		badSign = valid[:strings.LastIndex(valid, ".")+1] + "AAAA"
+	)
+	tests := []struct {
+		token string
+		err   error
+	}{
+		{valid, nil},
+		{signTestJWT(testJWTSecret, hs256, issued(now.Add(-jwtClockSkew/2))), nil},
+		{signTestJWT(testJWTSecret, hs256, issued(now.Add(jwtClockSkew/2))), nil},
+		{"", errMissingToken},
+		{"abc.def", errMalformedToken},
+		{badSign, errInvalidSig},
+		{signTestJWT([]byte("other secret"), hs256, issued(now)), errInvalidSig},
+		{signTestJWT(testJWTSecret, `{"alg":"none"}`, issued(now)), errInvalidAlg},
+		{signTestJWT(testJWTSecret, hs256, `{}`), errMissingIssuedAt},
+		{signTestJWT(testJWTSecret, hs256, issued(now.Add(-2*jwtClockSkew))), errStaleToken},
+		{signTestJWT(testJWTSecret, hs256, issued(now.Add(2*jwtClockSkew))), errFutureToken},
+	}
+	for i, tt := range tests {
+		if err := verifyJWT(testJWTSecret, tt.token, now); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// Tests that shared secrets are loaded both with and without hex prefix, and
+// that secrets of the wrong length are rejected.
+func TestReadJWTSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwtsecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		ok      bool
+	}{
+		{"0x" + strings.Repeat("ab", jwtSecretLength), true},
+		{strings.Repeat("ab", jwtSecretLength) + "\n", true},
+		{strings.Repeat("ab", jwtSecretLength-1), false},
+		{"not hex", false},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "secret")
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		secret, err := ReadJWTSecret(path)
+		if tt.ok && (err != nil || len(secret) != jwtSecretLength) {
+			t.Errorf("test %d: failed to read secret: %v", i, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+}
@@ -54,6 +54,7 @@ type Node struct {
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	http          *httpServer //
 	ws            *httpServer //
+	httpAuth      *httpServer // Serves the authenticated APIs over both HTTP and websocket
 	ipc           *ipcServer  // Stores information about the ipc http server
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
 
@@ -146,6 +147,7 @@ func New(conf *Config) (*Node, error) {
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
+	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
 
 	return node, nil
@@ -348,6 +350,20 @@ func (n *Node) startRPC() error {
 		}
 	}
 
+	// Authenticated APIs are never served on the public HTTP and websocket
+	// endpoints, only on the dedicated authenticated one.
+	var (
+		open          []rpc.API
+		authenticated []string
+	)
+	for _, api := range n.rpcAPIs {
+		if api.Authenticated {
+			authenticated = append(authenticated, api.Namespace)
+		} else {
+			open = append(open, api)
+		}
+	}
+
 	// Configure HTTP.
 	if n.config.HTTPHost != "" {
 		config := httpConfig{
@@ -359,7 +375,7 @@ func (n *Node) startRPC() error {
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
 		}
-		if err := n.http.enableRPC(n.rpcAPIs, config); err != nil {
+		if err := n.http.enableRPC(open, config); err != nil {
 			return err
 		}
 	}
@@ -375,15 +391,35 @@ func (n *Node) startRPC() error {
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
 		}
-		if err := server.enableWS(n.rpcAPIs, config); err != nil {
+		if err := server.enableWS(open, config); err != nil {
 			return err
 		}
 	}
 
+	// Configure the authenticated HTTP and WebSocket endpoint.
+	if len(authenticated) > 0 && n.config.AuthAddr != "" {
+		secret, err := n.config.obtainJWTSecret()
+		if err != nil {
+			return err
+		}
+		modules := append(authenticated, DefaultAuthModules...)
+		if err := n.httpAuth.setListenAddr(n.config.AuthAddr, n.config.AuthPort); err != nil {
+			return err
+		}
+		if err := n.httpAuth.enableRPC(n.rpcAPIs, httpConfig{Vhosts: n.config.AuthVirtualHosts, Modules: modules, jwtSecret: secret}); err != nil {
+			return err
+		}
+		if err := n.httpAuth.enableWS(n.rpcAPIs, wsConfig{Modules: modules, jwtSecret: secret}); err != nil {
+			return err
+		}
+	}
 	if err := n.http.start(); err != nil {
 		return err
 	}
-	return n.ws.start()
+	if err := n.ws.start(); err != nil {
+		return err
+	}
+	return n.httpAuth.start()
 }
 
 func (n *Node) wsServerForPort(port int) *httpServer {
@@ -396,6 +432,7 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	n.httpAuth.stop()
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -538,6 +575,12 @@ func (n *Node) WSEndpoint() string {
 	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
 }
 
+// AuthEndpoint returns the URL of the authenticated RPC server, serving both
+// HTTP and websocket requests.
+func (n *Node) AuthEndpoint() string {
+	return "http://" + n.httpAuth.listenAddr()
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -17,6 +17,7 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -24,10 +25,12 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -642,3 +645,77 @@ func containsAPI(stackAPIs []rpc.API, api rpc.API) bool {
 	}
 	return false
 }
+
+type authTestService struct{}
+
+func (s *authTestService) Hello() string { return "hello" }
+
+// Tests that authenticated APIs are only exposed on the dedicated endpoint and
+// that requests to it are rejected unless they carry a valid token.
+func TestAuthenticatedAPIs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "authrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretFile := filepath.Join(dir, "jwtsecret")
+	if err := ioutil.WriteFile(secretFile, []byte(hexutil.Encode(testJWTSecret)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	conf := &Config{
+		HTTPHost:  "127.0.0.1",
+		AuthAddr:  "127.0.0.1",
+		JWTSecret: secretFile,
+	}
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create a new node: %v", err)
+	}
+	defer node.Close()
+
+	node.RegisterAPIs([]rpc.API{{
+		Namespace:     "engine",
+		Version:       "1.0",
+		Service:       new(authTestService),
+		Public:        true,
+		Authenticated: true,
+	}})
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	var result string
+
+	// The public endpoint must not serve the authenticated namespace.
+	public, err := rpc.Dial(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer public.Close()
+	if err := public.Call(&result, "engine_hello"); err == nil {
+		t.Fatal("authenticated api served on public endpoint")
+	}
+	// The authenticated endpoint must reject unauthenticated requests.
+	unauth, err := rpc.Dial(node.AuthEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unauth.Close()
+	if err := unauth.Call(&result, "engine_hello"); err == nil {
+		t.Fatal("unauthenticated request accepted")
+	}
+	// Authenticated requests must succeed over both HTTP and websocket.
+	for _, endpoint := range []string{node.AuthEndpoint(), "ws://" + strings.TrimPrefix(node.AuthEndpoint(), "http://")} {
+		client, err := rpc.DialContextWithAuth(context.Background(), endpoint, rpc.NewJWTAuth(testJWTSecret))
+		if err != nil {
+			t.Fatalf("%s: failed to dial: %v", endpoint, err)
+		}
+		if err := client.Call(&result, "engine_hello"); err != nil {
+			t.Fatalf("%s: authenticated call failed: %v", endpoint, err)
+		}
+		if result != "hello" {
+			t.Fatalf("%s: result mismatch: have %q, want %q", endpoint, result, "hello")
+		}
+		client.Close()
+	}
+}
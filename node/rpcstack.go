@@ -40,13 +40,15 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
+	jwtSecret          []byte // optional JWT secret, requests are authenticated if set
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins []string
-	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	Origins   []string
+	Modules   []string
+	prefix    string // path prefix on which to mount ws handler
+	jwtSecret []byte // optional JWT secret, handshakes are authenticated if set
 }
 
 type rpcHandler struct {
@@ -283,9 +285,13 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	handler := NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts)
+	if len(config.jwtSecret) != 0 {
+		handler = newJWTHandler(config.jwtSecret, handler)
+	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
@@ -315,9 +321,13 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
+	handler := srv.WebsocketHandler(config.Origins)
+	if len(config.jwtSecret) != 0 {
+		handler = newJWTHandler(config.jwtSecret, handler)
+	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: handler,
 		server:  srv,
 	})
 	return nil
@@ -17,9 +17,11 @@
 package params
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"golang.org/x/crypto/sha3"
@@ -225,16 +227,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, new(EthashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, new(EthashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -316,6 +318,18 @@ type ChainConfig struct {
 
 	CatalystBlock *big.Int `json:"catalystBlock,omitempty"` // Catalyst switch block (nil = no fork, 0 = already on catalyst)
 
+	// CatalystTotalDifficulty is the total difficulty at which the network hands
+	// block production over to an external consensus client. The first block whose
+	// parent reached it is the first catalyst block (nil = no difficulty trigger).
+	CatalystTotalDifficulty *big.Int `json:"catalystTotalDifficulty,omitempty"`
+
+	EIP1153Block *big.Int `json:"eip1153Block,omitempty"` // EIP-1153 (transient storage) switch block (nil = no fork, 0 = already activated)
+
+	// Precompiles schedules the activation of additional precompiled contracts
+	// on top of the ones of the active fork, mapping their addresses to their
+	// activation blocks. The implementations need to be registered in the vm.
+	Precompiles map[common.Address]*big.Int `json:"precompiles,omitempty"`
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
@@ -437,15 +451,35 @@ func (c *ChainConfig) IsCatalyst(num *big.Int) bool {
 	return isForked(c.CatalystBlock, num)
 }
 
+// ActivePrecompiles returns the sorted addresses of the additional precompiles
+// activated by the config at the given block number.
+func (c *ChainConfig) ActivePrecompiles(num *big.Int) []common.Address {
+	var active []common.Address
+	for _, addr := range precompileAddresses(c.Precompiles) {
+		if isForked(c.Precompiles[addr], num) {
+			active = append(active, addr)
+		}
+	}
+	return active
+}
+
+// IsEIP1153 returns whether num is either equal to the EIP-1153 fork block or greater.
+func (c *ChainConfig) IsEIP1153(num *big.Int) bool {
+	return isForked(c.EIP1153Block, num)
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
-func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
+//
+// The transition is the first block of the chain whose parent reached the lower
+// of the stored and new catalyst total difficulties, nil if none did.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64, transition *big.Int) *ConfigCompatError {
 	bhead := new(big.Int).SetUint64(height)
 
 	// Iterate checkCompatible to find the lowest conflict.
 	var lasterr *ConfigCompatError
 	for {
-		err := c.checkCompatible(newcfg, bhead)
+		err := c.checkCompatible(newcfg, bhead, transition)
 		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo) {
 			break
 		}
@@ -499,7 +533,7 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 	return nil
 }
 
-func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
+func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int, transition *big.Int) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
 	}
@@ -546,9 +580,49 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.LondonBlock, newcfg.LondonBlock, head) {
 		return newCompatError("London fork block", c.LondonBlock, newcfg.LondonBlock)
 	}
+	if isForkIncompatible(c.CatalystBlock, newcfg.CatalystBlock, head) {
+		return newCompatError("Catalyst fork block", c.CatalystBlock, newcfg.CatalystBlock)
+	}
+	if isForked(transition, head) && !configNumEqual(c.CatalystTotalDifficulty, newcfg.CatalystTotalDifficulty) {
+		err := &ConfigCompatError{"Catalyst total difficulty", c.CatalystTotalDifficulty, newcfg.CatalystTotalDifficulty, 0}
+		if transition.Sign() > 0 {
+			err.RewindTo = transition.Uint64() - 1
+		}
+		return err
+	}
+	if isForkIncompatible(c.EIP1153Block, newcfg.EIP1153Block, head) {
+		return newCompatError("EIP1153 fork block", c.EIP1153Block, newcfg.EIP1153Block)
+	}
+	for _, addr := range precompileAddresses(c.Precompiles, newcfg.Precompiles) {
+		if isForkIncompatible(c.Precompiles[addr], newcfg.Precompiles[addr], head) {
+			return newCompatError(fmt.Sprintf("precompile %x activation block", addr), c.Precompiles[addr], newcfg.Precompiles[addr])
+		}
+	}
 	return nil
 }
 
+// precompileAddresses returns the sorted union of the precompile addresses
+// scheduled in the given activation maps.
+func precompileAddresses(schedules ...map[common.Address]*big.Int) []common.Address {
+	var addrs []common.Address
+	for _, schedule := range schedules {
+		for addr := range schedule {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(addrs[i][:], addrs[j][:]) < 0
+	})
+	// Drop the duplicates of addresses present in multiple schedules
+	for i := 1; i < len(addrs); i++ {
+		if addrs[i] == addrs[i-1] {
+			addrs = append(addrs[:i], addrs[i+1:]...)
+			i--
+		}
+	}
+	return addrs
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -577,7 +651,7 @@ func configNumEqual(x, y *big.Int) bool {
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
 	What string
-	// block numbers of the stored and new configurations
+	// block numbers (or total difficulties) of the stored and new configurations
 	StoredConfig, NewConfig *big.Int
 	// the block number to which the local chain must be rewound to correct the error
 	RewindTo uint64
@@ -614,6 +688,11 @@ type Rules struct {
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 	IsBerlin, IsLondon, IsCatalyst                          bool
+	IsEIP1153                                               bool
+
+	// ExtraPrecompiles are the sorted addresses of the additional precompiles
+	// activated by the chain config.
+	ExtraPrecompiles []common.Address
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -635,5 +714,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsBerlin:         c.IsBerlin(num),
 		IsLondon:         c.IsLondon(num),
 		IsCatalyst:       c.IsCatalyst(num),
+		IsEIP1153:        c.IsEIP1153(num),
+		ExtraPrecompiles: c.ActivePrecompiles(num),
 	}
 }
@@ -20,12 +20,15 @@ import (
 	"math/big"
 	"reflect"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 func TestCheckCompatible(t *testing.T) {
 	type test struct {
 		stored, new *ChainConfig
 		head        uint64
+		transition  *big.Int
 		wantErr     *ConfigCompatError
 	}
 	tests := []test{
@@ -87,12 +90,105 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{Precompiles: map[common.Address]*big.Int{{0x0a}: big.NewInt(30)}},
+			new:     &ChainConfig{Precompiles: map[common.Address]*big.Int{{0x0a}: big.NewInt(40), {0x0b}: big.NewInt(50)}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Precompiles: map[common.Address]*big.Int{{0x0a}: big.NewInt(30)}},
+			new:    &ChainConfig{Precompiles: map[common.Address]*big.Int{{0x0a}: big.NewInt(30), {0x0b}: big.NewInt(20)}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "precompile 0b00000000000000000000000000000000000000 activation block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(20),
+				RewindTo:     19,
+			},
+		},
+		{
+			stored:  &ChainConfig{CatalystBlock: big.NewInt(30)},
+			new:     &ChainConfig{CatalystBlock: big.NewInt(50)},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{CatalystBlock: big.NewInt(30)},
+			new:    &ChainConfig{CatalystBlock: big.NewInt(50)},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "Catalyst fork block",
+				StoredConfig: big.NewInt(30),
+				NewConfig:    big.NewInt(50),
+				RewindTo:     29,
+			},
+		},
+		{
+			stored:  &ChainConfig{CatalystTotalDifficulty: big.NewInt(1000)},
+			new:     &ChainConfig{CatalystTotalDifficulty: big.NewInt(2000)},
+			head:    40,
+			wantErr: nil,
+		},
+		{
+			stored:     &ChainConfig{CatalystTotalDifficulty: big.NewInt(1000)},
+			new:        &ChainConfig{CatalystTotalDifficulty: big.NewInt(1000)},
+			head:       40,
+			transition: big.NewInt(30),
+			wantErr:    nil,
+		},
+		{
+			stored:     &ChainConfig{CatalystTotalDifficulty: big.NewInt(1000)},
+			new:        &ChainConfig{CatalystTotalDifficulty: big.NewInt(2000)},
+			head:       40,
+			transition: big.NewInt(30),
+			wantErr: &ConfigCompatError{
+				What:         "Catalyst total difficulty",
+				StoredConfig: big.NewInt(1000),
+				NewConfig:    big.NewInt(2000),
+				RewindTo:     29,
+			},
+		},
+		{
+			stored:     &ChainConfig{},
+			new:        &ChainConfig{CatalystTotalDifficulty: big.NewInt(1000)},
+			head:       40,
+			transition: big.NewInt(30),
+			wantErr: &ConfigCompatError{
+				What:         "Catalyst total difficulty",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(1000),
+				RewindTo:     29,
+			},
+		},
 	}
 
 	for _, test := range tests {
-		err := test.stored.CheckCompatible(test.new, test.head)
+		err := test.stored.CheckCompatible(test.new, test.head, test.transition)
 		if !reflect.DeepEqual(err, test.wantErr) {
 			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
 		}
 	}
 }
+
+func TestRulesPrecompiles(t *testing.T) {
+	config := &ChainConfig{Precompiles: map[common.Address]*big.Int{
+		{0x0c}: big.NewInt(10),
+		{0x0a}: big.NewInt(0),
+		{0x0b}: big.NewInt(10),
+		{0x0d}: nil,
+	}}
+	tests := []struct {
+		number uint64
+		want   []common.Address
+	}{
+		{0, []common.Address{{0x0a}}},
+		{9, []common.Address{{0x0a}}},
+		{10, []common.Address{{0x0a}, {0x0b}, {0x0c}}},
+	}
+	for _, tt := range tests {
+		if have := config.Rules(new(big.Int).SetUint64(tt.number)).ExtraPrecompiles; !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("block %d: active precompiles mismatch: have %x, want %x", tt.number, have, tt.want)
+		}
+	}
+}
@@ -185,6 +185,24 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	}
 }
 
+// DialContextWithAuth creates a new RPC client, just like DialContext, but
+// authenticates all HTTP requests and websocket handshakes with the given
+// provider. Other transports are not supported.
+func DialContextWithAuth(ctx context.Context, rawurl string, auth HTTPAuth) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return DialHTTPWithAuth(rawurl, auth)
+	case "ws", "wss":
+		return DialWebsocketWithAuth(ctx, rawurl, "", auth)
+	default:
+		return nil, fmt.Errorf("no authenticated transport for URL scheme %q", u.Scheme)
+	}
+}
+
 // Client retrieves the client from the context, if any. This can be used to perform
 // 'reverse calls' in a handler method.
 func ClientFromContext(ctx context.Context) (*Client, bool) {
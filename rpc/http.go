@@ -46,6 +46,7 @@ type httpConn struct {
 	closeCh   chan interface{}
 	mu        sync.Mutex // protects headers
 	headers   http.Header
+	auth      HTTPAuth // optional authentication applied to every request
 }
 
 // httpConn is treated specially by Client.
@@ -105,6 +106,10 @@ var DefaultHTTPTimeouts = HTTPTimeouts{
 // DialHTTPWithClient creates a new RPC client that connects to an RPC server over HTTP
 // using the provided HTTP Client.
 func DialHTTPWithClient(endpoint string, client *http.Client) (*Client, error) {
+	return dialHTTP(endpoint, client, nil)
+}
+
+func dialHTTP(endpoint string, client *http.Client, auth HTTPAuth) (*Client, error) {
 	// Sanity check URL so we don't end up with a client that will fail every request.
 	_, err := url.Parse(endpoint)
 	if err != nil {
@@ -121,6 +126,7 @@ func DialHTTPWithClient(endpoint string, client *http.Client) (*Client, error) {
 			headers: headers,
 			url:     endpoint,
 			closeCh: make(chan interface{}),
+			auth:    auth,
 		}
 		return hc, nil
 	})
@@ -131,6 +137,12 @@ func DialHTTP(endpoint string) (*Client, error) {
 	return DialHTTPWithClient(endpoint, new(http.Client))
 }
 
+// DialHTTPWithAuth creates a new RPC client that connects to an RPC server over
+// HTTP, authenticating every request with the given provider.
+func DialHTTPWithAuth(endpoint string, auth HTTPAuth) (*Client, error) {
+	return dialHTTP(endpoint, new(http.Client), auth)
+}
+
 func (c *Client) sendHTTP(ctx context.Context, op *requestOp, msg interface{}) error {
 	hc := c.writeConn.(*httpConn)
 	respBody, err := hc.doRequest(ctx, msg)
@@ -180,6 +192,12 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	req.Header = hc.headers.Clone()
 	hc.mu.Unlock()
 
+	if hc.auth != nil {
+		if err := hc.auth(req.Header); err != nil {
+			return nil, err
+		}
+	}
+
 	// do request
 	resp, err := hc.client.Do(req)
 	if err != nil {
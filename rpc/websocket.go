@@ -183,11 +183,33 @@ func parseOriginURL(origin string) (string, string, string, error) {
 // DialWebsocketWithDialer creates a new RPC client that communicates with a JSON-RPC server
 // that is listening on the given endpoint using the provided dialer.
 func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, dialer websocket.Dialer) (*Client, error) {
+	return dialWebsocket(ctx, endpoint, origin, dialer, nil)
+}
+
+// DialWebsocketWithAuth creates a new RPC client that communicates with a JSON-RPC
+// server that is listening on the given endpoint, authenticating the handshake
+// of every (re)connection with the given provider.
+func DialWebsocketWithAuth(ctx context.Context, endpoint, origin string, auth HTTPAuth) (*Client, error) {
+	dialer := websocket.Dialer{
+		ReadBufferSize:  wsReadBuffer,
+		WriteBufferSize: wsWriteBuffer,
+		WriteBufferPool: wsBufferPool,
+	}
+	return dialWebsocket(ctx, endpoint, origin, dialer, auth)
+}
+
+func dialWebsocket(ctx context.Context, endpoint, origin string, dialer websocket.Dialer, auth HTTPAuth) (*Client, error) {
 	endpoint, header, err := wsClientHeaders(endpoint, origin)
 	if err != nil {
 		return nil, err
 	}
 	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		header := header.Clone()
+		if auth != nil {
+			if err := auth(header); err != nil {
+				return nil, err
+			}
+		}
 		conn, resp, err := dialer.DialContext(ctx, endpoint, header)
 		if err != nil {
 			hErr := wsHandshakeError{err: err}